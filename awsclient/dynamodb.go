@@ -1,6 +1,7 @@
 package awsclient
 
 import (
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
@@ -8,5 +9,50 @@ type DynamoDB interface {
 	CreateTable(*dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error)
 	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
 	GetItem(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	UpdateItem(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	Query(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	ScanPages(*dynamodb.ScanInput, func(*dynamodb.ScanOutput, bool) bool) error
 	ListTablesPages(*dynamodb.ListTablesInput, func(*dynamodb.ListTablesOutput, bool) bool) error
+	UpdateTimeToLive(*dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error)
+	UpdateTable(*dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error)
+	UpdateTableToPayPerRequest(*dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error)
+	DescribeTable(*dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error)
+	WaitUntilTableExists(*dynamodb.DescribeTableInput) error
+}
+
+// dynamoDB supports on-demand billing mode, which the vendored SDK predates
+type dynamoDB struct {
+	*dynamodb.DynamoDB
+}
+
+// CreateTable creates a table in PAY_PER_REQUEST billing mode if ProvisionedThroughput is nil
+func (d *dynamoDB) CreateTable(input *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	if input.ProvisionedThroughput != nil {
+		return d.DynamoDB.CreateTable(input)
+	}
+
+	req, out := d.DynamoDB.CreateTableRequest(input)
+	// ProvisionedThroughput is required by the validation of the SDK
+	req.Handlers.Validate.Remove(corehandlers.ValidateParametersHandler)
+	req.Handlers.Build.PushBack(setBodyField("BillingMode", "PAY_PER_REQUEST"))
+	return out, req.Send()
+}
+
+// UpdateTable updates a table. Indexes created without ProvisionedThroughput are for a table in PAY_PER_REQUEST billing mode.
+func (d *dynamoDB) UpdateTable(input *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	req, out := d.DynamoDB.UpdateTableRequest(input)
+	for _, u := range input.GlobalSecondaryIndexUpdates {
+		if u.Create != nil && u.Create.ProvisionedThroughput == nil {
+			// ProvisionedThroughput of indexes is required by the validation of the SDK
+			req.Handlers.Validate.Remove(corehandlers.ValidateParametersHandler)
+		}
+	}
+	return out, req.Send()
+}
+
+// UpdateTableToPayPerRequest updates a table and switches it to PAY_PER_REQUEST billing mode
+func (d *dynamoDB) UpdateTableToPayPerRequest(input *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	req, out := d.DynamoDB.UpdateTableRequest(input)
+	req.Handlers.Build.PushBack(setBodyField("BillingMode", "PAY_PER_REQUEST"))
+	return out, req.Send()
 }
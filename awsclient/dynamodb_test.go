@@ -0,0 +1,108 @@
+package awsclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// newTestDynamoDB returns a client of a server which records the last request body
+func newTestDynamoDB(body *map[string]interface{}) (*dynamoDB, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		*body = map[string]interface{}{}
+		json.Unmarshal(b, body)
+		w.Write([]byte("{}"))
+	}))
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	return &dynamoDB{dynamodb.New(sess)}, server.Close
+}
+
+func TestDynamoDBCreateTablePayPerRequest(t *testing.T) {
+	var body map[string]interface{}
+	d, closeServer := newTestDynamoDB(&body)
+	defer closeServer()
+
+	_, err := d.CreateTable(&dynamodb.CreateTableInput{
+		TableName: aws.String("ParamedicCommands"),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("CommandID"), AttributeType: aws.String("S")},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("CommandID"), KeyType: aws.String("HASH")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body["BillingMode"] != "PAY_PER_REQUEST" {
+		t.Errorf("got BillingMode %v, want PAY_PER_REQUEST", body["BillingMode"])
+	}
+	if body["TableName"] != "ParamedicCommands" {
+		t.Errorf("got TableName %v, want ParamedicCommands", body["TableName"])
+	}
+}
+
+func TestDynamoDBUpdateTableToPayPerRequest(t *testing.T) {
+	var body map[string]interface{}
+	d, closeServer := newTestDynamoDB(&body)
+	defer closeServer()
+
+	_, err := d.UpdateTableToPayPerRequest(&dynamodb.UpdateTableInput{
+		TableName: aws.String("ParamedicCommands"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body["BillingMode"] != "PAY_PER_REQUEST" {
+		t.Errorf("got BillingMode %v, want PAY_PER_REQUEST", body["BillingMode"])
+	}
+}
+
+func TestDynamoDBUpdateTableCreateIndexWithoutThroughput(t *testing.T) {
+	var body map[string]interface{}
+	d, closeServer := newTestDynamoDB(&body)
+	defer closeServer()
+
+	_, err := d.UpdateTable(&dynamodb.UpdateTableInput{
+		TableName: aws.String("ParamedicCommands"),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("Status"), AttributeType: aws.String("S")},
+		},
+		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+			{
+				Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+					IndexName: aws.String("Status-index"),
+					KeySchema: []*dynamodb.KeySchemaElement{
+						{AttributeName: aws.String("Status"), KeyType: aws.String("HASH")},
+					},
+					Projection: &dynamodb.Projection{ProjectionType: aws.String("ALL")},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := body["BillingMode"]; ok {
+		t.Errorf("got BillingMode %v, want none", body["BillingMode"])
+	}
+	if _, ok := body["GlobalSecondaryIndexUpdates"]; !ok {
+		t.Error("GlobalSecondaryIndexUpdates is not sent")
+	}
+}
@@ -0,0 +1,7 @@
+package awsclient
+
+import "github.com/aws/aws-sdk-go/service/ec2"
+
+type EC2 interface {
+	DescribeTagsPages(*ec2.DescribeTagsInput, func(*ec2.DescribeTagsOutput, bool) bool) error
+}
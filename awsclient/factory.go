@@ -1,48 +1,203 @@
 package awsclient
 
 import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/kinesis"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/sts"
 )
 
+// Service names used as keys of Options.Endpoints
+const (
+	ServiceSSM            = "ssm"
+	ServiceS3             = "s3"
+	ServiceDynamoDB       = "dynamodb"
+	ServiceCloudWatchLogs = "logs"
+	ServiceKinesis        = "kinesis"
+	ServiceSTS            = "sts"
+	ServiceIAM            = "iam"
+	ServiceEC2            = "ec2"
+)
+
+// Options overrides the configuration of the default session
+type Options struct {
+	Profile string
+	Region  string
+
+	// RoleARN is a role to be assumed
+	RoleARN         string
+	ExternalID      string
+	RoleSessionName string
+	// MFASerial is a serial number of the MFA device required to assume the role
+	MFASerial string
+	// TokenProvider returns an MFA token code
+	TokenProvider func() (string, error)
+
+	// Endpoint is used for all services which are not in Endpoints
+	Endpoint  string
+	Endpoints map[string]string // map[service]endpoint URL
+}
+
 type Factory struct {
-	sess *session.Session
+	sess      *session.Session
+	endpoints map[string]string
+	clients   *Clients
+}
+
+// Clients are returned by a factory instead of clients of the SDK (e.g. fakes in tests)
+type Clients struct {
+	Region         string
+	SSM            SSM
+	S3             S3
+	DynamoDB       DynamoDB
+	CloudWatchLogs CloudWatchLogs
+	Kinesis        Kinesis
+	STS            STS
+	IAM            IAM
+	EC2            EC2
+}
+
+// NewFactoryWithClients returns a factory which returns the given clients
+func NewFactoryWithClients(clients *Clients) *Factory {
+	return &Factory{clients: clients}
 }
 
-func NewFactory() (*Factory, error) {
-	sess, err := session.NewSession()
+func NewFactory(opts *Options) (*Factory, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	tokenProvider := opts.TokenProvider
+	if tokenProvider == nil {
+		tokenProvider = stscreds.StdinTokenProvider
+	}
+
+	config := aws.Config{}
+	if opts.Region != "" {
+		config.Region = aws.String(opts.Region)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:                  config,
+		Profile:                 opts.Profile,
+		SharedConfigState:       session.SharedConfigEnable,
+		AssumeRoleTokenProvider: tokenProvider,
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &Factory{
-		sess: sess,
-	}, nil
+
+	f := &Factory{
+		sess:      sess,
+		endpoints: map[string]string{},
+	}
+	for _, s := range []string{ServiceSSM, ServiceS3, ServiceDynamoDB, ServiceCloudWatchLogs, ServiceKinesis, ServiceSTS, ServiceIAM, ServiceEC2} {
+		if e, ok := opts.Endpoints[s]; ok {
+			f.endpoints[s] = e
+		} else if opts.Endpoint != "" {
+			f.endpoints[s] = opts.Endpoint
+		}
+	}
+
+	if opts.RoleARN != "" {
+		stsSess := sess.Copy(f.config(ServiceSTS))
+		creds := stscreds.NewCredentials(stsSess, opts.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			if opts.RoleSessionName != "" {
+				p.RoleSessionName = opts.RoleSessionName
+			}
+			if opts.ExternalID != "" {
+				p.ExternalID = aws.String(opts.ExternalID)
+			}
+			if opts.MFASerial != "" {
+				p.SerialNumber = aws.String(opts.MFASerial)
+				p.TokenProvider = tokenProvider
+			}
+		})
+		f.sess = sess.Copy(&aws.Config{Credentials: creds})
+	}
+
+	return f, nil
+}
+
+// config returns the configuration of a service client
+func (f *Factory) config(service string) *aws.Config {
+	c := aws.NewConfig()
+	if e, ok := f.endpoints[service]; ok {
+		c = c.WithEndpoint(e)
+		if service == ServiceS3 {
+			// Emulators don't support virtual-hosted style
+			c = c.WithS3ForcePathStyle(true)
+		}
+	}
+	return c
 }
 
 func (f *Factory) Region() string {
-	return *f.sess.Config.Region
+	if f.clients != nil {
+		return f.clients.Region
+	}
+	return aws.StringValue(f.sess.Config.Region)
 }
 
 func (f *Factory) SSM() SSM {
-	return SSM(ssm.New(f.sess))
+	if f.clients != nil {
+		return f.clients.SSM
+	}
+	return &ssmClient{ssm.New(f.sess, f.config(ServiceSSM))}
 }
 
 func (f *Factory) S3() S3 {
-	return S3(s3.New(f.sess))
+	if f.clients != nil {
+		return f.clients.S3
+	}
+	return S3(s3.New(f.sess, f.config(ServiceS3)))
 }
 
 func (f *Factory) DynamoDB() DynamoDB {
-	return DynamoDB(dynamodb.New(f.sess))
+	if f.clients != nil {
+		return f.clients.DynamoDB
+	}
+	return DynamoDB(&dynamoDB{dynamodb.New(f.sess, f.config(ServiceDynamoDB))})
 }
 
 func (f *Factory) CloudWatchLogs() CloudWatchLogs {
-	return CloudWatchLogs(cloudwatchlogs.New(f.sess))
+	if f.clients != nil {
+		return f.clients.CloudWatchLogs
+	}
+	return CloudWatchLogs(cloudwatchlogs.New(f.sess, f.config(ServiceCloudWatchLogs)))
 }
 
 func (f *Factory) Kinesis() Kinesis {
-	return Kinesis(kinesis.New(f.sess))
+	if f.clients != nil {
+		return f.clients.Kinesis
+	}
+	return Kinesis(kinesis.New(f.sess, f.config(ServiceKinesis)))
+}
+
+func (f *Factory) IAM() IAM {
+	if f.clients != nil {
+		return f.clients.IAM
+	}
+	return IAM(iam.New(f.sess, f.config(ServiceIAM)))
+}
+
+func (f *Factory) STS() STS {
+	if f.clients != nil {
+		return f.clients.STS
+	}
+	return STS(sts.New(f.sess, f.config(ServiceSTS)))
+}
+
+func (f *Factory) EC2() EC2 {
+	if f.clients != nil {
+		return f.clients.EC2
+	}
+	return EC2(ec2.New(f.sess, f.config(ServiceEC2)))
 }
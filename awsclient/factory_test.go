@@ -0,0 +1,33 @@
+package awsclient
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestNewFactoryEndpoints(t *testing.T) {
+	f, err := NewFactory(&Options{
+		Region:    "us-east-1",
+		Endpoint:  "http://localhost:4566",
+		Endpoints: map[string]string{ServiceEC2: "http://localhost:5000"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := f.EC2().(*ec2.EC2).Endpoint; e != "http://localhost:5000" {
+		t.Errorf("got EC2 endpoint %s, want http://localhost:5000", e)
+	}
+	if e := f.SSM().(*ssmClient).Endpoint; e != "http://localhost:4566" {
+		t.Errorf("got SSM endpoint %s, want http://localhost:4566", e)
+	}
+
+	f, err = NewFactory(&Options{Region: "us-east-1", Endpoint: "http://localhost:4566"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := f.EC2().(*ec2.EC2).Endpoint; e != "http://localhost:4566" {
+		t.Errorf("got EC2 endpoint %s, want http://localhost:4566", e)
+	}
+}
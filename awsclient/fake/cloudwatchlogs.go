@@ -0,0 +1,340 @@
+package fake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// CloudWatchLogs is a fake of awsclient.CloudWatchLogs.
+// Events are delivered to Kinesis Streams of subscription filters as gzipped JSON records.
+type CloudWatchLogs struct {
+	mutex   sync.Mutex
+	groups  map[string]*logGroup
+	kinesis *Kinesis
+	eventID int64
+}
+
+type logGroup struct {
+	retentionInDays *int64
+	streams         map[string][]*cloudwatchlogs.OutputLogEvent
+	filters         []*cloudwatchlogs.SubscriptionFilter
+}
+
+// subscriptionRecord is the data of a Kinesis record delivered by a subscription filter
+type subscriptionRecord struct {
+	MessageType         string              `json:"messageType"`
+	Owner               string              `json:"owner"`
+	LogGroup            string              `json:"logGroup"`
+	LogStream           string              `json:"logStream"`
+	SubscriptionFilters []string            `json:"subscriptionFilters"`
+	LogEvents           []subscriptionEvent `json:"logEvents"`
+}
+
+type subscriptionEvent struct {
+	ID        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+func NewCloudWatchLogs(k *Kinesis) *CloudWatchLogs {
+	return &CloudWatchLogs{
+		groups:  map[string]*logGroup{},
+		kinesis: k,
+	}
+}
+
+// PutLogEvents appends messages to a log stream, creating the stream if it does not exist
+func (c *CloudWatchLogs) PutLogEvents(group, stream string, t time.Time, messages ...string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	g, ok := c.groups[group]
+	if !ok {
+		return errorf(cloudwatchlogs.ErrCodeResourceNotFoundException, "The specified log group does not exist.")
+	}
+
+	timestamp := t.UnixNano() / int64(time.Millisecond)
+	events := []subscriptionEvent{}
+	for _, m := range messages {
+		c.eventID++
+		g.streams[stream] = append(g.streams[stream], &cloudwatchlogs.OutputLogEvent{
+			Message:       aws.String(m),
+			Timestamp:     aws.Int64(timestamp),
+			IngestionTime: aws.Int64(timestamp),
+		})
+		events = append(events, subscriptionEvent{
+			ID:        fmt.Sprintf("%d", c.eventID),
+			Timestamp: timestamp,
+			Message:   m,
+		})
+	}
+
+	for _, f := range g.filters {
+		if err := c.deliver(f, group, stream, events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CloudWatchLogs) deliver(f *cloudwatchlogs.SubscriptionFilter, group, stream string, events []subscriptionEvent) error {
+	matched := []subscriptionEvent{}
+	for _, e := range events {
+		if matchFilterPattern(*f.FilterPattern, e.Message) {
+			matched = append(matched, e)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	streamName, ok := streamNameFromARN(*f.DestinationArn)
+	if !ok {
+		return nil
+	}
+
+	b, err := json.Marshal(&subscriptionRecord{
+		MessageType:         "DATA_MESSAGE",
+		Owner:               AccountID,
+		LogGroup:            group,
+		LogStream:           stream,
+		SubscriptionFilters: []string{*f.FilterName},
+		LogEvents:           matched,
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.kinesis.PutRecord(streamName, stream, buf.Bytes())
+}
+
+// matchFilterPattern supports only an empty pattern and terms which all must be contained
+func matchFilterPattern(pattern, message string) bool {
+	for _, term := range strings.Fields(pattern) {
+		if !strings.Contains(message, strings.Trim(term, `"`)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *CloudWatchLogs) CreateLogGroup(input *cloudwatchlogs.CreateLogGroupInput) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	name := aws.StringValue(input.LogGroupName)
+	if _, ok := c.groups[name]; ok {
+		return nil, errorf(cloudwatchlogs.ErrCodeResourceAlreadyExistsException, "The specified log group already exists")
+	}
+	c.groups[name] = &logGroup{streams: map[string][]*cloudwatchlogs.OutputLogEvent{}}
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (c *CloudWatchLogs) DescribeLogGroupsPages(input *cloudwatchlogs.DescribeLogGroupsInput, fn func(*cloudwatchlogs.DescribeLogGroupsOutput, bool) bool) error {
+	c.mutex.Lock()
+	names := []string{}
+	for name := range c.groups {
+		if strings.HasPrefix(name, aws.StringValue(input.LogGroupNamePrefix)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	groups := []*cloudwatchlogs.LogGroup{}
+	for _, name := range names {
+		groups = append(groups, &cloudwatchlogs.LogGroup{
+			LogGroupName:    aws.String(name),
+			Arn:             aws.String(arn("logs", fmt.Sprintf("log-group:%s:*", name))),
+			RetentionInDays: c.groups[name].retentionInDays,
+		})
+	}
+	c.mutex.Unlock()
+
+	fn(&cloudwatchlogs.DescribeLogGroupsOutput{LogGroups: groups}, true)
+	return nil
+}
+
+func (c *CloudWatchLogs) PutRetentionPolicy(input *cloudwatchlogs.PutRetentionPolicyInput) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	g, err := c.group(input.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+	g.retentionInDays = input.RetentionInDays
+	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
+}
+
+func (c *CloudWatchLogs) DescribeSubscriptionFilters(input *cloudwatchlogs.DescribeSubscriptionFiltersInput) (*cloudwatchlogs.DescribeSubscriptionFiltersOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	g, err := c.group(input.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []*cloudwatchlogs.SubscriptionFilter{}
+	for _, f := range g.filters {
+		if strings.HasPrefix(*f.FilterName, aws.StringValue(input.FilterNamePrefix)) {
+			filters = append(filters, f)
+		}
+	}
+	return &cloudwatchlogs.DescribeSubscriptionFiltersOutput{SubscriptionFilters: filters}, nil
+}
+
+func (c *CloudWatchLogs) PutSubscriptionFilter(input *cloudwatchlogs.PutSubscriptionFilterInput) (*cloudwatchlogs.PutSubscriptionFilterOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	g, err := c.group(input.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := streamNameFromARN(aws.StringValue(input.DestinationArn)); !ok {
+		return nil, errorf(cloudwatchlogs.ErrCodeInvalidParameterException, "Could not deliver test message to specified destination.")
+	}
+
+	f := &cloudwatchlogs.SubscriptionFilter{
+		LogGroupName:   input.LogGroupName,
+		FilterName:     input.FilterName,
+		FilterPattern:  aws.String(aws.StringValue(input.FilterPattern)),
+		DestinationArn: input.DestinationArn,
+		RoleArn:        input.RoleArn,
+	}
+	for i, existing := range g.filters {
+		if *existing.FilterName == *f.FilterName {
+			g.filters[i] = f
+			return &cloudwatchlogs.PutSubscriptionFilterOutput{}, nil
+		}
+	}
+	if len(g.filters) >= 2 {
+		return nil, errorf(cloudwatchlogs.ErrCodeLimitExceededException, "Resource limit exceeded.")
+	}
+	g.filters = append(g.filters, f)
+	return &cloudwatchlogs.PutSubscriptionFilterOutput{}, nil
+}
+
+func (c *CloudWatchLogs) DescribeLogStreamsPages(input *cloudwatchlogs.DescribeLogStreamsInput, fn func(*cloudwatchlogs.DescribeLogStreamsOutput, bool) bool) error {
+	c.mutex.Lock()
+	g, err := c.group(input.LogGroupName)
+	if err != nil {
+		c.mutex.Unlock()
+		return err
+	}
+
+	names := []string{}
+	for name := range g.streams {
+		if strings.HasPrefix(name, aws.StringValue(input.LogStreamNamePrefix)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	streams := []*cloudwatchlogs.LogStream{}
+	for _, name := range names {
+		streams = append(streams, &cloudwatchlogs.LogStream{
+			LogStreamName: aws.String(name),
+		})
+	}
+	c.mutex.Unlock()
+
+	fn(&cloudwatchlogs.DescribeLogStreamsOutput{LogStreams: streams}, true)
+	return nil
+}
+
+// Forward tokens are "f/INDEX", where INDEX is of the next event in the stream.
+// The same token is returned at the end of the stream as CloudWatch Logs does.
+func (c *CloudWatchLogs) GetLogEvents(input *cloudwatchlogs.GetLogEventsInput) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	g, err := c.group(input.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+	all, ok := g.streams[aws.StringValue(input.LogStreamName)]
+	if !ok {
+		return nil, errorf(cloudwatchlogs.ErrCodeResourceNotFoundException, "The specified log stream does not exist.")
+	}
+
+	index := 0
+	if input.NextToken != nil {
+		index, err = strconv.Atoi(strings.TrimPrefix(*input.NextToken, "f/"))
+		if err != nil {
+			return nil, errorf(cloudwatchlogs.ErrCodeInvalidParameterException, "The specified nextToken is invalid.")
+		}
+	} else if input.StartTime != nil {
+		index = len(all)
+		for i, e := range all {
+			if *e.Timestamp >= *input.StartTime {
+				index = i
+				break
+			}
+		}
+	}
+
+	limit := 10000
+	if input.Limit != nil {
+		limit = int(*input.Limit)
+	}
+
+	events := []*cloudwatchlogs.OutputLogEvent{}
+	for index < len(all) && len(events) < limit {
+		e := all[index]
+		if input.EndTime != nil && *e.Timestamp >= *input.EndTime {
+			break
+		}
+		events = append(events, e)
+		index++
+	}
+
+	return &cloudwatchlogs.GetLogEventsOutput{
+		Events:            events,
+		NextForwardToken:  aws.String(fmt.Sprintf("f/%d", index)),
+		NextBackwardToken: aws.String("b/0"),
+	}, nil
+}
+
+func (c *CloudWatchLogs) GetLogEventsPages(input *cloudwatchlogs.GetLogEventsInput, fn func(*cloudwatchlogs.GetLogEventsOutput, bool) bool) error {
+	in := *input
+	for {
+		resp, err := c.GetLogEvents(&in)
+		if err != nil {
+			return err
+		}
+		last := in.NextToken != nil && *in.NextToken == *resp.NextForwardToken
+		if !fn(resp, last) || last {
+			return nil
+		}
+		in.NextToken = resp.NextForwardToken
+	}
+}
+
+func (c *CloudWatchLogs) group(name *string) (*logGroup, error) {
+	g, ok := c.groups[aws.StringValue(name)]
+	if !ok {
+		return nil, errorf(cloudwatchlogs.ErrCodeResourceNotFoundException, "The specified log group does not exist.")
+	}
+	return g, nil
+}
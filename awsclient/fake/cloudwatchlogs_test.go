@@ -0,0 +1,88 @@
+package fake
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/ryotarai/paramedic/outputlog"
+)
+
+func TestSubscriptionFilter(t *testing.T) {
+	a := New()
+	if _, err := a.CloudWatchLogs.CreateLogGroup(&cloudwatchlogs.CreateLogGroupInput{LogGroupName: aws.String("paramedic")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Kinesis.CreateStream(&kinesis.CreateStreamInput{StreamName: aws.String("logs"), ShardCount: aws.Int64(2)}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := a.CloudWatchLogs.PutSubscriptionFilter(&cloudwatchlogs.PutSubscriptionFilterInput{
+		LogGroupName:   aws.String("paramedic"),
+		FilterName:     aws.String("paramedic"),
+		FilterPattern:  aws.String(""),
+		DestinationArn: aws.String(arn("kinesis", "stream/logs")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	reader := &outputlog.KinesisReader{
+		Kinesis:         a.Kinesis,
+		StreamName:      "logs",
+		StartTimestamp:  start,
+		LogGroup:        "paramedic",
+		LogStreamPrefix: "pcommand/",
+	}
+	messages := func() []string {
+		events, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputlog.SortEventsByInstance(events)
+		ms := []string{}
+		for _, e := range events {
+			ms = append(ms, e.InstanceID()+" "+e.Message)
+		}
+		return ms
+	}
+
+	put := func(stream string, ms ...string) {
+		if err := a.CloudWatchLogs.PutLogEvents("paramedic", stream, start, ms...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	put("pcommand/i-aaa", "a1", "a2")
+	put("other/i-aaa", "ignored")
+	if got, want := messages(), []string{"i-aaa a1", "i-aaa a2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Records put after resharding are read from the child shards
+	if err := a.Kinesis.Reshard("logs"); err != nil {
+		t.Fatal(err)
+	}
+	put("pcommand/i-bbb", "b1")
+	put("pcommand/i-aaa", "a3")
+	messages() // parents are closed
+	if got, want := messages(), []string{"i-aaa a3", "i-bbb b1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The same events are stored in CloudWatch Logs
+	events, err := (&outputlog.CloudWatchLogsReader{
+		CloudWatchLogs:  a.CloudWatchLogs,
+		LogGroup:        "paramedic",
+		LogStreamPrefix: "pcommand/",
+	}).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Errorf("got %d events, want 4", len(events))
+	}
+}
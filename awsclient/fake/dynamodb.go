@@ -0,0 +1,542 @@
+package fake
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DynamoDB is a fake of awsclient.DynamoDB. Tables have a partition key only,
+// and global secondary indexes have a partition key and an optional sort key.
+type DynamoDB struct {
+	mutex  sync.Mutex
+	tables map[string]*dynamoDBTable
+}
+
+type dynamoDBTable struct {
+	name       string
+	hashKey    string
+	indexes    map[string]*dynamoDBIndex
+	items      map[string]item // map[partition key]item
+	billing    string
+	throughput *dynamodb.ProvisionedThroughput
+	ttlEnabled bool
+	ttlName    string
+}
+
+type dynamoDBIndex struct {
+	hashKey  string
+	rangeKey string
+}
+
+func NewDynamoDB() *DynamoDB {
+	return &DynamoDB{tables: map[string]*dynamoDBTable{}}
+}
+
+// Items returns all items of a table
+func (d *DynamoDB) Items(tableName string) []map[string]*dynamodb.AttributeValue {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	t, ok := d.tables[tableName]
+	if !ok {
+		return items
+	}
+	for _, it := range t.sortedItems(t.hashKey, "") {
+		items = append(items, copyItem(it))
+	}
+	return items
+}
+
+// BillingMode returns the billing mode of a table, which is PROVISIONED or PAY_PER_REQUEST
+func (d *DynamoDB) BillingMode(tableName string) string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	t, ok := d.tables[tableName]
+	if !ok {
+		return ""
+	}
+	return t.billing
+}
+
+func (d *DynamoDB) table(name *string) (*dynamoDBTable, error) {
+	t, ok := d.tables[aws.StringValue(name)]
+	if !ok {
+		return nil, errorf(dynamodb.ErrCodeResourceNotFoundException, "Requested resource not found: Table: %s not found", aws.StringValue(name))
+	}
+	return t, nil
+}
+
+func keySchema(elements []*dynamodb.KeySchemaElement) (string, string) {
+	hash, rng := "", ""
+	for _, e := range elements {
+		switch aws.StringValue(e.KeyType) {
+		case dynamodb.KeyTypeHash:
+			hash = aws.StringValue(e.AttributeName)
+		case dynamodb.KeyTypeRange:
+			rng = aws.StringValue(e.AttributeName)
+		}
+	}
+	return hash, rng
+}
+
+func (d *DynamoDB) CreateTable(input *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	name := aws.StringValue(input.TableName)
+	if _, ok := d.tables[name]; ok {
+		return nil, errorf(dynamodb.ErrCodeResourceInUseException, "Table already exists: %s", name)
+	}
+
+	hash, rng := keySchema(input.KeySchema)
+	if hash == "" || rng != "" {
+		return nil, validationError("The fake supports only tables with a partition key")
+	}
+
+	t := &dynamoDBTable{
+		name:    name,
+		hashKey: hash,
+		indexes: map[string]*dynamoDBIndex{},
+		items:   map[string]item{},
+		billing: "PROVISIONED",
+	}
+	// The on-demand mode is requested without throughput (see awsclient.dynamoDB)
+	if input.ProvisionedThroughput == nil {
+		t.billing = "PAY_PER_REQUEST"
+	} else {
+		t.throughput = input.ProvisionedThroughput
+	}
+	for _, i := range input.GlobalSecondaryIndexes {
+		hash, rng := keySchema(i.KeySchema)
+		t.indexes[aws.StringValue(i.IndexName)] = &dynamoDBIndex{hashKey: hash, rangeKey: rng}
+	}
+	d.tables[name] = t
+
+	return &dynamodb.CreateTableOutput{
+		TableDescription: &dynamodb.TableDescription{
+			TableName:   aws.String(name),
+			TableStatus: aws.String(dynamodb.TableStatusActive),
+		},
+	}, nil
+}
+
+func (d *DynamoDB) WaitUntilTableExists(input *dynamodb.DescribeTableInput) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	_, err := d.table(input.TableName)
+	return err
+}
+
+// DescribeTable describes a table. Tables and indexes are always ACTIVE.
+func (d *DynamoDB) DescribeTable(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// On-demand tables have zero throughput, as described by DynamoDB
+	throughput := &dynamodb.ProvisionedThroughputDescription{
+		ReadCapacityUnits:  aws.Int64(0),
+		WriteCapacityUnits: aws.Int64(0),
+	}
+	if t.throughput != nil {
+		throughput.ReadCapacityUnits = t.throughput.ReadCapacityUnits
+		throughput.WriteCapacityUnits = t.throughput.WriteCapacityUnits
+	}
+
+	names := []string{}
+	for name := range t.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	indexes := []*dynamodb.GlobalSecondaryIndexDescription{}
+	for _, name := range names {
+		indexes = append(indexes, &dynamodb.GlobalSecondaryIndexDescription{
+			IndexName:   aws.String(name),
+			IndexStatus: aws.String(dynamodb.IndexStatusActive),
+		})
+	}
+
+	return &dynamodb.DescribeTableOutput{
+		Table: &dynamodb.TableDescription{
+			TableName:              aws.String(t.name),
+			TableStatus:            aws.String(dynamodb.TableStatusActive),
+			ProvisionedThroughput:  throughput,
+			GlobalSecondaryIndexes: indexes,
+		},
+	}, nil
+}
+
+// UpdateTable supports only creating global secondary indexes
+func (d *DynamoDB) UpdateTable(input *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input.GlobalSecondaryIndexUpdates) > 1 {
+		return nil, validationError("Subscriber limit exceeded: Only 1 online index can be created or deleted simultaneously per table")
+	}
+	for _, u := range input.GlobalSecondaryIndexUpdates {
+		if u.Create == nil {
+			return nil, validationError("The fake supports only creating indexes")
+		}
+		name := aws.StringValue(u.Create.IndexName)
+		if _, ok := t.indexes[name]; ok {
+			return nil, validationError("Attempting to create an index which already exists")
+		}
+		if (t.throughput == nil) != (u.Create.ProvisionedThroughput == nil) {
+			return nil, validationError("ProvisionedThroughput of the index doesn't match the billing mode of the table")
+		}
+		hash, rng := keySchema(u.Create.KeySchema)
+		t.indexes[name] = &dynamoDBIndex{hashKey: hash, rangeKey: rng}
+	}
+	if input.ProvisionedThroughput != nil {
+		t.throughput = input.ProvisionedThroughput
+	}
+
+	return &dynamodb.UpdateTableOutput{
+		TableDescription: &dynamodb.TableDescription{
+			TableName:   aws.String(t.name),
+			TableStatus: aws.String(dynamodb.TableStatusUpdating),
+		},
+	}, nil
+}
+
+// UpdateTableToPayPerRequest supports only switching the billing mode
+func (d *DynamoDB) UpdateTableToPayPerRequest(input *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	t.billing = "PAY_PER_REQUEST"
+	t.throughput = nil
+
+	return &dynamodb.UpdateTableOutput{
+		TableDescription: &dynamodb.TableDescription{
+			TableName:   aws.String(t.name),
+			TableStatus: aws.String(dynamodb.TableStatusUpdating),
+		},
+	}, nil
+}
+
+func (d *DynamoDB) ListTablesPages(input *dynamodb.ListTablesInput, fn func(*dynamodb.ListTablesOutput, bool) bool) error {
+	d.mutex.Lock()
+	names := []string{}
+	for name := range d.tables {
+		names = append(names, name)
+	}
+	d.mutex.Unlock()
+
+	sort.Strings(names)
+	fn(&dynamodb.ListTablesOutput{TableNames: aws.StringSlice(names)}, true)
+	return nil
+}
+
+func (d *DynamoDB) UpdateTimeToLive(input *dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := input.TimeToLiveSpecification
+	enabled := aws.BoolValue(spec.Enabled)
+	if enabled && t.ttlEnabled {
+		return nil, validationError("TimeToLive is already enabled")
+	}
+	if !enabled && !t.ttlEnabled {
+		return nil, validationError("TimeToLive is already disabled")
+	}
+	t.ttlEnabled = enabled
+	t.ttlName = aws.StringValue(spec.AttributeName)
+	return &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: spec}, nil
+}
+
+func (d *DynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := input.Item[t.hashKey]
+	if !ok {
+		return nil, validationError("One of the required keys was not given a value")
+	}
+
+	cond, err := parseCondition(aws.StringValue(input.ConditionExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	existing := t.items[attributeString(key)]
+	if existing == nil {
+		existing = item{}
+	}
+	if !cond(existing) {
+		return nil, errorf(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed")
+	}
+
+	t.items[attributeString(key)] = copyItem(input.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (d *DynamoDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	it, ok := t.items[attributeString(input.Key[t.hashKey])]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	projected, err := projection(aws.StringValue(input.ProjectionExpression), input.ExpressionAttributeNames, it)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.GetItemOutput{Item: copyItem(projected)}, nil
+}
+
+// DeleteItem is not a part of awsclient.DynamoDB, but is used to expire items in tests
+func (d *DynamoDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	delete(t.items, attributeString(input.Key[t.hashKey]))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (d *DynamoDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := input.Key[t.hashKey]
+	if !ok {
+		return nil, validationError("The provided key element does not match the schema")
+	}
+
+	cond, err := parseCondition(aws.StringValue(input.ConditionExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	upd, err := parseUpdate(aws.StringValue(input.UpdateExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, ok := t.items[attributeString(key)]
+	if !ok {
+		existing = item{}
+	}
+	if !cond(existing) {
+		return nil, errorf(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed")
+	}
+
+	updated := copyItem(existing)
+	updated[t.hashKey] = key
+	if err := upd(updated); err != nil {
+		return nil, err
+	}
+	t.items[attributeString(key)] = updated
+
+	out := &dynamodb.UpdateItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllNew {
+		out.Attributes = copyItem(updated)
+	}
+	return out, nil
+}
+
+func (d *DynamoDB) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, rng := t.hashKey, ""
+	if input.IndexName != nil {
+		i, ok := t.indexes[*input.IndexName]
+		if !ok {
+			return nil, validationError("The table does not have the specified index: %s", *input.IndexName)
+		}
+		hash, rng = i.hashKey, i.rangeKey
+	}
+
+	keyCond, err := parseCondition(aws.StringValue(input.KeyConditionExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	items := []item{}
+	for _, it := range t.sortedItems(hash, rng) {
+		if keyCond(it) {
+			items = append(items, it)
+		}
+	}
+	if !aws.BoolValue(input.ScanIndexForward) && input.ScanIndexForward != nil {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	resp, err := t.page(items, input.ExclusiveStartKey, input.Limit, aws.StringValue(input.FilterExpression), aws.StringValue(input.ProjectionExpression),
+		input.ExpressionAttributeNames, input.ExpressionAttributeValues, hash, rng)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.QueryOutput{
+		Items:            resp.Items,
+		Count:            resp.Count,
+		ScannedCount:     resp.ScannedCount,
+		LastEvaluatedKey: resp.LastEvaluatedKey,
+	}, nil
+}
+
+func (d *DynamoDB) ScanPages(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool) error {
+	in := *input
+	for {
+		d.mutex.Lock()
+		t, err := d.table(in.TableName)
+		if err != nil {
+			d.mutex.Unlock()
+			return err
+		}
+		hash, rng := t.hashKey, ""
+		if in.IndexName != nil {
+			i, ok := t.indexes[*in.IndexName]
+			if !ok {
+				d.mutex.Unlock()
+				return validationError("The table does not have the specified index: %s", *in.IndexName)
+			}
+			hash, rng = i.hashKey, i.rangeKey
+		}
+		resp, err := t.page(t.sortedItems(hash, rng), in.ExclusiveStartKey, in.Limit, aws.StringValue(in.FilterExpression), aws.StringValue(in.ProjectionExpression),
+			in.ExpressionAttributeNames, in.ExpressionAttributeValues, hash, rng)
+		d.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+
+		last := len(resp.LastEvaluatedKey) == 0
+		if !fn(resp, last) || last {
+			return nil
+		}
+		in.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// sortedItems returns items which have the keys, in the order of the sort key (or the partition key)
+func (t *dynamoDBTable) sortedItems(hash, rng string) []item {
+	items := []item{}
+	for _, it := range t.items {
+		if _, ok := it[hash]; !ok {
+			continue
+		}
+		if _, ok := it[rng]; rng != "" && !ok {
+			continue
+		}
+		items = append(items, it)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if rng != "" {
+			if c, ok := compare(items[i][rng], items[j][rng]); ok && c != 0 {
+				return c < 0
+			}
+		}
+		return attributeString(items[i][t.hashKey]) < attributeString(items[j][t.hashKey])
+	})
+	return items
+}
+
+// page evaluates items after exclusiveStartKey up to limit, and returns ones matching the filter
+func (t *dynamoDBTable) page(items []item, exclusiveStartKey map[string]*dynamodb.AttributeValue, limit *int64, filter, proj string,
+	names map[string]*string, values map[string]*dynamodb.AttributeValue, hash, rng string) (*dynamodb.ScanOutput, error) {
+
+	cond, err := parseCondition(filter, names, values)
+	if err != nil {
+		return nil, err
+	}
+
+	start := 0
+	if len(exclusiveStartKey) > 0 {
+		key := attributeString(exclusiveStartKey[t.hashKey])
+		for i, it := range items {
+			if attributeString(it[t.hashKey]) == key {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	end := len(items)
+	if limit != nil && start+int(*limit) < end {
+		end = start + int(*limit)
+	}
+
+	out := &dynamodb.ScanOutput{
+		Items:        []map[string]*dynamodb.AttributeValue{},
+		ScannedCount: aws.Int64(int64(end - start)),
+	}
+	for _, it := range items[start:end] {
+		if !cond(it) {
+			continue
+		}
+		projected, err := projection(proj, names, it)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, copyItem(projected))
+	}
+	out.Count = aws.Int64(int64(len(out.Items)))
+
+	if end < len(items) {
+		last := items[end-1]
+		out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{}
+		for _, k := range []string{t.hashKey, hash, rng} {
+			if v, ok := last[k]; ok {
+				out.LastEvaluatedKey[k] = v
+			}
+		}
+	}
+	return out, nil
+}
+
+func copyItem(it map[string]*dynamodb.AttributeValue) item {
+	c := item{}
+	for k, v := range it {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,179 @@
+package fake
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestCondition(t *testing.T) {
+	it := item{
+		"CommandID": {S: aws.String("c1")},
+		"Status":    {S: aws.String("Success")},
+		"StartedAt": {N: aws.String("100")},
+		"Tags":      {L: []*dynamodb.AttributeValue{{S: aws.String("Role=app")}}},
+	}
+	names := map[string]*string{"#status": aws.String("Status")}
+	values := map[string]*dynamodb.AttributeValue{
+		":status": {S: aws.String("Success")},
+		":since":  {N: aws.String("99")},
+		":tag":    {S: aws.String("Role=app")},
+		":prefix": {S: aws.String("c")},
+	}
+
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"#status = :status", true},
+		{"#status <> :status", false},
+		{"StartedAt >= :since AND contains(Tags, :tag)", true},
+		{"StartedAt < :since OR begins_with(CommandID, :prefix)", true},
+		{"NOT (attribute_exists(RolloutID) OR attribute_not_exists(CommandID))", true},
+		{"attribute_exists(RolloutID)", false},
+	}
+	for _, c := range cases {
+		cond, err := parseCondition(c.expr, names, values)
+		if err != nil {
+			t.Errorf("%s: %s", c.expr, err)
+			continue
+		}
+		if got := cond(it); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.expr, got, c.want)
+		}
+	}
+
+	for _, expr := range []string{"#undefined = :status", "Status = :undefined", "Status ==", "contains(Status"} {
+		if _, err := parseCondition(expr, names, values); err == nil {
+			t.Errorf("%s: no error", expr)
+		}
+	}
+}
+
+func TestUpdateItem(t *testing.T) {
+	d := NewDynamoDB()
+	_, err := d.CreateTable(&dynamodb.CreateTableInput{
+		TableName: aws.String("t"),
+		KeySchema: []*dynamodb.KeySchemaElement{{AttributeName: aws.String("ID"), KeyType: aws.String("HASH")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := map[string]*dynamodb.AttributeValue{"ID": {S: aws.String("a")}}
+	update := func(cond, expr string) error {
+		input := &dynamodb.UpdateItemInput{
+			TableName:        aws.String("t"),
+			Key:              key,
+			UpdateExpression: aws.String(expr),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":empty": {L: []*dynamodb.AttributeValue{}},
+				":ids":   {L: []*dynamodb.AttributeValue{{S: aws.String("x")}}},
+				":one":   {N: aws.String("1")},
+			},
+		}
+		if cond != "" {
+			input.ConditionExpression = aws.String(cond)
+		}
+		_, err := d.UpdateItem(input)
+		return err
+	}
+
+	err = update("attribute_exists(ID)", "SET N = :one")
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+		t.Errorf("got %v, want ConditionalCheckFailedException", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := update("", "SET IDs = list_append(if_not_exists(IDs, :empty), :ids), N = if_not_exists(N, :one) + :one"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := d.Items("t")
+	want := []map[string]*dynamodb.AttributeValue{{
+		"ID":  {S: aws.String("a")},
+		"IDs": {L: []*dynamodb.AttributeValue{{S: aws.String("x")}, {S: aws.String("x")}}},
+		"N":   {N: aws.String("3")},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := update("", "REMOVE IDs, N"); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Items("t")[0]; len(got) != 1 {
+		t.Errorf("got %v, want only the key", got)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	d := NewDynamoDB()
+	_, err := d.CreateTable(&dynamodb.CreateTableInput{
+		TableName: aws.String("t"),
+		KeySchema: []*dynamodb.KeySchemaElement{{AttributeName: aws.String("ID"), KeyType: aws.String("HASH")}},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{{
+			IndexName: aws.String("Type-At-index"),
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("Type"), KeyType: aws.String("HASH")},
+				{AttributeName: aws.String("At"), KeyType: aws.String("RANGE")},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []struct{ id, typ, at string }{
+		{"a", "x", "3"}, {"b", "x", "1"}, {"c", "y", "2"}, {"d", "x", "10"},
+	} {
+		_, err := d.PutItem(&dynamodb.PutItemInput{
+			TableName: aws.String("t"),
+			Item: map[string]*dynamodb.AttributeValue{
+				"ID":   {S: aws.String(i.id)},
+				"Type": {S: aws.String(i.typ)},
+				"At":   {N: aws.String(i.at)},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := []string{}
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String("t"),
+		IndexName:              aws.String("Type-At-index"),
+		KeyConditionExpression: aws.String("#type = :type AND At >= :at"),
+		ExpressionAttributeNames: map[string]*string{
+			"#type": aws.String("Type"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":type": {S: aws.String("x")},
+			":at":   {N: aws.String("2")},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(1),
+	}
+	for {
+		resp, err := d.Query(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, it := range resp.Items {
+			ids = append(ids, *it["ID"].S)
+		}
+		if len(resp.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+
+	if want := []string{"d", "a"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+}
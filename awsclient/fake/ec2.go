@@ -0,0 +1,62 @@
+package fake
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// EC2 is a fake of awsclient.EC2, which serves tags of instances registered to SSM
+type EC2 struct {
+	ssm *SSM
+}
+
+func NewEC2(s *SSM) *EC2 {
+	return &EC2{ssm: s}
+}
+
+// DescribeTagsPages supports resource-id, resource-type and key filters
+func (e *EC2) DescribeTagsPages(input *ec2.DescribeTagsInput, fn func(*ec2.DescribeTagsOutput, bool) bool) error {
+	e.ssm.mutex.Lock()
+	tags := []*ec2.TagDescription{}
+	for _, i := range e.ssm.sortedInstances() {
+		if !strings.HasPrefix(i.ID, "i-") {
+			// managed instances which are not EC2 instances
+			continue
+		}
+
+		keys := []string{}
+		for k := range i.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if !matchEC2Filters(input.Filters, map[string]string{"resource-id": i.ID, "resource-type": ec2.ResourceTypeInstance, "key": k}) {
+				continue
+			}
+			tags = append(tags, &ec2.TagDescription{
+				ResourceId:   aws.String(i.ID),
+				ResourceType: aws.String(ec2.ResourceTypeInstance),
+				Key:          aws.String(k),
+				Value:        aws.String(i.Tags[k]),
+			})
+		}
+	}
+	e.ssm.mutex.Unlock()
+
+	fn(&ec2.DescribeTagsOutput{Tags: tags}, true)
+	return nil
+}
+
+func matchEC2Filters(filters []*ec2.Filter, attributes map[string]string) bool {
+	for _, f := range filters {
+		v, ok := attributes[aws.StringValue(f.Name)]
+		if ok && !containsString(aws.StringValueSlice(f.Values), v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,544 @@
+package fake
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// This file implements a subset of DynamoDB expressions: conditions with comparisons, AND, OR, NOT,
+// attribute_exists, attribute_not_exists, contains and begins_with, and updates with SET
+// (including if_not_exists, list_append, + and -) and REMOVE of top-level attributes.
+
+type item map[string]*dynamodb.AttributeValue
+
+type expression struct {
+	tokens []string
+	pos    int
+	names  map[string]*string
+	values map[string]*dynamodb.AttributeValue
+}
+
+func newExpression(s string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (*expression, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	return &expression{tokens: tokens, names: names, values: values}, nil
+}
+
+func tokenize(s string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("(),+-", c):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '=':
+			tokens = append(tokens, "=")
+			i++
+		case c == '<' || c == '>':
+			if i+1 < len(s) && (s[i+1] == '=' || (c == '<' && s[i+1] == '>')) {
+				tokens = append(tokens, s[i:i+2])
+				i += 2
+			} else {
+				tokens = append(tokens, string(c))
+				i++
+			}
+		case c == '#' || c == ':' || c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			return nil, validationError("Invalid character '%c' in expression: %s", c, s)
+		}
+	}
+	return tokens, nil
+}
+
+func validationError(format string, a ...interface{}) error {
+	return errorf("ValidationException", format, a...)
+}
+
+func (e *expression) peek() string {
+	if e.pos >= len(e.tokens) {
+		return ""
+	}
+	return e.tokens[e.pos]
+}
+
+func (e *expression) next() string {
+	t := e.peek()
+	e.pos++
+	return t
+}
+
+func (e *expression) expect(t string) error {
+	if got := e.next(); got != t {
+		return validationError("Syntax error in expression: '%s' is expected, but got '%s'", t, got)
+	}
+	return nil
+}
+
+func (e *expression) done() error {
+	if e.pos < len(e.tokens) {
+		return validationError("Syntax error in expression: unexpected token '%s'", e.peek())
+	}
+	return nil
+}
+
+// name resolves an attribute name, which may be a placeholder (#name)
+func (e *expression) name(t string) (string, error) {
+	if strings.HasPrefix(t, "#") {
+		n, ok := e.names[t]
+		if !ok {
+			return "", validationError("An expression attribute name used in the document path is not defined; attribute name: %s", t)
+		}
+		return *n, nil
+	}
+	if t == "" || !(t[0] == '_' || unicode.IsLetter(rune(t[0]))) {
+		return "", validationError("Syntax error in expression: attribute name is expected, but got '%s'", t)
+	}
+	return t, nil
+}
+
+// operand is a value in an item or a placeholder of a value
+type operand func(it item) *dynamodb.AttributeValue
+
+func (e *expression) operand() (operand, error) {
+	t := e.next()
+	if strings.HasPrefix(t, ":") {
+		v, ok := e.values[t]
+		if !ok {
+			return nil, validationError("An expression attribute value used in expression is not defined; attribute value: %s", t)
+		}
+		return func(item) *dynamodb.AttributeValue { return v }, nil
+	}
+
+	switch t {
+	case "if_not_exists", "list_append":
+		return e.function(t)
+	}
+
+	name, err := e.name(t)
+	if err != nil {
+		return nil, err
+	}
+	return func(it item) *dynamodb.AttributeValue { return it[name] }, nil
+}
+
+func (e *expression) function(f string) (operand, error) {
+	if err := e.expect("("); err != nil {
+		return nil, err
+	}
+	a, err := e.operand()
+	if err != nil {
+		return nil, err
+	}
+	if err := e.expect(","); err != nil {
+		return nil, err
+	}
+	b, err := e.operand()
+	if err != nil {
+		return nil, err
+	}
+	if err := e.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if f == "if_not_exists" {
+		return func(it item) *dynamodb.AttributeValue {
+			if v := a(it); v != nil {
+				return v
+			}
+			return b(it)
+		}, nil
+	}
+	return func(it item) *dynamodb.AttributeValue {
+		l := []*dynamodb.AttributeValue{}
+		if v := a(it); v != nil {
+			l = append(l, v.L...)
+		}
+		if v := b(it); v != nil {
+			l = append(l, v.L...)
+		}
+		return &dynamodb.AttributeValue{L: l}
+	}, nil
+}
+
+// condition is a parsed condition expression
+type condition func(it item) bool
+
+func parseCondition(s string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (condition, error) {
+	if s == "" {
+		return func(item) bool { return true }, nil
+	}
+	e, err := newExpression(s, names, values)
+	if err != nil {
+		return nil, err
+	}
+	c, err := e.or()
+	if err != nil {
+		return nil, err
+	}
+	return c, e.done()
+}
+
+func (e *expression) or() (condition, error) {
+	a, err := e.and()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(e.peek(), "OR") {
+		e.next()
+		b, err := e.and()
+		if err != nil {
+			return nil, err
+		}
+		a = func(a, b condition) condition {
+			return func(it item) bool { return a(it) || b(it) }
+		}(a, b)
+	}
+	return a, nil
+}
+
+func (e *expression) and() (condition, error) {
+	a, err := e.not()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(e.peek(), "AND") {
+		e.next()
+		b, err := e.not()
+		if err != nil {
+			return nil, err
+		}
+		a = func(a, b condition) condition {
+			return func(it item) bool { return a(it) && b(it) }
+		}(a, b)
+	}
+	return a, nil
+}
+
+func (e *expression) not() (condition, error) {
+	if strings.EqualFold(e.peek(), "NOT") {
+		e.next()
+		c, err := e.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(it item) bool { return !c(it) }, nil
+	}
+	return e.primary()
+}
+
+func (e *expression) primary() (condition, error) {
+	t := e.peek()
+	switch t {
+	case "(":
+		e.next()
+		c, err := e.or()
+		if err != nil {
+			return nil, err
+		}
+		return c, e.expect(")")
+	case "attribute_exists", "attribute_not_exists":
+		e.next()
+		if err := e.expect("("); err != nil {
+			return nil, err
+		}
+		name, err := e.name(e.next())
+		if err != nil {
+			return nil, err
+		}
+		if err := e.expect(")"); err != nil {
+			return nil, err
+		}
+		exists := t == "attribute_exists"
+		return func(it item) bool {
+			_, ok := it[name]
+			return ok == exists
+		}, nil
+	case "contains", "begins_with":
+		e.next()
+		if err := e.expect("("); err != nil {
+			return nil, err
+		}
+		a, err := e.operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := e.expect(","); err != nil {
+			return nil, err
+		}
+		b, err := e.operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := e.expect(")"); err != nil {
+			return nil, err
+		}
+		if t == "contains" {
+			return func(it item) bool { return contains(a(it), b(it)) }, nil
+		}
+		return func(it item) bool {
+			x, y := a(it), b(it)
+			return x != nil && y != nil && x.S != nil && y.S != nil && strings.HasPrefix(*x.S, *y.S)
+		}, nil
+	}
+
+	a, err := e.operand()
+	if err != nil {
+		return nil, err
+	}
+	op := e.next()
+	switch op {
+	case "=", "<>", "<", "<=", ">", ">=":
+	default:
+		return nil, validationError("Syntax error in expression: comparator is expected, but got '%s'", op)
+	}
+	b, err := e.operand()
+	if err != nil {
+		return nil, err
+	}
+	return func(it item) bool { return compareOp(a(it), op, b(it)) }, nil
+}
+
+func compareOp(a *dynamodb.AttributeValue, op string, b *dynamodb.AttributeValue) bool {
+	if a == nil || b == nil {
+		return op == "<>" && (a == nil) != (b == nil)
+	}
+	switch op {
+	case "=":
+		return equal(a, b)
+	case "<>":
+		return !equal(a, b)
+	}
+
+	c, ok := compare(a, b)
+	if !ok {
+		return false
+	}
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+func equal(a, b *dynamodb.AttributeValue) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare compares two strings or numbers
+func compare(a, b *dynamodb.AttributeValue) (int, bool) {
+	switch {
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S), true
+	case a.N != nil && b.N != nil:
+		x, err1 := strconv.ParseFloat(*a.N, 64)
+		y, err2 := strconv.ParseFloat(*b.N, 64)
+		if err1 != nil || err2 != nil {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func contains(a, b *dynamodb.AttributeValue) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	switch {
+	case a.S != nil && b.S != nil:
+		return strings.Contains(*a.S, *b.S)
+	case a.SS != nil && b.S != nil:
+		for _, s := range a.SS {
+			if *s == *b.S {
+				return true
+			}
+		}
+	case a.NS != nil && b.N != nil:
+		for _, n := range a.NS {
+			if equal(&dynamodb.AttributeValue{N: n}, b) {
+				return true
+			}
+		}
+	case a.L != nil:
+		for _, v := range a.L {
+			if equal(v, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// update is a parsed update expression
+type update func(it item) error
+
+func parseUpdate(s string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (update, error) {
+	e, err := newExpression(s, names, values)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := []update{}
+	for e.peek() != "" {
+		switch action := strings.ToUpper(e.next()); action {
+		case "SET":
+			for {
+				u, err := e.assignment()
+				if err != nil {
+					return nil, err
+				}
+				updates = append(updates, u)
+				if e.peek() != "," {
+					break
+				}
+				e.next()
+			}
+		case "REMOVE":
+			for {
+				name, err := e.name(e.next())
+				if err != nil {
+					return nil, err
+				}
+				updates = append(updates, func(it item) error {
+					delete(it, name)
+					return nil
+				})
+				if e.peek() != "," {
+					break
+				}
+				e.next()
+			}
+		default:
+			return nil, validationError("Syntax error in update expression: '%s' is not supported", action)
+		}
+	}
+
+	return func(it item) error {
+		// Operands refer to the item before the update
+		before := item{}
+		for k, v := range it {
+			before[k] = v
+		}
+		for _, u := range updates {
+			if err := u(before); err != nil {
+				return err
+			}
+		}
+		for k := range it {
+			delete(it, k)
+		}
+		for k, v := range before {
+			it[k] = v
+		}
+		return nil
+	}, nil
+}
+
+func (e *expression) assignment() (update, error) {
+	name, err := e.name(e.next())
+	if err != nil {
+		return nil, err
+	}
+	if err := e.expect("="); err != nil {
+		return nil, err
+	}
+	a, err := e.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	op := e.peek()
+	if op != "+" && op != "-" {
+		return func(it item) error {
+			v := a(it)
+			if v == nil {
+				return validationError("The provided expression refers to an attribute that does not exist in the item")
+			}
+			it[name] = v
+			return nil
+		}, nil
+	}
+
+	e.next()
+	b, err := e.operand()
+	if err != nil {
+		return nil, err
+	}
+	return func(it item) error {
+		x, y := a(it), b(it)
+		if x == nil || y == nil || x.N == nil || y.N == nil {
+			return validationError("An operand in the update expression has an incorrect data type")
+		}
+		m, err1 := strconv.ParseFloat(*x.N, 64)
+		n, err2 := strconv.ParseFloat(*y.N, 64)
+		if err1 != nil || err2 != nil {
+			return validationError("An operand in the update expression has an incorrect data type")
+		}
+		if op == "-" {
+			n = -n
+		}
+		it[name] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(m+n, 'f', -1, 64))}
+		return nil
+	}, nil
+}
+
+// projection returns an item with only the attributes in a projection expression
+func projection(s string, names map[string]*string, it item) (item, error) {
+	if s == "" {
+		return it, nil
+	}
+	projected := item{}
+	for _, t := range strings.Split(s, ",") {
+		e := &expression{names: names}
+		name, err := e.name(strings.TrimSpace(t))
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := it[name]; ok {
+			projected[name] = v
+		}
+	}
+	return projected, nil
+}
+
+func attributeString(v *dynamodb.AttributeValue) string {
+	switch {
+	case v == nil:
+		return ""
+	case v.S != nil:
+		return *v.S
+	case v.N != nil:
+		return *v.N
+	}
+	return fmt.Sprintf("%v", v)
+}
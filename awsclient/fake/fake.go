@@ -0,0 +1,72 @@
+// Package fake implements in-memory fakes of the awsclient interfaces,
+// so that paramedic can be run entirely offline in tests.
+package fake
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/ryotarai/paramedic/awsclient"
+)
+
+const (
+	// Region is the region of the fake account
+	Region = "us-east-1"
+	// AccountID is the ID of the fake account
+	AccountID = "123456789012"
+)
+
+// AWS is a fake AWS account. Its services are wired together like the real ones:
+// invocations of SSM write output logs to CloudWatch Logs, which delivers them to
+// Kinesis Streams through subscription filters, and the agent is cancelled by signal objects in S3.
+type AWS struct {
+	SSM            *SSM
+	S3             *S3
+	DynamoDB       *DynamoDB
+	CloudWatchLogs *CloudWatchLogs
+	Kinesis        *Kinesis
+	STS            *STS
+	IAM            *IAM
+	EC2            *EC2
+}
+
+// New returns an empty account
+func New() *AWS {
+	k := NewKinesis()
+	logs := NewCloudWatchLogs(k)
+	s3 := NewS3()
+	s := NewSSM(s3, logs)
+	return &AWS{
+		SSM:            s,
+		S3:             s3,
+		DynamoDB:       NewDynamoDB(),
+		CloudWatchLogs: logs,
+		Kinesis:        k,
+		STS:            NewSTS(),
+		IAM:            NewIAM(),
+		EC2:            NewEC2(s),
+	}
+}
+
+// Factory returns a factory of clients of the account
+func (a *AWS) Factory() *awsclient.Factory {
+	return awsclient.NewFactoryWithClients(&awsclient.Clients{
+		Region:         Region,
+		SSM:            a.SSM,
+		S3:             a.S3,
+		DynamoDB:       a.DynamoDB,
+		CloudWatchLogs: a.CloudWatchLogs,
+		Kinesis:        a.Kinesis,
+		STS:            a.STS,
+		IAM:            a.IAM,
+		EC2:            a.EC2,
+	})
+}
+
+func arn(service, resource string) string {
+	return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, Region, AccountID, resource)
+}
+
+func errorf(code, format string, a ...interface{}) error {
+	return awserr.New(code, fmt.Sprintf(format, a...), nil)
+}
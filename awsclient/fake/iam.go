@@ -0,0 +1,79 @@
+package fake
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// IAM is a fake of awsclient.IAM
+type IAM struct {
+	mutex sync.Mutex
+	roles map[string]*iamRole
+}
+
+type iamRole struct {
+	role     *iam.Role
+	policies map[string]string // map[policy name]policy document
+}
+
+func NewIAM() *IAM {
+	return &IAM{roles: map[string]*iamRole{}}
+}
+
+// RolePolicy returns an inline policy of a role
+func (m *IAM) RolePolicy(roleName, policyName string) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r, ok := m.roles[roleName]
+	if !ok {
+		return "", false
+	}
+	p, ok := r.policies[policyName]
+	return p, ok
+}
+
+func (m *IAM) GetRole(input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r, ok := m.roles[aws.StringValue(input.RoleName)]
+	if !ok {
+		return nil, errorf(iam.ErrCodeNoSuchEntityException, "The role with name %s cannot be found.", aws.StringValue(input.RoleName))
+	}
+	return &iam.GetRoleOutput{Role: r.role}, nil
+}
+
+func (m *IAM) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(input.RoleName)
+	if _, ok := m.roles[name]; ok {
+		return nil, errorf(iam.ErrCodeEntityAlreadyExistsException, "Role with name %s already exists.", name)
+	}
+
+	role := &iam.Role{
+		RoleName:                 aws.String(name),
+		Arn:                      aws.String(fmt.Sprintf("arn:aws:iam::%s:role/%s", AccountID, name)),
+		AssumeRolePolicyDocument: input.AssumeRolePolicyDocument,
+		Description:              input.Description,
+	}
+	m.roles[name] = &iamRole{role: role, policies: map[string]string{}}
+	return &iam.CreateRoleOutput{Role: role}, nil
+}
+
+func (m *IAM) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r, ok := m.roles[aws.StringValue(input.RoleName)]
+	if !ok {
+		return nil, errorf(iam.ErrCodeNoSuchEntityException, "The role with name %s cannot be found.", aws.StringValue(input.RoleName))
+	}
+	r.policies[aws.StringValue(input.PolicyName)] = aws.StringValue(input.PolicyDocument)
+	return &iam.PutRolePolicyOutput{}, nil
+}
@@ -0,0 +1,277 @@
+package fake
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// Kinesis is a fake of awsclient.Kinesis
+type Kinesis struct {
+	mutex          sync.Mutex
+	streams        map[string]*kinesisStream
+	sequenceNumber int64
+	now            func() time.Time
+}
+
+type kinesisStream struct {
+	name   string
+	shards []*kinesisShard
+}
+
+type kinesisShard struct {
+	id       string
+	parentID string
+	closed   bool
+	records  []*kinesis.Record
+}
+
+func NewKinesis() *Kinesis {
+	return &Kinesis{
+		streams: map[string]*kinesisStream{},
+		now:     time.Now,
+	}
+}
+
+// PutRecord puts a record to a shard chosen by the partition key
+func (k *Kinesis) PutRecord(streamName, partitionKey string, data []byte) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	s, ok := k.streams[streamName]
+	if !ok {
+		return errorf(kinesis.ErrCodeResourceNotFoundException, "Stream %s under account %s not found.", streamName, AccountID)
+	}
+
+	open := []*kinesisShard{}
+	for _, sh := range s.shards {
+		if !sh.closed {
+			open = append(open, sh)
+		}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(partitionKey))
+	shard := open[int(h.Sum32())%len(open)]
+
+	k.sequenceNumber++
+	shard.records = append(shard.records, &kinesis.Record{
+		Data:                        data,
+		PartitionKey:                aws.String(partitionKey),
+		SequenceNumber:              aws.String(fmt.Sprintf("%056d", k.sequenceNumber)),
+		ApproximateArrivalTimestamp: aws.Time(k.now()),
+	})
+	return nil
+}
+
+// Reshard closes all open shards of a stream and creates a child shard for each of them
+func (k *Kinesis) Reshard(streamName string) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	s, ok := k.streams[streamName]
+	if !ok {
+		return errorf(kinesis.ErrCodeResourceNotFoundException, "Stream %s under account %s not found.", streamName, AccountID)
+	}
+
+	for _, sh := range s.shards {
+		if sh.closed {
+			continue
+		}
+		sh.closed = true
+		s.shards = append(s.shards, &kinesisShard{
+			id:       shardID(len(s.shards)),
+			parentID: sh.id,
+		})
+	}
+	return nil
+}
+
+func shardID(n int) string {
+	return fmt.Sprintf("shardId-%012d", n)
+}
+
+func (k *Kinesis) CreateStream(input *kinesis.CreateStreamInput) (*kinesis.CreateStreamOutput, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	name := aws.StringValue(input.StreamName)
+	if _, ok := k.streams[name]; ok {
+		return nil, errorf(kinesis.ErrCodeResourceInUseException, "Stream %s under account %s already exists.", name, AccountID)
+	}
+
+	s := &kinesisStream{name: name}
+	for i := 0; i < int(aws.Int64Value(input.ShardCount)); i++ {
+		s.shards = append(s.shards, &kinesisShard{id: shardID(i)})
+	}
+	if len(s.shards) == 0 {
+		return nil, errorf(kinesis.ErrCodeInvalidArgumentException, "ShardCount must be positive")
+	}
+	k.streams[name] = s
+	return &kinesis.CreateStreamOutput{}, nil
+}
+
+func (k *Kinesis) WaitUntilStreamExists(input *kinesis.DescribeStreamInput) error {
+	_, err := k.DescribeStream(input)
+	return err
+}
+
+func (k *Kinesis) DescribeStream(input *kinesis.DescribeStreamInput) (*kinesis.DescribeStreamOutput, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	name := aws.StringValue(input.StreamName)
+	s, ok := k.streams[name]
+	if !ok {
+		return nil, errorf(kinesis.ErrCodeResourceNotFoundException, "Stream %s under account %s not found.", name, AccountID)
+	}
+
+	limit := 100
+	if input.Limit != nil {
+		limit = int(*input.Limit)
+	}
+
+	shards := []*kinesis.Shard{}
+	started := input.ExclusiveStartShardId == nil
+	hasMore := false
+	for _, sh := range s.shards {
+		if !started {
+			started = sh.id == *input.ExclusiveStartShardId
+			continue
+		}
+		if len(shards) >= limit {
+			hasMore = true
+			break
+		}
+		shard := &kinesis.Shard{
+			ShardId: aws.String(sh.id),
+			SequenceNumberRange: &kinesis.SequenceNumberRange{
+				StartingSequenceNumber: aws.String(fmt.Sprintf("%056d", 0)),
+			},
+		}
+		if sh.parentID != "" {
+			shard.ParentShardId = aws.String(sh.parentID)
+		}
+		shards = append(shards, shard)
+	}
+
+	return &kinesis.DescribeStreamOutput{
+		StreamDescription: &kinesis.StreamDescription{
+			StreamName:    aws.String(name),
+			StreamARN:     aws.String(arn("kinesis", "stream/"+name)),
+			StreamStatus:  aws.String(kinesis.StreamStatusActive),
+			Shards:        shards,
+			HasMoreShards: aws.Bool(hasMore),
+		},
+	}, nil
+}
+
+// Iterators are "STREAM/SHARD_ID/INDEX", where INDEX is of the next record in the shard
+func (k *Kinesis) GetShardIterator(input *kinesis.GetShardIteratorInput) (*kinesis.GetShardIteratorOutput, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	s, sh, err := k.shard(aws.StringValue(input.StreamName), aws.StringValue(input.ShardId))
+	if err != nil {
+		return nil, err
+	}
+
+	index := 0
+	switch t := aws.StringValue(input.ShardIteratorType); t {
+	case "TRIM_HORIZON":
+	case "LATEST":
+		index = len(sh.records)
+	case "AT_TIMESTAMP":
+		index = len(sh.records)
+		for i, r := range sh.records {
+			if !r.ApproximateArrivalTimestamp.Before(aws.TimeValue(input.Timestamp)) {
+				index = i
+				break
+			}
+		}
+	case "AT_SEQUENCE_NUMBER", "AFTER_SEQUENCE_NUMBER":
+		index = -1
+		for i, r := range sh.records {
+			if *r.SequenceNumber == aws.StringValue(input.StartingSequenceNumber) {
+				index = i
+				if t == "AFTER_SEQUENCE_NUMBER" {
+					index++
+				}
+				break
+			}
+		}
+		if index < 0 {
+			return nil, errorf(kinesis.ErrCodeInvalidArgumentException, "StartingSequenceNumber %s is not in shard %s", aws.StringValue(input.StartingSequenceNumber), sh.id)
+		}
+	default:
+		return nil, errorf(kinesis.ErrCodeInvalidArgumentException, "ShardIteratorType %s is invalid", t)
+	}
+
+	return &kinesis.GetShardIteratorOutput{
+		ShardIterator: aws.String(fmt.Sprintf("%s/%s/%d", s.name, sh.id, index)),
+	}, nil
+}
+
+func (k *Kinesis) GetRecords(input *kinesis.GetRecordsInput) (*kinesis.GetRecordsOutput, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	parts := strings.Split(aws.StringValue(input.ShardIterator), "/")
+	if len(parts) != 3 {
+		return nil, errorf(kinesis.ErrCodeInvalidArgumentException, "ShardIterator is invalid")
+	}
+	index, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, errorf(kinesis.ErrCodeInvalidArgumentException, "ShardIterator is invalid")
+	}
+	s, sh, err := k.shard(parts[0], parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	end := len(sh.records)
+	if input.Limit != nil && index+int(*input.Limit) < end {
+		end = index + int(*input.Limit)
+	}
+	records := []*kinesis.Record{}
+	if index < end {
+		records = sh.records[index:end]
+	}
+
+	out := &kinesis.GetRecordsOutput{
+		Records:            records,
+		MillisBehindLatest: aws.Int64(0),
+	}
+	// A closed shard has no next iterator after the last record
+	if !sh.closed || end < len(sh.records) {
+		out.NextShardIterator = aws.String(fmt.Sprintf("%s/%s/%d", s.name, sh.id, end))
+	}
+	return out, nil
+}
+
+func (k *Kinesis) shard(streamName, shardID string) (*kinesisStream, *kinesisShard, error) {
+	s, ok := k.streams[streamName]
+	if !ok {
+		return nil, nil, errorf(kinesis.ErrCodeResourceNotFoundException, "Stream %s under account %s not found.", streamName, AccountID)
+	}
+	for _, sh := range s.shards {
+		if sh.id == shardID {
+			return s, sh, nil
+		}
+	}
+	return nil, nil, errorf(kinesis.ErrCodeResourceNotFoundException, "Shard %s in stream %s under account %s does not exist", shardID, streamName, AccountID)
+}
+
+// streamNameFromARN returns the name of a stream in this account
+func streamNameFromARN(a string) (string, bool) {
+	prefix := arn("kinesis", "stream/")
+	if !strings.HasPrefix(a, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(a, prefix), true
+}
@@ -0,0 +1,240 @@
+package fake
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3 is a fake of awsclient.S3
+type S3 struct {
+	mutex   sync.Mutex
+	buckets map[string]*s3Bucket
+}
+
+type s3Bucket struct {
+	objects   map[string]*s3Object
+	lifecycle []*s3.LifecycleRule
+}
+
+type s3Object struct {
+	body         []byte
+	lastModified time.Time
+}
+
+func NewS3() *S3 {
+	return &S3{buckets: map[string]*s3Bucket{}}
+}
+
+// Object returns the content of an object
+func (c *S3) Object(bucket, key string) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b, ok := c.buckets[bucket]
+	if !ok {
+		return nil, false
+	}
+	o, ok := b.objects[key]
+	if !ok {
+		return nil, false
+	}
+	return o.body, true
+}
+
+// SetLastModified changes when an object was last modified
+func (c *S3) SetLastModified(bucket, key string, t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if b, ok := c.buckets[bucket]; ok {
+		if o, ok := b.objects[key]; ok {
+			o.lastModified = t
+		}
+	}
+}
+
+// Keys returns keys of objects in a bucket which start with prefix
+func (c *S3) Keys(bucket, prefix string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	keys := []string{}
+	b, ok := c.buckets[bucket]
+	if !ok {
+		return keys
+	}
+	for k := range b.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *S3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b, ok := c.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, errorf(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist")
+	}
+
+	body := []byte{}
+	if input.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(input.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b.objects[aws.StringValue(input.Key)] = &s3Object{body: body, lastModified: time.Now()}
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (c *S3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b, ok := c.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, errorf(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist")
+	}
+	o, ok := b.objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, errorf(s3.ErrCodeNoSuchKey, "The specified key does not exist.")
+	}
+	return &s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(bytes.NewReader(o.body)),
+		ContentLength: aws.Int64(int64(len(o.body))),
+		LastModified:  aws.Time(o.lastModified),
+	}, nil
+}
+
+func (c *S3) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	c.mutex.Lock()
+	b, ok := c.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		c.mutex.Unlock()
+		return errorf(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist")
+	}
+	keys := []string{}
+	for k := range b.objects {
+		if strings.HasPrefix(k, aws.StringValue(input.Prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	contents := []*s3.Object{}
+	for _, k := range keys {
+		o := b.objects[k]
+		contents = append(contents, &s3.Object{
+			Key:          aws.String(k),
+			Size:         aws.Int64(int64(len(o.body))),
+			LastModified: aws.Time(o.lastModified),
+		})
+	}
+	c.mutex.Unlock()
+
+	// Pages have at most MaxKeys (default 1000) objects
+	size := int(aws.Int64Value(input.MaxKeys))
+	if size <= 0 {
+		size = 1000
+	}
+	for i := 0; i == 0 || i < len(contents); i += size {
+		end := i + size
+		if end > len(contents) {
+			end = len(contents)
+		}
+		last := end == len(contents)
+		page := &s3.ListObjectsV2Output{
+			Contents:    contents[i:end],
+			KeyCount:    aws.Int64(int64(end - i)),
+			IsTruncated: aws.Bool(!last),
+		}
+		if !fn(page, last) {
+			break
+		}
+	}
+	return nil
+}
+
+func (c *S3) DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b, ok := c.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, errorf(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist")
+	}
+	if input.Delete == nil || len(input.Delete.Objects) > 1000 {
+		return nil, errorf("MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+
+	out := &s3.DeleteObjectsOutput{}
+	for _, o := range input.Delete.Objects {
+		delete(b.objects, aws.StringValue(o.Key))
+		out.Deleted = append(out.Deleted, &s3.DeletedObject{Key: o.Key})
+	}
+	return out, nil
+}
+
+func (c *S3) HeadBucket(input *s3.HeadBucketInput) (*s3.HeadBucketOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.buckets[aws.StringValue(input.Bucket)]; !ok {
+		// HEAD responses have no body, so the code is the HTTP status
+		return nil, errorf("NotFound", "Not Found")
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (c *S3) CreateBucket(input *s3.CreateBucketInput) (*s3.CreateBucketOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	name := aws.StringValue(input.Bucket)
+	if _, ok := c.buckets[name]; ok {
+		return nil, errorf(s3.ErrCodeBucketAlreadyOwnedByYou, "Your previous request to create the named bucket succeeded and you already own it.")
+	}
+	c.buckets[name] = &s3Bucket{objects: map[string]*s3Object{}}
+	return &s3.CreateBucketOutput{Location: aws.String("/" + name)}, nil
+}
+
+func (c *S3) GetBucketLifecycleConfiguration(input *s3.GetBucketLifecycleConfigurationInput) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b, ok := c.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, errorf(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist")
+	}
+	if len(b.lifecycle) == 0 {
+		return nil, errorf("NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist")
+	}
+	return &s3.GetBucketLifecycleConfigurationOutput{Rules: b.lifecycle}, nil
+}
+
+func (c *S3) PutBucketLifecycleConfiguration(input *s3.PutBucketLifecycleConfigurationInput) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b, ok := c.buckets[aws.StringValue(input.Bucket)]
+	if !ok {
+		return nil, errorf(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist")
+	}
+	b.lifecycle = nil
+	if input.LifecycleConfiguration != nil {
+		b.lifecycle = input.LifecycleConfiguration.Rules
+	}
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
@@ -0,0 +1,779 @@
+package fake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/google/uuid"
+)
+
+// SSM is a fake of awsclient.SSM.
+// Commands progress each time they are polled: invocations are Pending when the command is sent,
+// start running on the next poll, writing their output logs, and finish on the poll after that.
+type SSM struct {
+	// Agent decides how a command runs on an instance. By default, it succeeds without output.
+	Agent func(instanceID string, command *ssm.Command) *Execution
+
+	mutex     sync.Mutex
+	s3        *S3
+	logs      *CloudWatchLogs
+	documents map[string]*ssmDocument
+	instances map[string]*Instance
+	commands  []*ssmCommand
+	now       func() time.Time
+}
+
+// defaultAgentVersion is the version of SSM agent on instances by default
+const defaultAgentVersion = "2.3.0.0"
+
+// runShellScriptDocument is a document owned by AWS which commands can be sent with
+const runShellScriptDocument = "AWS-RunShellScript"
+
+// Instance is a managed instance
+type Instance struct {
+	ID           string
+	Name         string
+	Tags         map[string]string
+	PingStatus   string
+	PlatformType string
+	IPAddress    string
+	AgentVersion string
+	// OutdatedAgent is true if SSM agent is not the latest version
+	OutdatedAgent bool
+}
+
+// Execution is how an invocation runs on an instance
+type Execution struct {
+	// Output is written to the output log stream of the instance when the invocation starts.
+	// It is also the output of the plugin of the invocation.
+	Output []string
+	// Status is the final status of the invocation (e.g. Success, Failed).
+	// If it is empty, the invocation keeps running until it is cancelled.
+	Status string
+}
+
+type ssmDocument struct {
+	name           string
+	documentType   string
+	defaultVersion int
+	versions       []string // contents
+	createdDates   []time.Time
+	tags           map[string]string
+}
+
+type ssmCommand struct {
+	command         *ssm.Command
+	documentVersion int
+	invocations     []*ssmInvocation
+}
+
+type ssmInvocation struct {
+	invocation *ssm.CommandInvocation
+	execution  *Execution
+}
+
+func NewSSM(s3 *S3, logs *CloudWatchLogs) *SSM {
+	return &SSM{
+		s3:        s3,
+		logs:      logs,
+		documents: map[string]*ssmDocument{},
+		instances: map[string]*Instance{},
+		now:       time.Now,
+	}
+}
+
+// AddInstance registers a managed instance. PingStatus and PlatformType default to Online and Linux.
+// AgentVersion defaults to the latest version.
+func (s *SSM) AddInstance(i *Instance) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if i.PingStatus == "" {
+		i.PingStatus = ssm.PingStatusOnline
+	}
+	if i.PlatformType == "" {
+		i.PlatformType = ssm.PlatformTypeLinux
+	}
+	if i.AgentVersion == "" {
+		i.AgentVersion = defaultAgentVersion
+	}
+	s.instances[i.ID] = i
+}
+
+func documentHash(content string) string {
+	h := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(h[:])
+}
+
+func (d *ssmDocument) description(version int) *ssm.DocumentDescription {
+	return &ssm.DocumentDescription{
+		Name:            aws.String(d.name),
+		DocumentType:    aws.String(d.documentType),
+		DocumentVersion: aws.String(strconv.Itoa(version)),
+		DefaultVersion:  aws.String(strconv.Itoa(d.defaultVersion)),
+		LatestVersion:   aws.String(strconv.Itoa(len(d.versions))),
+		Hash:            aws.String(documentHash(d.versions[version-1])),
+		HashType:        aws.String(ssm.DocumentHashTypeSha256),
+		CreatedDate:     aws.Time(d.createdDates[version-1]),
+		Owner:           aws.String(AccountID),
+		Status:          aws.String(ssm.DocumentStatusActive),
+	}
+}
+
+// version resolves a document version, which is a number, $LATEST, $DEFAULT or empty (the default version)
+func (d *ssmDocument) version(v *string) (int, error) {
+	switch aws.StringValue(v) {
+	case "", "$DEFAULT":
+		return d.defaultVersion, nil
+	case "$LATEST":
+		return len(d.versions), nil
+	}
+	n, err := strconv.Atoi(*v)
+	if err != nil || n < 1 || n > len(d.versions) {
+		return 0, errorf(ssm.ErrCodeInvalidDocumentVersion, "Document %s does not have version %s", d.name, *v)
+	}
+	return n, nil
+}
+
+func (s *SSM) document(name *string) (*ssmDocument, error) {
+	d, ok := s.documents[aws.StringValue(name)]
+	if !ok {
+		return nil, errorf(ssm.ErrCodeInvalidDocument, "Document with name %s does not exist.", aws.StringValue(name))
+	}
+	return d, nil
+}
+
+func (s *SSM) CreateDocument(input *ssm.CreateDocumentInput) (*ssm.CreateDocumentOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	name := aws.StringValue(input.Name)
+	if _, ok := s.documents[name]; ok {
+		return nil, errorf(ssm.ErrCodeDocumentAlreadyExists, "Document with same name %s already exists", name)
+	}
+
+	d := &ssmDocument{
+		name:           name,
+		documentType:   aws.StringValue(input.DocumentType),
+		defaultVersion: 1,
+		versions:       []string{aws.StringValue(input.Content)},
+		createdDates:   []time.Time{s.now()},
+	}
+	s.documents[name] = d
+	return &ssm.CreateDocumentOutput{DocumentDescription: d.description(1)}, nil
+}
+
+func (s *SSM) DescribeDocument(input *ssm.DescribeDocumentInput) (*ssm.DescribeDocumentOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	d, err := s.document(input.Name)
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.version(input.DocumentVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &ssm.DescribeDocumentOutput{Document: d.description(v)}, nil
+}
+
+func (s *SSM) GetDocument(input *ssm.GetDocumentInput) (*ssm.GetDocumentOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	d, err := s.document(input.Name)
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.version(input.DocumentVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &ssm.GetDocumentOutput{
+		Name:            aws.String(d.name),
+		DocumentType:    aws.String(d.documentType),
+		DocumentVersion: aws.String(strconv.Itoa(v)),
+		Content:         aws.String(d.versions[v-1]),
+	}, nil
+}
+
+func (s *SSM) UpdateDocument(input *ssm.UpdateDocumentInput) (*ssm.UpdateDocumentOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	d, err := s.document(input.Name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.version(input.DocumentVersion); err != nil {
+		return nil, err
+	}
+
+	content := aws.StringValue(input.Content)
+	for _, c := range d.versions {
+		if c == content {
+			return nil, errorf(ssm.ErrCodeDuplicateDocumentContent, "The content of the association document matches another document. Change the content of the document and try again.")
+		}
+	}
+
+	d.versions = append(d.versions, content)
+	d.createdDates = append(d.createdDates, s.now())
+	return &ssm.UpdateDocumentOutput{DocumentDescription: d.description(len(d.versions))}, nil
+}
+
+func (s *SSM) UpdateDocumentDefaultVersion(input *ssm.UpdateDocumentDefaultVersionInput) (*ssm.UpdateDocumentDefaultVersionOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	d, err := s.document(input.Name)
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.version(input.DocumentVersion)
+	if err != nil {
+		return nil, err
+	}
+	d.defaultVersion = v
+	return &ssm.UpdateDocumentDefaultVersionOutput{
+		Description: &ssm.DocumentDefaultVersionDescription{
+			Name:           aws.String(d.name),
+			DefaultVersion: aws.String(strconv.Itoa(v)),
+		},
+	}, nil
+}
+
+func (s *SSM) ListDocumentVersions(input *ssm.ListDocumentVersionsInput) (*ssm.ListDocumentVersionsOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	d, err := s.document(input.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	start := 0
+	if input.NextToken != nil {
+		start, err = strconv.Atoi(*input.NextToken)
+		if err != nil {
+			return nil, errorf(ssm.ErrCodeInvalidNextToken, "The specified token is not valid.")
+		}
+	}
+	end := len(d.versions)
+	if input.MaxResults != nil && start+int(*input.MaxResults) < end {
+		end = start + int(*input.MaxResults)
+	}
+
+	out := &ssm.ListDocumentVersionsOutput{}
+	for i := start; i < end; i++ {
+		v := i + 1
+		out.DocumentVersions = append(out.DocumentVersions, &ssm.DocumentVersionInfo{
+			Name:             aws.String(d.name),
+			DocumentVersion:  aws.String(strconv.Itoa(v)),
+			CreatedDate:      aws.Time(d.createdDates[i]),
+			IsDefaultVersion: aws.Bool(v == d.defaultVersion),
+		})
+	}
+	if end < len(d.versions) {
+		out.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return out, nil
+}
+
+func (s *SSM) DeleteDocument(input *ssm.DeleteDocumentInput) (*ssm.DeleteDocumentOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	d, err := s.document(input.Name)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.documents, d.name)
+	return &ssm.DeleteDocumentOutput{}, nil
+}
+
+// AddTagsToResource supports only documents
+func (s *SSM) AddTagsToResource(input *ssm.AddTagsToResourceInput) (*ssm.AddTagsToResourceOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if aws.StringValue(input.ResourceType) != "Document" {
+		return nil, errorf(ssm.ErrCodeInvalidResourceType, "%s is not supported", aws.StringValue(input.ResourceType))
+	}
+	d, err := s.document(input.ResourceId)
+	if err != nil {
+		return nil, errorf(ssm.ErrCodeInvalidResourceId, "Document %s does not exist", aws.StringValue(input.ResourceId))
+	}
+	if d.tags == nil {
+		d.tags = map[string]string{}
+	}
+	for _, t := range input.Tags {
+		d.tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return &ssm.AddTagsToResourceOutput{}, nil
+}
+
+func (s *SSM) ListTagsForResource(input *ssm.ListTagsForResourceInput) (*ssm.ListTagsForResourceOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if aws.StringValue(input.ResourceType) != "Document" {
+		return nil, errorf(ssm.ErrCodeInvalidResourceType, "%s is not supported", aws.StringValue(input.ResourceType))
+	}
+	d, err := s.document(input.ResourceId)
+	if err != nil {
+		return nil, errorf(ssm.ErrCodeInvalidResourceId, "Document %s does not exist", aws.StringValue(input.ResourceId))
+	}
+
+	keys := []string{}
+	for k := range d.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	resp := &ssm.ListTagsForResourceOutput{TagList: []*ssm.Tag{}}
+	for _, k := range keys {
+		resp.TagList = append(resp.TagList, &ssm.Tag{Key: aws.String(k), Value: aws.String(d.tags[k])})
+	}
+	return resp, nil
+}
+
+// DocumentTags returns tags of a document
+func (s *SSM) DocumentTags(name string) map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tags := map[string]string{}
+	if d, ok := s.documents[name]; ok {
+		for k, v := range d.tags {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+func (s *SSM) ListDocumentsPages(input *ssm.ListDocumentsInput, fn func(*ssm.ListDocumentsOutput, bool) bool) error {
+	s.mutex.Lock()
+	names := []string{}
+	for name := range s.documents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ids := []*ssm.DocumentIdentifier{}
+	for _, name := range names {
+		d := s.documents[name]
+		ids = append(ids, &ssm.DocumentIdentifier{
+			Name:            aws.String(d.name),
+			DocumentType:    aws.String(d.documentType),
+			DocumentVersion: aws.String(strconv.Itoa(d.defaultVersion)),
+			Owner:           aws.String(AccountID),
+		})
+	}
+	s.mutex.Unlock()
+
+	fn(&ssm.ListDocumentsOutput{DocumentIdentifiers: ids}, true)
+	return nil
+}
+
+func (s *SSM) DescribeInstanceInformationPages(input *ssm.DescribeInstanceInformationInput, fn func(*ssm.DescribeInstanceInformationOutput, bool) bool) error {
+	s.mutex.Lock()
+	instances := []*Instance{}
+	for _, i := range s.sortedInstances() {
+		if matchInstanceFilters(i, input.Filters) {
+			instances = append(instances, i)
+		}
+	}
+
+	list := []*ssm.InstanceInformation{}
+	for _, i := range instances {
+		info := &ssm.InstanceInformation{
+			InstanceId:       aws.String(i.ID),
+			ComputerName:     aws.String(i.Name),
+			PingStatus:       aws.String(i.PingStatus),
+			LastPingDateTime: aws.Time(s.now()),
+			PlatformType:     aws.String(i.PlatformType),
+			PlatformName:     aws.String("Amazon Linux"),
+			PlatformVersion:  aws.String("2"),
+			AgentVersion:     aws.String(i.AgentVersion),
+			IsLatestVersion:  aws.Bool(!i.OutdatedAgent),
+			ResourceType:     aws.String(ssm.ResourceTypeEc2instance),
+		}
+		if strings.HasPrefix(i.ID, "mi-") {
+			info.ResourceType = aws.String(ssm.ResourceTypeManagedInstance)
+		}
+		if i.IPAddress != "" {
+			info.IPAddress = aws.String(i.IPAddress)
+		}
+		list = append(list, info)
+	}
+	s.mutex.Unlock()
+
+	fn(&ssm.DescribeInstanceInformationOutput{InstanceInformationList: list}, true)
+	return nil
+}
+
+func (s *SSM) sortedInstances() []*Instance {
+	ids := []string{}
+	for id := range s.instances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	instances := []*Instance{}
+	for _, id := range ids {
+		instances = append(instances, s.instances[id])
+	}
+	return instances
+}
+
+func matchInstanceFilters(i *Instance, filters []*ssm.InstanceInformationStringFilter) bool {
+	for _, f := range filters {
+		values := aws.StringValueSlice(f.Values)
+		key := aws.StringValue(f.Key)
+		switch {
+		case key == "InstanceIds":
+			if !containsString(values, i.ID) {
+				return false
+			}
+		case key == "PingStatus":
+			if !containsString(values, i.PingStatus) {
+				return false
+			}
+		case key == "tag-key":
+			found := false
+			for _, k := range values {
+				if _, ok := i.Tags[k]; ok {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		case strings.HasPrefix(key, "tag:"):
+			v, ok := i.Tags[strings.TrimPrefix(key, "tag:")]
+			if !ok || !containsString(values, v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchTargets returns true if an instance matches all targets of a command
+func matchTargets(i *Instance, targets []*ssm.Target) bool {
+	filters := []*ssm.InstanceInformationStringFilter{}
+	for _, t := range targets {
+		filters = append(filters, &ssm.InstanceInformationStringFilter{Key: t.Key, Values: t.Values})
+	}
+	return matchInstanceFilters(i, filters)
+}
+
+func (s *SSM) SendCommand(input *ssm.SendCommandInput) (*ssm.SendCommandOutput, error) {
+	return s.SendCommandWithDocumentVersion(input, "")
+}
+
+func (s *SSM) SendCommandWithDocumentVersion(input *ssm.SendCommandInput, version string) (*ssm.SendCommandOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if aws.StringValue(input.DocumentName) == runShellScriptDocument {
+		return s.sendCommand(input, 1)
+	}
+
+	d, err := s.document(input.DocumentName)
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.version(aws.String(version))
+	if err != nil {
+		return nil, err
+	}
+	if input.DocumentHash != nil {
+		found := false
+		for _, c := range d.versions {
+			if documentHash(c) == *input.DocumentHash {
+				found = true
+			}
+		}
+		if !found {
+			return nil, errorf(ssm.ErrCodeInvalidDocument, "Document %s does not have hash %s", d.name, *input.DocumentHash)
+		}
+	}
+
+	return s.sendCommand(input, v)
+}
+
+func (s *SSM) sendCommand(input *ssm.SendCommandInput, documentVersion int) (*ssm.SendCommandOutput, error) {
+	targets := input.Targets
+	if len(input.InstanceIds) > 0 {
+		targets = append(targets, &ssm.Target{Key: aws.String("InstanceIds"), Values: input.InstanceIds})
+	}
+	if len(targets) == 0 {
+		return nil, errorf("ValidationException", "Either InstanceIds or Targets must be specified")
+	}
+	if len(input.Targets) > 5 {
+		return nil, errorf("ValidationException", "Targets must contain at most 5 items")
+	}
+	for _, t := range targets {
+		if aws.StringValue(t.Key) == "InstanceIds" && len(t.Values) > 50 {
+			return nil, errorf("ValidationException", "InstanceIds must contain at most 50 items")
+		}
+	}
+
+	now := s.now()
+	c := &ssmCommand{
+		command: &ssm.Command{
+			CommandId:         aws.String(uuid.New().String()),
+			DocumentName:      input.DocumentName,
+			Parameters:        input.Parameters,
+			Targets:           input.Targets,
+			InstanceIds:       input.InstanceIds,
+			MaxConcurrency:    input.MaxConcurrency,
+			MaxErrors:         input.MaxErrors,
+			Comment:           input.Comment,
+			RequestedDateTime: aws.Time(now),
+			Status:            aws.String(ssm.CommandStatusPending),
+		},
+		documentVersion: documentVersion,
+	}
+
+	for _, i := range s.sortedInstances() {
+		if !matchTargets(i, targets) {
+			continue
+		}
+		c.invocations = append(c.invocations, &ssmInvocation{
+			invocation: &ssm.CommandInvocation{
+				CommandId:         c.command.CommandId,
+				InstanceId:        aws.String(i.ID),
+				InstanceName:      aws.String(i.Name),
+				DocumentName:      c.command.DocumentName,
+				RequestedDateTime: aws.Time(now),
+				Status:            aws.String(ssm.CommandInvocationStatusPending),
+				StatusDetails:     aws.String("Pending"),
+			},
+		})
+	}
+	c.command.TargetCount = aws.Int64(int64(len(c.invocations)))
+	c.updateStatus()
+
+	s.commands = append(s.commands, c)
+	command := *c.command
+	return &ssm.SendCommandOutput{Command: &command}, nil
+}
+
+// DocumentVersion returns the version of the document a command runs
+func (s *SSM) DocumentVersion(commandID string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, c := range s.commands {
+		if *c.command.CommandId == commandID {
+			return strconv.Itoa(c.documentVersion)
+		}
+	}
+	return ""
+}
+
+func (s *SSM) ListCommands(input *ssm.ListCommandsInput) (*ssm.ListCommandsOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.progress()
+
+	commands := []*ssm.Command{}
+	for i := len(s.commands) - 1; i >= 0; i-- {
+		c := s.commands[i]
+		if input.CommandId != nil && *c.command.CommandId != *input.CommandId {
+			continue
+		}
+		if input.InstanceId != nil && c.invocation(*input.InstanceId) == nil {
+			continue
+		}
+		command := *c.command
+		commands = append(commands, &command)
+	}
+	if input.CommandId != nil && len(commands) == 0 {
+		return nil, errorf(ssm.ErrCodeInvalidCommandId, "Command %s is not found", *input.CommandId)
+	}
+
+	return &ssm.ListCommandsOutput{Commands: commands}, nil
+}
+
+func (s *SSM) ListCommandInvocationsPages(input *ssm.ListCommandInvocationsInput, fn func(*ssm.ListCommandInvocationsOutput, bool) bool) error {
+	s.mutex.Lock()
+	s.progress()
+
+	invocations := []*ssm.CommandInvocation{}
+	for _, c := range s.commands {
+		if input.CommandId != nil && *c.command.CommandId != *input.CommandId {
+			continue
+		}
+		for _, i := range c.invocations {
+			if input.InstanceId != nil && *i.invocation.InstanceId != *input.InstanceId {
+				continue
+			}
+			invocation := *i.invocation
+			if aws.BoolValue(input.Details) && !i.running() && i.execution != nil {
+				invocation.CommandPlugins = []*ssm.CommandPlugin{{
+					Name:   aws.String("aws:runShellScript"),
+					Status: invocation.Status,
+					Output: aws.String(strings.Join(i.execution.Output, "\n")),
+				}}
+			}
+			invocations = append(invocations, &invocation)
+		}
+	}
+	s.mutex.Unlock()
+
+	fn(&ssm.ListCommandInvocationsOutput{CommandInvocations: invocations}, true)
+	return nil
+}
+
+func (s *SSM) CancelCommand(input *ssm.CancelCommandInput) (*ssm.CancelCommandOutput, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, c := range s.commands {
+		if *c.command.CommandId != aws.StringValue(input.CommandId) {
+			continue
+		}
+		for _, i := range c.invocations {
+			if len(input.InstanceIds) > 0 && !containsString(aws.StringValueSlice(input.InstanceIds), *i.invocation.InstanceId) {
+				continue
+			}
+			if i.running() {
+				i.finish(ssm.CommandInvocationStatusCancelled)
+			}
+		}
+		c.updateStatus()
+		return &ssm.CancelCommandOutput{}, nil
+	}
+	return nil, errorf(ssm.ErrCodeInvalidCommandId, "Command %s is not found", aws.StringValue(input.CommandId))
+}
+
+// progress advances invocations of all commands by a step
+func (s *SSM) progress() {
+	for _, c := range s.commands {
+		for _, i := range c.invocations {
+			switch *i.invocation.Status {
+			case ssm.CommandInvocationStatusPending:
+				s.start(c, i)
+			case ssm.CommandInvocationStatusInProgress:
+				if s.signaled(c, i) {
+					i.finish(ssm.CommandInvocationStatusCancelled)
+				} else if i.execution.Status != "" {
+					i.finish(i.execution.Status)
+				}
+			}
+		}
+		c.updateStatus()
+	}
+}
+
+// start runs the agent on an instance and writes output logs as the agent of paramedic does
+func (s *SSM) start(c *ssmCommand, i *ssmInvocation) {
+	agent := s.Agent
+	if agent == nil {
+		agent = func(string, *ssm.Command) *Execution {
+			return &Execution{Status: ssm.CommandInvocationStatusSuccess}
+		}
+	}
+	i.execution = agent(*i.invocation.InstanceId, c.command)
+	i.invocation.Status = aws.String(ssm.CommandInvocationStatusInProgress)
+	i.invocation.StatusDetails = aws.String("InProgress")
+
+	group := parameter(c.command, "outputLogGroup")
+	if len(i.execution.Output) == 0 || group == "" {
+		// Documents other than those of paramedic don't write output logs
+		return
+	}
+	stream := parameter(c.command, "outputLogStreamPrefix") + *i.invocation.InstanceId
+	if err := s.logs.PutLogEvents(group, stream, s.now(), i.execution.Output...); err != nil {
+		i.finish(ssm.CommandInvocationStatusFailed)
+	}
+}
+
+// signaled returns true if a signal object for an invocation is put
+func (s *SSM) signaled(c *ssmCommand, i *ssmInvocation) bool {
+	bucket := parameter(c.command, "signalS3Bucket")
+	key := parameter(c.command, "signalS3Key")
+	if _, ok := s.s3.Object(bucket, key); ok {
+		return true
+	}
+	key = fmt.Sprintf("%s/%s.json", strings.TrimSuffix(key, ".json"), *i.invocation.InstanceId)
+	_, ok := s.s3.Object(bucket, key)
+	return ok
+}
+
+func parameter(c *ssm.Command, name string) string {
+	v, ok := c.Parameters[name]
+	if !ok || len(v) == 0 {
+		return ""
+	}
+	return aws.StringValue(v[0])
+}
+
+func (c *ssmCommand) invocation(instanceID string) *ssmInvocation {
+	for _, i := range c.invocations {
+		if *i.invocation.InstanceId == instanceID {
+			return i
+		}
+	}
+	return nil
+}
+
+// updateStatus sets the status of a command from its invocations
+func (c *ssmCommand) updateStatus() {
+	counts := map[string]int{}
+	for _, i := range c.invocations {
+		counts[*i.invocation.Status]++
+	}
+
+	status := ssm.CommandStatusSuccess
+	switch {
+	case counts[ssm.CommandInvocationStatusPending] == len(c.invocations) && len(c.invocations) > 0:
+		status = ssm.CommandStatusPending
+	case counts[ssm.CommandInvocationStatusPending]+counts[ssm.CommandInvocationStatusInProgress] > 0:
+		status = ssm.CommandStatusInProgress
+	case counts[ssm.CommandInvocationStatusCancelled] > 0:
+		status = ssm.CommandStatusCancelled
+	case counts[ssm.CommandInvocationStatusTimedOut] > 0:
+		status = ssm.CommandStatusTimedOut
+	case counts[ssm.CommandInvocationStatusSuccess] < len(c.invocations):
+		status = ssm.CommandStatusFailed
+	}
+
+	c.command.Status = aws.String(status)
+	c.command.CompletedCount = aws.Int64(int64(len(c.invocations) - counts[ssm.CommandInvocationStatusPending] - counts[ssm.CommandInvocationStatusInProgress]))
+	c.command.ErrorCount = aws.Int64(int64(counts[ssm.CommandInvocationStatusFailed] + counts[ssm.CommandInvocationStatusTimedOut]))
+}
+
+func (i *ssmInvocation) running() bool {
+	switch *i.invocation.Status {
+	case ssm.CommandInvocationStatusPending, ssm.CommandInvocationStatusInProgress, ssm.CommandInvocationStatusDelayed:
+		return true
+	}
+	return false
+}
+
+func (i *ssmInvocation) finish(status string) {
+	i.invocation.Status = aws.String(status)
+	i.invocation.StatusDetails = aws.String(status)
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
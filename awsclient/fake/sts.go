@@ -0,0 +1,26 @@
+package fake
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// STS is a fake of awsclient.STS
+type STS struct {
+	// UserName is the IAM user the caller is authenticated as
+	UserName string
+}
+
+func NewSTS() *STS {
+	return &STS{UserName: "paramedic"}
+}
+
+func (s *STS) GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String(AccountID),
+		Arn:     aws.String(fmt.Sprintf("arn:aws:iam::%s:user/%s", AccountID, s.UserName)),
+		UserId:  aws.String("AIDAFAKE"),
+	}, nil
+}
@@ -0,0 +1,9 @@
+package awsclient
+
+import "github.com/aws/aws-sdk-go/service/iam"
+
+type IAM interface {
+	GetRole(*iam.GetRoleInput) (*iam.GetRoleOutput, error)
+	CreateRole(*iam.CreateRoleInput) (*iam.CreateRoleOutput, error)
+	PutRolePolicy(*iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error)
+}
@@ -6,4 +6,6 @@ type Kinesis interface {
 	GetRecords(*kinesis.GetRecordsInput) (*kinesis.GetRecordsOutput, error)
 	GetShardIterator(*kinesis.GetShardIteratorInput) (*kinesis.GetShardIteratorOutput, error)
 	DescribeStream(*kinesis.DescribeStreamInput) (*kinesis.DescribeStreamOutput, error)
+	CreateStream(*kinesis.CreateStreamInput) (*kinesis.CreateStreamOutput, error)
+	WaitUntilStreamExists(*kinesis.DescribeStreamInput) error
 }
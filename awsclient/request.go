@@ -0,0 +1,38 @@
+package awsclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// setBodyField returns a Build handler which adds a field to the JSON request body.
+// It is for request fields which the vendored SDK predates.
+func setBodyField(name string, value interface{}) func(*request.Request) {
+	return func(r *request.Request) {
+		if r.Error != nil {
+			return
+		}
+
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			r.Error = err
+			return
+		}
+
+		body := map[string]interface{}{}
+		if err := json.Unmarshal(b, &body); err != nil {
+			r.Error = err
+			return
+		}
+		body[name] = value
+
+		b, err = json.Marshal(body)
+		if err != nil {
+			r.Error = err
+			return
+		}
+		r.SetBufferBody(b)
+	}
+}
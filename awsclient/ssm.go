@@ -1,17 +1,34 @@
 package awsclient
 
-import (
-	"github.com/aws/aws-sdk-go/service/ssm"
-)
+import "github.com/aws/aws-sdk-go/service/ssm"
 
 type SSM interface {
 	CreateDocument(*ssm.CreateDocumentInput) (*ssm.CreateDocumentOutput, error)
 	DescribeDocument(*ssm.DescribeDocumentInput) (*ssm.DescribeDocumentOutput, error)
+	GetDocument(*ssm.GetDocumentInput) (*ssm.GetDocumentOutput, error)
 	UpdateDocument(*ssm.UpdateDocumentInput) (*ssm.UpdateDocumentOutput, error)
 	UpdateDocumentDefaultVersion(*ssm.UpdateDocumentDefaultVersionInput) (*ssm.UpdateDocumentDefaultVersionOutput, error)
+	ListDocumentVersions(*ssm.ListDocumentVersionsInput) (*ssm.ListDocumentVersionsOutput, error)
+	DeleteDocument(*ssm.DeleteDocumentInput) (*ssm.DeleteDocumentOutput, error)
+	AddTagsToResource(*ssm.AddTagsToResourceInput) (*ssm.AddTagsToResourceOutput, error)
+	ListTagsForResource(*ssm.ListTagsForResourceInput) (*ssm.ListTagsForResourceOutput, error)
+	CancelCommand(*ssm.CancelCommandInput) (*ssm.CancelCommandOutput, error)
 	SendCommand(*ssm.SendCommandInput) (*ssm.SendCommandOutput, error)
+	SendCommandWithDocumentVersion(*ssm.SendCommandInput, string) (*ssm.SendCommandOutput, error)
 	ListCommands(*ssm.ListCommandsInput) (*ssm.ListCommandsOutput, error)
 	ListCommandInvocationsPages(*ssm.ListCommandInvocationsInput, func(*ssm.ListCommandInvocationsOutput, bool) bool) error
 	DescribeInstanceInformationPages(*ssm.DescribeInstanceInformationInput, func(*ssm.DescribeInstanceInformationOutput, bool) bool) error
 	ListDocumentsPages(*ssm.ListDocumentsInput, func(*ssm.ListDocumentsOutput, bool) bool) error
 }
+
+// ssmClient supports running a specific version of a document, which the vendored SDK predates
+type ssmClient struct {
+	*ssm.SSM
+}
+
+// SendCommandWithDocumentVersion sends a command which runs a version of the document
+func (c *ssmClient) SendCommandWithDocumentVersion(input *ssm.SendCommandInput, version string) (*ssm.SendCommandOutput, error) {
+	req, out := c.SSM.SendCommandRequest(input)
+	req.Handlers.Build.PushBack(setBodyField("DocumentVersion", version))
+	return out, req.Send()
+}
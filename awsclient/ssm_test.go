@@ -0,0 +1,46 @@
+package awsclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+func TestSSMSendCommandWithDocumentVersion(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	c := &ssmClient{ssm.New(sess)}
+
+	_, err := c.SendCommandWithDocumentVersion(&ssm.SendCommandInput{
+		DocumentName: aws.String("paramedic-foo"),
+		InstanceIds:  aws.StringSlice([]string{"i-aaa"}),
+	}, "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body["DocumentVersion"] != "3" {
+		t.Errorf("got DocumentVersion %v, want 3", body["DocumentVersion"])
+	}
+	if body["DocumentName"] != "paramedic-foo" {
+		t.Errorf("got DocumentName %v, want paramedic-foo", body["DocumentName"])
+	}
+}
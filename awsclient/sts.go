@@ -0,0 +1,7 @@
+package awsclient
+
+import "github.com/aws/aws-sdk-go/service/sts"
+
+type STS interface {
+	GetCallerIdentity(*sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error)
+}
@@ -3,12 +3,35 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"log"
 	"os"
 	"strings"
+
+	"github.com/spf13/viper"
 )
 
+// confirm asks to continue unless yes (--yes) is true. In an environment with require-confirmation,
+// it asks even with --yes. It returns false if the user declines.
+func confirm(yes bool) (bool, error) {
+	if yes {
+		if !viper.GetBool("require-confirmation") {
+			return true, nil
+		}
+		log.Print("[INFO] Confirmation is required in this environment even with --yes")
+	}
+
+	cont, err := askContinue("Are you sure to continue?")
+	if err != nil {
+		return false, err
+	}
+	if !cont {
+		fmt.Fprintln(os.Stderr, "Canceled.")
+	}
+	return cont, nil
+}
+
 func askContinue(msg string) (bool, error) {
-	fmt.Printf("%s (y/N): ", msg)
+	fmt.Fprintf(os.Stderr, "%s (y/N): ", msg)
 
 	r := bufio.NewReader(os.Stdin)
 	line, err := r.ReadString('\n')
@@ -17,3 +40,15 @@ func askContinue(msg string) (bool, error) {
 	}
 	return strings.HasPrefix(line, "y"), nil
 }
+
+// askMFAToken prompts for an MFA token code to assume a role
+func askMFAToken() (string, error) {
+	fmt.Fprint(os.Stderr, "MFA token code: ")
+
+	r := bufio.NewReader(os.Stdin)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestConfirm(t *testing.T) {
+	defer viper.Set("require-confirmation", false)
+
+	examples := []struct {
+		yes                 bool
+		requireConfirmation bool
+		answer              string
+		want                bool
+	}{
+		{yes: true, want: true},
+		{yes: false, answer: "y\n", want: true},
+		{yes: false, answer: "n\n", want: false},
+		{yes: true, requireConfirmation: true, answer: "n\n", want: false},
+		{yes: true, requireConfirmation: true, answer: "yes\n", want: true},
+	}
+	for _, ex := range examples {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteString(ex.answer)
+		w.Close()
+		stdin := os.Stdin
+		os.Stdin = r
+
+		viper.Set("require-confirmation", ex.requireConfirmation)
+		got, err := confirm(ex.yes)
+		os.Stdin = stdin
+		r.Close()
+
+		if ex.answer == "" && err != nil {
+			t.Errorf("%+v: asked for confirmation", ex)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != ex.want {
+			t.Errorf("%+v: got %v, want %v", ex, got, ex.want)
+		}
+	}
+}
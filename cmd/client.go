@@ -1,20 +1,93 @@
 package cmd
 
 import (
+	"fmt"
+	"regexp"
+	"time"
+
 	"github.com/ryotarai/paramedic/awsclient"
 	"github.com/ryotarai/paramedic/commands"
 	"github.com/ryotarai/paramedic/documents"
 	"github.com/ryotarai/paramedic/store"
+	"github.com/spf13/viper"
 )
 
+var endpointServicePattern = regexp.MustCompile(`^([a-z0-9]+)=(.+)$`)
+
+// awsFactory is used instead of the one configured by flags if it is set (e.g. fakes in tests)
+var awsFactory *awsclient.Factory
+
+// pollInterval overrides intervals to check the status of commands if it is not zero
+var pollInterval time.Duration
+
+// newAWSFactory returns a factory configured by the global flags and the config file
+func newAWSFactory() (*awsclient.Factory, error) {
+	if awsFactory != nil {
+		return awsFactory, nil
+	}
+
+	endpoint, endpoints, err := parseEndpointURLs(viper.GetStringSlice("endpoint-url"))
+	if err != nil {
+		return nil, err
+	}
+
+	return awsclient.NewFactory(&awsclient.Options{
+		Profile:         viper.GetString("profile"),
+		Region:          viper.GetString("region"),
+		RoleARN:         viper.GetString("role-arn"),
+		ExternalID:      viper.GetString("external-id"),
+		RoleSessionName: viper.GetString("role-session-name"),
+		MFASerial:       viper.GetString("mfa-serial"),
+		TokenProvider:   askMFAToken,
+		Endpoint:        endpoint,
+		Endpoints:       endpoints,
+	})
+}
+
+// parseEndpointURLs parses values of --endpoint-url, which are a URL for all services
+// or SERVICE=URL for a specific service (e.g. s3=http://localhost:4572)
+func parseEndpointURLs(values []string) (string, map[string]string, error) {
+	endpoint := ""
+	endpoints := map[string]string{}
+	for _, v := range values {
+		m := endpointServicePattern.FindStringSubmatch(v)
+		if m == nil {
+			if endpoint != "" {
+				return "", nil, fmt.Errorf("endpoint URL for all services is specified more than once")
+			}
+			endpoint = v
+			continue
+		}
+
+		switch m[1] {
+		case awsclient.ServiceSSM, awsclient.ServiceS3, awsclient.ServiceDynamoDB, awsclient.ServiceCloudWatchLogs,
+			awsclient.ServiceKinesis, awsclient.ServiceSTS, awsclient.ServiceIAM, awsclient.ServiceEC2:
+			endpoints[m[1]] = m[2]
+		default:
+			return "", nil, fmt.Errorf("unknown service '%s' in endpoint URL %s", m[1], v)
+		}
+	}
+	return endpoint, endpoints, nil
+}
+
 func newCommandsClient(f *awsclient.Factory) (*commands.Client, error) {
 	return &commands.Client{
 		SSM:   f.SSM(),
 		S3:    f.S3(),
-		Store: store.New(f.DynamoDB()),
+		STS:   f.STS(),
+		EC2:   f.EC2(),
+		Store: newStore(f),
+
+		PollInterval: pollInterval,
 	}, nil
 }
 
+func newStore(f *awsclient.Factory) *store.Store {
+	s := store.New(f.DynamoDB(), viper.GetString("table-name"))
+	s.HistoryTTL = viper.GetDuration("history-ttl")
+	return s
+}
+
 func newDocumentsClient(f *awsclient.Factory, bucket, keyPrefix string) (*documents.Client, error) {
 	return &documents.Client{
 		SSM:               f.SSM(),
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEndpointURLs(t *testing.T) {
+	endpoint, endpoints, err := parseEndpointURLs([]string{"http://localhost:4566", "s3=http://localhost:4572", "logs=http://localhost:4586?a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint != "http://localhost:4566" {
+		t.Errorf("got %s, want http://localhost:4566", endpoint)
+	}
+	want := map[string]string{"s3": "http://localhost:4572", "logs": "http://localhost:4586?a=b"}
+	if !reflect.DeepEqual(endpoints, want) {
+		t.Errorf("got %v, want %v", endpoints, want)
+	}
+
+	if _, _, err := parseEndpointURLs([]string{"sqs=http://localhost:4576"}); err == nil {
+		t.Error("got no error for an unknown service")
+	}
+}
@@ -16,8 +16,10 @@ package cmd
 
 import (
 	"log"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/ryotarai/paramedic/awsclient"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -40,8 +42,11 @@ func commandsCancelHandler(cmd *cobra.Command, args []string) error {
 
 	commandID := viper.GetString("command-id")
 	signalNo := viper.GetInt("signal")
+	instanceIDs := viper.GetStringSlice("instance-ids")
+	escalateAfter := viper.GetDuration("escalate-after")
+	forceAfter := viper.GetDuration("force-after")
 
-	awsf, err := awsclient.NewFactory()
+	awsf, err := newAWSFactory()
 	if err != nil {
 		return err
 	}
@@ -56,17 +61,75 @@ func commandsCancelHandler(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	err = cmdClient.Cancel(command, signalNo)
+	err = cmdClient.Cancel(command, signalNo, instanceIDs)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("[INFO] Canceling a command %s", commandID)
+	if len(instanceIDs) > 0 {
+		log.Printf("[INFO] Canceling a command %s on %s", commandID, strings.Join(instanceIDs, ", "))
+	} else {
+		log.Printf("[INFO] Canceling a command %s", commandID)
+	}
+
+	if escalateAfter > 0 {
+		running, err := cmdClient.WaitInvocations(commandID, instanceIDs, escalateAfter)
+		if err != nil {
+			return err
+		}
+		if len(running) > 0 {
+			log.Printf("[INFO] Sending SIGKILL to %d instances still running: %s", len(running), strings.Join(running, ", "))
+			if len(instanceIDs) == 0 {
+				// The command key still has the first signal, which agents watching only it would keep seeing
+				if err := cmdClient.Cancel(command, int(syscall.SIGKILL), nil); err != nil {
+					return err
+				}
+			}
+			if err := cmdClient.Cancel(command, int(syscall.SIGKILL), running); err != nil {
+				return err
+			}
+		}
+	}
 
-	canceled := []string{"Success", "Cancelled", "Failed", "TimedOut", "Cancelling"}
-	command = <-cmdClient.WaitStatus(command.CommandID, canceled)
+	if forceAfter > 0 {
+		running, err := cmdClient.WaitInvocations(commandID, instanceIDs, forceAfter)
+		if err != nil {
+			return err
+		}
+		if len(running) > 0 {
+			log.Printf("[WARN] The signal is not picked up on %d instances. Cancelling them with SSM CancelCommand: %s", len(running), strings.Join(running, ", "))
+			if err := cmdClient.ForceCancel(commandID, running); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(instanceIDs) == 0 {
+		canceled := []string{"Success", "Cancelled", "Failed", "TimedOut", "Cancelling"}
+		command = <-cmdClient.WaitStatus(command.CommandID, canceled)
+
+		log.Printf("[INFO] The command is now in %s state", command.Status)
+	} else {
+		command, err = cmdClient.Get(commandID)
+		if err != nil {
+			return err
+		}
+	}
 
-	log.Printf("[INFO] The command is now in %s state", command.Status)
+	invocations, err := cmdClient.GetInvocations(command.CommandID)
+	if err != nil {
+		return err
+	}
+	if err := cmdClient.UpdateRecord(command.CommandID, invocations); err != nil {
+		log.Printf("[WARN] Failed to update the command history: %s", err)
+	}
+
+	if structuredOutput() {
+		return printData(&commandResult{
+			Command:     command,
+			Invocations: invocations,
+		})
+	}
 
 	return nil
 }
@@ -84,4 +147,7 @@ func init() {
 	// is called directly, e.g.:
 	commandsCancelCmd.Flags().String("command-id", "", "Command ID to be canceled")
 	commandsCancelCmd.Flags().Int("signal", 15, "Signal number to be sent to the processes")
+	commandsCancelCmd.Flags().StringSlice("instance-ids", []string{}, "Cancel only the invocations on these instances")
+	commandsCancelCmd.Flags().Duration("escalate-after", 0, "Send SIGKILL to the processes still running after this duration (e.g. 30s, 0 means never)")
+	commandsCancelCmd.Flags().Duration("force-after", time.Minute, "Cancel invocations with SSM CancelCommand if they are still running after this duration since the last signal, e.g. when the agent is not running (0 means never)")
 }
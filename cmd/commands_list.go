@@ -0,0 +1,155 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ryotarai/paramedic/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var commandsListCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "List commands in the history",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          commandsListHandler,
+}
+
+func commandsListHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	since, err := parseSince(viper.GetString("since"))
+	if err != nil {
+		return err
+	}
+
+	opts := &store.ListCommandsOptions{
+		DocumentName: viper.GetString("document"),
+		RequestedBy:  viper.GetString("user"),
+		Status:       viper.GetString("status"),
+		Tag:          viper.GetString("tag"),
+		Since:        since,
+		Limit:        viper.GetInt64("limit"),
+		NextToken:    viper.GetString("next-token"),
+	}
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	cmdClient, err := newCommandsClient(awsf)
+	if err != nil {
+		return err
+	}
+
+	records, nextToken, err := cmdClient.Store.ListCommands(opts)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return printData(struct {
+			Commands  []*store.CommandRecord `json:"commands" yaml:"commands"`
+			NextToken string                 `json:"nextToken,omitempty" yaml:"nextToken,omitempty"`
+		}{records, nextToken})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "COMMAND ID\tDOCUMENT\tSTATUS\tINSTANCES\tREQUESTED BY\tSTARTED AT")
+	for _, r := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.CommandID,
+			r.DocumentName,
+			r.Status,
+			formatStatusCounts(r.StatusCounts),
+			r.RequestedBy,
+			r.StartedAt.Local().Format(time.RFC3339))
+	}
+	w.Flush()
+
+	if nextToken != "" {
+		fmt.Fprintf(os.Stderr, "\nTo see more commands, run with --next-token=%s\n", nextToken)
+	}
+
+	return nil
+}
+
+// parseSince parses a duration ago (e.g. 12h, 7d) or a time (e.g. 2017-09-27, 2017-09-27T13:00:00+09:00)
+func parseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil {
+			return time.Now().AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("since '%s' is neither a duration nor a time", s)
+}
+
+func formatStatusCounts(counts map[string]int) string {
+	keys := []string{}
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s:%d", k, counts[k]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func init() {
+	commandsCmd.AddCommand(commandsListCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// uploadCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	commandsListCmd.Flags().String("document", "", "Filter by document name")
+	commandsListCmd.Flags().String("user", "", "Filter by IAM identity who ran commands (ARN or a part of it)")
+	commandsListCmd.Flags().String("since", "", "Show commands started after a duration ago (e.g. 12h, 7d) or a time (e.g. 2017-09-27)")
+	commandsListCmd.Flags().String("status", "", "Filter by status (e.g. Success, Failed)")
+	commandsListCmd.Flags().String("tag", "", "Filter by target tag (e.g. 'Env=prod')")
+	commandsListCmd.Flags().Int64("limit", 20, "The maximum number of commands to be evaluated")
+	commandsListCmd.Flags().String("next-token", "", "Token to show the next page")
+}
@@ -20,7 +20,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/ryotarai/paramedic/awsclient"
 	"github.com/ryotarai/paramedic/outputlog"
 
 	"github.com/spf13/cobra"
@@ -48,7 +47,7 @@ func commandsLogHandler(cmd *cobra.Command, args []string) error {
 	sortBy := viper.GetString("sort")
 	follow := viper.GetBool("follow")
 
-	awsf, err := awsclient.NewFactory()
+	awsf, err := newAWSFactory()
 	if err != nil {
 		return err
 	}
@@ -67,11 +66,9 @@ func commandsLogHandler(cmd *cobra.Command, args []string) error {
 
 	var reader outputlog.Reader
 	if follow {
-		reader = &outputlog.KinesisReader{
-			Kinesis:         awsf.Kinesis(),
-			StartTimestamp:  time.Now(),
-			LogGroup:        outputLogGroup,
-			LogStreamPrefix: logStreamPrefix,
+		reader, err = newFollowReader(awsf, outputLogGroup, logStreamPrefix, time.Now())
+		if err != nil {
+			return err
 		}
 	} else {
 		sortByTime := false
@@ -87,13 +84,14 @@ func commandsLogHandler(cmd *cobra.Command, args []string) error {
 	}
 
 	printer := outputlog.NewPrinter(os.Stdout)
+	printer.Format = outputFormat
 
 	if follow {
 		stopCh := make(chan struct{})
 		go func() {
 			command := <-cmdClient.WaitStatus(commandID, []string{"Success", "Cancelled", "Failed", "TimedOut", "Cancelling"})
 			log.Printf("[DEBUG] The command is now in %s status.", command.Status)
-			time.Sleep(10 * time.Second) // Wait for propagation of logs
+			time.Sleep(logPropagationDelay)
 			stopCh <- struct{}{}
 		}()
 		outputlog.Follow(reader, printer, stopCh)
@@ -122,5 +120,6 @@ func init() {
 	commandsLogCmd.Flags().String("command-id", "", "Command ID")
 	commandsLogCmd.Flags().String("output-log-group", "", "Log group")
 	commandsLogCmd.Flags().String("sort", "instance", "Sort by 'instance' of 'time' (This option is effective only for non-follow mode)")
-	commandsLogCmd.Flags().BoolP("follow", "f", false, "Follow logs like `tail -f -n0` (Kinesis Streams will be used)")
+	commandsLogCmd.Flags().BoolP("follow", "f", false, "Follow logs like `tail -f -n0`")
+	addFollowFlags(commandsLogCmd)
 }
@@ -0,0 +1,150 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ryotarai/paramedic/commands"
+	"github.com/ryotarai/paramedic/documents"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var commandsRerunCmd = &cobra.Command{
+	Use:           "rerun",
+	Short:         "Run a command again",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          commandsRerunHandler,
+}
+
+func commandsRerunHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	if err := requireStringFlags([]string{"command-id"}); err != nil {
+		return err
+	}
+
+	commandID := viper.GetString("command-id")
+	only := viper.GetString("only")
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	cmdClient, err := newCommandsClient(awsf)
+	if err != nil {
+		return err
+	}
+
+	docClient, err := newDocumentsClient(awsf, "", "")
+	if err != nil {
+		return err
+	}
+
+	opts, err := cmdClient.RerunOptions(commandID)
+	if err != nil {
+		return err
+	}
+
+	invocations, err := cmdClient.GetInvocations(commandID)
+	if err != nil {
+		return err
+	}
+
+	if only != "all" && !commands.InvocationsFinished(invocations) {
+		// Invocations still running would run twice
+		return fmt.Errorf("command %s is not finished yet, wait for it or cancel it first (--only=all runs it again on all the instances anyway)", commandID)
+	}
+
+	instanceIDs, err := commands.InstanceIDsByStatus(invocations, only)
+	if err != nil {
+		return err
+	}
+	if len(instanceIDs) == 0 {
+		log.Printf("[INFO] There are no instances to run the command again on")
+		return nil
+	}
+	opts.InstanceIDs = instanceIDs
+
+	// The same version runs again, or the default version for commands recorded without the version
+	doc, err := docClient.GetVersion(documents.ConvertFromSSMName(opts.DocumentName), opts.DocumentVersion)
+	if err != nil {
+		return err
+	}
+	opts.DocumentVersion = doc.Version
+	opts.ScriptS3Key = doc.ScriptS3Key
+
+	if err := documents.ValidateParameters(doc.Parameters, opts.Parameters); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] %s will run again under max concurrency %s and max errors %s", opts.DocumentName, opts.MaxConcurrency, opts.MaxErrors)
+	for k, v := range opts.Parameters {
+		log.Printf("[INFO] Parameter %s=%s", k, v)
+	}
+
+	instances, err := cmdClient.GetInstances(instanceIDs, nil)
+	if err != nil {
+		return err
+	}
+	if len(instances) < len(instanceIDs) {
+		log.Printf("[WARN] %d instances are no longer managed by SSM", len(instanceIDs)-len(instances))
+	}
+	if len(instances) == 0 {
+		return errors.New("no instances are found")
+	}
+
+	printTargetInstances(instances)
+
+	cont, err := confirm(false)
+	if err != nil {
+		return err
+	}
+	if !cont {
+		return nil
+	}
+
+	command, invocations, err := sendAndWait(awsf, cmdClient, opts, true)
+	if err != nil {
+		return err
+	}
+
+	if err := printCommandResult(command, invocations); err != nil {
+		return err
+	}
+
+	return invocationsError(invocations)
+}
+
+func init() {
+	commandsCmd.AddCommand(commandsRerunCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// uploadCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	commandsRerunCmd.Flags().String("command-id", "", "Command ID to be run again")
+	commandsRerunCmd.Flags().String("only", "failed", "Instances to run the command again on (failed, timed-out or all)")
+	addFollowFlags(commandsRerunCmd)
+}
@@ -20,12 +20,13 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ryotarai/paramedic/documents"
-	"github.com/ryotarai/paramedic/outputlog"
+	"github.com/ryotarai/paramedic/rollout"
 
 	"github.com/ryotarai/paramedic/awsclient"
 	"github.com/ryotarai/paramedic/commands"
@@ -50,17 +51,35 @@ func commandsRunHandler(cmd *cobra.Command, args []string) error {
 	}
 
 	documentName := viper.GetString("document-name")
+	documentVersion := viper.GetString("document-version")
 	maxConcurrency := viper.GetString("max-concurrency")
 	maxErrors := viper.GetString("max-errors")
-	instanceIDs := viper.GetStringSlice("instance-ids")
-	tags := viper.GetStringSlice("tags")
+	targetGroup := viper.GetString("target-group")
 	outputLogGroup := viper.GetString("output-log-group")
 	signalS3Bucket := viper.GetString("signal-s3-bucket")
 	signalS3KeyPrefix := viper.GetString("signal-s3-key-prefix")
+	paramsFile := viper.GetString("params-file")
+	timeout := viper.GetDuration("timeout")
+	yes := viper.GetBool("yes")
+	wait := viper.GetBool("wait")
+	noWait := viper.GetBool("no-wait")
+	maxTargets := viper.GetInt("max-targets")
+
+	if wait && noWait {
+		return errors.New("--wait and --no-wait can't be specified at the same time")
+	}
 
-	documentName = documents.ConvertToSSMName(documentName)
+	paramPairs, err := cmd.Flags().GetStringArray("param")
+	if err != nil {
+		return err
+	}
+
+	params, err := loadParams(paramsFile, paramPairs)
+	if err != nil {
+		return err
+	}
 
-	awsf, err := awsclient.NewFactory()
+	awsf, err := newAWSFactory()
 	if err != nil {
 		return err
 	}
@@ -70,114 +89,347 @@ func commandsRunHandler(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	tagMap := map[string][]string{}
-	for _, t := range tags {
-		parts := strings.SplitN(t, "=", 2)
-		tagMap[parts[0]] = []string{parts[1]}
+	docClient, err := newDocumentsClient(awsf, "", "")
+	if err != nil {
+		return err
 	}
 
-	if len(instanceIDs) == 0 && len(tagMap) == 0 {
-		return errors.New("Both instance IDs and tags are not specified")
+	doc, err := docClient.GetVersion(documentName, documentVersion)
+	if err != nil {
+		return err
 	}
 
-	log.Printf("[INFO] %s will run under max concurrency %s and max errors %s", documentName, maxConcurrency, maxErrors)
+	if err := documents.ValidateParameters(doc.Parameters, params); err != nil {
+		return err
+	}
 
-	instances, err := cmdClient.GetInstances(instanceIDs, tagMap)
+	stagesFlag := viper.GetString("stages")
+	maxFailuresFlag := viper.GetString("stage-max-failures")
+	if stagesFlag == "" && doc.Rollout != nil {
+		stagesFlag = strings.Join(doc.Rollout.Stages, ",")
+		if !cmd.Flags().Changed("stage-max-failures") {
+			maxFailuresFlag = doc.Rollout.MaxFailures
+		}
+	}
+
+	stages, err := rollout.ParseStages(stagesFlag)
 	if err != nil {
 		return err
 	}
 
-	log.Println("[INFO] This command will be executed on the following instances")
-	for _, i := range instances {
-		log.Printf("[INFO]   %s (%s)", i.ComputerName, i.InstanceID)
+	threshold, err := rollout.ParseThreshold(maxFailuresFlag)
+	if err != nil {
+		return err
 	}
-	for _, i := range instances {
-		if i.PingStatus != "Online" {
-			log.Printf("[WARN] %s (%s) is in %s status", i.ComputerName, i.InstanceID, i.PingStatus)
+	if len(stages) > 0 && noWait {
+		return errors.New("--no-wait can't be used with a staged rollout")
+	}
+
+	if timeout != 0 {
+		if err := documents.ValidateTimeout(timeout); err != nil {
+			return err
+		}
+		if _, ok := doc.Parameters["executionTimeout"]; !ok {
+			return errors.New("the document does not support timeout, upload it again to override timeout")
 		}
 	}
 
-	cont, err := askContinue("Are you sure to continue?")
+	documentName = documents.ConvertToSSMName(documentName)
+
+	g, err := targetGroupFromFlags(cmd, awsf)
 	if err != nil {
 		return err
 	}
-	if !cont {
-		fmt.Println("Canceled.")
-		return nil
+	if err := requireTargets(g); err != nil {
+		return err
 	}
+	instanceIDs := g.InstanceIDs
 
-	startTime := time.Now()
-	command, err := cmdClient.Send(&commands.SendOptions{
+	tagExpr, filter, err := targetGroupTargets(g)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] %s (version %s) will run under max concurrency %s and max errors %s", documentName, doc.Version, maxConcurrency, maxErrors)
+	for k, v := range params {
+		log.Printf("[INFO] Parameter %s=%s", k, v)
+	}
+	if timeout != 0 {
+		log.Printf("[INFO] Execution timeout: %s", timeout)
+	}
+
+	instances, err := cmdClient.GetInstances(instanceIDs, tagExpr)
+	if err != nil {
+		return err
+	}
+	instances, err = cmdClient.FilterInstances(instances, filter)
+	if err != nil {
+		return err
+	}
+
+	identity, err := cmdClient.CallerIdentity()
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] Running as %s in %s", identity, awsf.Region())
+
+	printTargetInstances(instances)
+
+	// Targets resolved to instance IDs are not resolved again, so that the command runs on the instances shown above
+	resolvedIDs := []string{}
+	for _, i := range instances {
+		resolvedIDs = append(resolvedIDs, i.InstanceID)
+	}
+
+	sendOpts := &commands.SendOptions{
 		DocumentName:      documentName,
+		DocumentVersion:   doc.Version,
+		ScriptS3Key:       doc.ScriptS3Key,
 		InstanceIDs:       instanceIDs,
-		Tags:              tagMap,
+		Tags:              tagExpr,
+		Filter:            filter,
+		TargetGroup:       targetGroup,
 		MaxConcurrency:    maxConcurrency,
 		MaxErrors:         maxErrors,
 		OutputLogGroup:    outputLogGroup,
 		SignalS3Bucket:    signalS3Bucket,
 		SignalS3KeyPrefix: signalS3KeyPrefix,
-	})
+		Parameters:        params,
+		ExecutionTimeout:  timeout,
+
+		ResolvedInstanceIDs: resolvedIDs,
+	}
+
+	var waves [][]string
+	if len(stages) > 0 {
+		ids := []string{}
+		for _, i := range instances {
+			ids = append(ids, i.InstanceID)
+		}
+		sort.Strings(ids)
+		waves = rollout.SplitWaves(ids, stages)
+
+		log.Printf("[INFO] The command will be rolled out in %d waves (stages: %s, max failures per wave: %s)", len(waves), stagesString(stages), threshold)
+		for n, w := range waves {
+			log.Printf("[INFO]   Wave %d: %s", n+1, strings.Join(w, ", "))
+		}
+	}
+
+	if maxTargets > 0 && len(instances) > maxTargets {
+		log.Printf("[WARN] %d instances exceed --max-targets=%d", len(instances), maxTargets)
+		if yes {
+			return fmt.Errorf("%d instances exceed --max-targets=%d, confirm interactively without --yes or raise --max-targets", len(instances), maxTargets)
+		}
+	}
+
+	// --yes with too many instances is rejected above
+	cont, err := confirm(yes)
 	if err != nil {
 		return err
 	}
+	if !cont {
+		return nil
+	}
 
-	log.Printf("[INFO] A command '%s' started", command.CommandID)
-	log.Printf("[INFO] To see the command status, run 'paramedic commands show --command-id=%s'", command.CommandID)
-	log.Print("[INFO] Output logs will be shown below")
+	if len(stages) > 0 {
+		return runRollout(awsf, cmdClient, sendOpts, stages, waves, threshold, !wait)
+	}
 
-	logStreamPrefix := fmt.Sprintf("%s/", command.PcommandID)
-	reader := &outputlog.KinesisReader{
-		Kinesis:         awsf.Kinesis(),
-		StartTimestamp:  startTime,
-		LogGroup:        outputLogGroup,
-		LogStreamPrefix: logStreamPrefix,
+	if noWait {
+		command, err := cmdClient.Send(sendOpts)
+		if err != nil {
+			return err
+		}
+		log.Printf("[INFO] A command '%s' started", command.CommandID)
+		log.Printf("[INFO] To follow output logs, run 'paramedic commands log --command-id=%s --follow'", command.CommandID)
+		if structuredOutput() {
+			return printData(command)
+		}
+		fmt.Println(command.CommandID)
+		return nil
 	}
 
-	printer := outputlog.NewPrinter(os.Stdout)
+	command, invocations, err := sendAndWait(awsf, cmdClient, sendOpts, !wait)
+	if err != nil {
+		return err
+	}
 
-	stopCh := make(chan struct{})
-	go func() {
-		command := <-cmdClient.WaitStatus(command.CommandID, []string{"Success", "Cancelled", "Failed", "TimedOut", "Cancelling"})
-		log.Printf("[DEBUG] The command is now in %s status.", command.Status)
-		time.Sleep(10 * time.Second) // Wait for propagation of logs
-		stopCh <- struct{}{}
-	}()
+	if err := printCommandResult(command, invocations); err != nil {
+		return err
+	}
+
+	return invocationsError(invocations)
+}
 
-	exitCh := make(chan struct{})
-	go func() {
-		err := outputlog.Follow(reader, printer, stopCh)
+func runRollout(awsf *awsclient.Factory, cmdClient *commands.Client, opts *commands.SendOptions, stages []rollout.Stage, waves [][]string, threshold rollout.Threshold, follow bool) error {
+	rolloutID := ""
+	// Invocations and instances without invocations in all the waves
+	all := []*commands.CommandInvocation{}
+	missing := 0
+	for n, w := range waves {
+		log.Printf("[INFO] Starting wave %d of %d (%d instances)", n+1, len(waves), len(w))
+
+		waveOpts := *opts
+		waveOpts.InstanceIDs = w
+		waveOpts.Tags = nil
+		waveOpts.Filter = nil
+		waveOpts.ResolvedInstanceIDs = nil
+		waveOpts.Rollout = true
+		waveOpts.RolloutID = rolloutID
+		waveOpts.RolloutStages = stagesStrings(stages)
+
+		command, invocations, err := sendAndWait(awsf, cmdClient, &waveOpts, follow)
 		if err != nil {
-			log.Printf("[WARN] %s", err)
+			if n+1 < len(waves) {
+				log.Printf("[WARN] The rollout is stopped. %d waves are not started", len(waves)-n-1)
+			}
+			return err
+		}
+		if rolloutID == "" {
+			rolloutID = command.CommandID
+		}
+
+		if structuredOutput() {
+			if err := printCommandResult(command, invocations); err != nil {
+				return err
+			}
+		} else {
+			printInvocations(invocations)
+			fmt.Print("\n")
+		}
+
+		failed := 0
+		for _, i := range invocations {
+			if i.Status != "Success" {
+				failed++
+			}
+		}
+		// Instances without invocations are regarded as failed
+		if len(invocations) < len(w) {
+			failed += len(w) - len(invocations)
+			missing += len(w) - len(invocations)
+		}
+		all = append(all, invocations...)
+
+		if threshold.Exceeded(failed, len(w)) {
+			if err := printRollout(cmdClient, rolloutID); err != nil {
+				log.Printf("[WARN] %s", err)
+			}
+			code := invocationsExitCode(invocations)
+			if code == exitCodeSuccess {
+				// some instances have no invocation
+				code = exitCodeSomeFailed
+			}
+			return &exitError{
+				code: code,
+				msg:  fmt.Sprintf("the rollout is halted at wave %d of %d because %d of %d instances failed (max failures: %s)", n+1, len(waves), failed, len(w), threshold),
+			}
+		}
+	}
+
+	if err := printRollout(cmdClient, rolloutID); err != nil {
+		log.Printf("[WARN] %s", err)
+	}
+	log.Printf("[INFO] To see the rollout, run 'paramedic commands show --command-id=%s'", rolloutID)
+
+	// Failures within the threshold don't halt the rollout, but fail it like a command without stages
+	if missing == 0 {
+		return invocationsError(all)
+	}
+	code := invocationsExitCode(all)
+	if code == exitCodeSuccess {
+		code = exitCodeSomeFailed
+		if len(all) == 0 {
+			code = exitCodeAllFailed
 		}
-		exitCh <- struct{}{}
-	}()
+	}
+	failed := missing
+	for _, i := range all {
+		if i.Status != "Success" {
+			failed++
+		}
+	}
+	return &exitError{
+		code: code,
+		msg:  fmt.Sprintf("the command did not succeed on %d of %d instances", failed, len(all)+missing),
+	}
+}
+
+// sendAndWait sends a command and waits until it finishes, printing its output logs if follow is true.
+// It returns an exitError if interrupted.
+func sendAndWait(awsf *awsclient.Factory, cmdClient *commands.Client, opts *commands.SendOptions, follow bool) (*commands.Command, []*commands.CommandInvocation, error) {
+	startTime := time.Now()
+	command, err := cmdClient.Send(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("[INFO] A command '%s' started", command.CommandID)
+	log.Printf("[INFO] To see the command status, run 'paramedic commands show --command-id=%s'", command.CommandID)
+
+	exitCh := make(chan struct{}, 1)
+	if follow {
+		log.Print("[INFO] Output logs will be shown below")
+		go followCommand(awsf, cmdClient, command, opts.OutputLogGroup, startTime, exitCh)
+	} else {
+		log.Print("[INFO] Waiting for the command to finish")
+		go func() {
+			<-cmdClient.WaitStatus(command.CommandID, []string{"Success", "Cancelled", "Failed", "TimedOut", "Cancelling"})
+			exitCh <- struct{}{}
+		}()
+	}
 
 	// Wait until interrupted
-	sigCh := make(chan os.Signal)
+	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT)
+	defer signal.Stop(sigCh)
 
 	select {
 	case <-sigCh:
-		fmt.Print("Interrupted\n")
+		fmt.Fprint(os.Stderr, "Interrupted\n")
 		log.Printf("[INFO] To follow output logs, run 'paramedic commands log --command-id=%s --follow'", command.CommandID)
 		log.Printf("[WARN] The command is NOT cancelled. To cancel, run 'paramedic commands cancel --command-id=%s'", command.CommandID)
-		return nil
+		return nil, nil, &exitError{code: exitCodeInterrupted, msg: "interrupted"}
 	case <-exitCh:
 	}
 
 	invocations, err := cmdClient.GetInvocations(command.CommandID)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	fmt.Print("\n")
-	for _, i := range invocations {
-		fmt.Printf("%s (%s) %s\n", i.InstanceName, i.InstanceID, i.Status)
+	if err := cmdClient.UpdateRecord(command.CommandID, invocations); err != nil {
+		log.Printf("[WARN] Failed to update the command history: %s", err)
 	}
-	fmt.Print("\n")
-	fmt.Printf("To see output logs, run 'paramedic commands log --command-id=%s'\n", command.CommandID)
 
-	return nil
+	if !structuredOutput() {
+		fmt.Print("\n")
+	}
+	return command, invocations, nil
+}
+
+func printTargetInstances(instances []*commands.Instance) {
+	log.Println("[INFO] This command will be executed on the following instances")
+	for _, i := range instances {
+		log.Printf("[INFO]   %s (%s)", i.ComputerName, i.InstanceID)
+	}
+	for _, i := range instances {
+		if i.PingStatus != "Online" {
+			log.Printf("[WARN] %s (%s) is in %s status", i.ComputerName, i.InstanceID, i.PingStatus)
+		}
+	}
+}
+
+func stagesStrings(stages []rollout.Stage) []string {
+	strs := []string{}
+	for _, s := range stages {
+		strs = append(strs, s.String())
+	}
+	return strs
+}
+
+func stagesString(stages []rollout.Stage) string {
+	return strings.Join(stagesStrings(stages), ",")
 }
 
 func init() {
@@ -192,11 +444,22 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	commandsRunCmd.Flags().String("document-name", "", "Document name")
+	commandsRunCmd.Flags().String("document-version", "", "Version of the document to run (default: the default version)")
 	commandsRunCmd.Flags().String("output-log-group", "paramedic", "Log group")
 	commandsRunCmd.Flags().String("signal-s3-bucket", "", "S3 bucket to store a signal object")
 	commandsRunCmd.Flags().String("signal-s3-key-prefix", "signals/", "S3 key prefix to store a signal object")
 	commandsRunCmd.Flags().String("max-concurrency", "50", "The maximum number of instances that are allowed to execute the command at the same time")
 	commandsRunCmd.Flags().String("max-errors", "50", "The maximum number of errors allowed without the command failing")
-	commandsRunCmd.Flags().StringSlice("instance-ids", []string{}, "Instance IDs")
-	commandsRunCmd.Flags().StringSlice("tags", []string{}, "Instance tags (e.g. 'Role=app,Env=prod')")
+	addTargetFlags(commandsRunCmd)
+	commandsRunCmd.Flags().String("target-group", "", "Saved target group to run the command on")
+	commandsRunCmd.Flags().StringArray("param", []string{}, "Document parameter in key=value format (can be specified multiple times)")
+	commandsRunCmd.Flags().String("params-file", "", "YAML or JSON file which contains document parameters")
+	commandsRunCmd.Flags().Duration("timeout", 0, "Execution timeout overriding the document's (e.g. 10m)")
+	commandsRunCmd.Flags().BoolP("yes", "y", false, "Run without confirmation")
+	commandsRunCmd.Flags().Bool("wait", false, "Wait for the command to finish without following output logs")
+	commandsRunCmd.Flags().Bool("no-wait", false, "Exit as soon as the command is sent, printing its ID")
+	commandsRunCmd.Flags().Int("max-targets", 0, "Require interactive confirmation when the number of target instances exceeds this (0 means no limit)")
+	commandsRunCmd.Flags().String("stages", "", "Roll out the command in waves (e.g. '1,10%,50%,100%'), overriding the document's rollout")
+	commandsRunCmd.Flags().String("stage-max-failures", "0", "The maximum number (or percentage) of failed instances in each wave before the rollout is halted")
+	addFollowFlags(commandsRunCmd)
 }
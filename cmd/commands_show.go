@@ -16,8 +16,10 @@ package cmd
 
 import (
 	"fmt"
+	"log"
+	"strings"
 
-	"github.com/ryotarai/paramedic/awsclient"
+	"github.com/ryotarai/paramedic/commands"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -40,7 +42,7 @@ func commandsShowHandler(cmd *cobra.Command, args []string) error {
 	commandID := viper.GetString("command-id")
 	detail := viper.GetBool("detail")
 
-	awsf, err := awsclient.NewFactory()
+	awsf, err := newAWSFactory()
 	if err != nil {
 		return err
 	}
@@ -55,29 +57,141 @@ func commandsShowHandler(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	invocations, err := cmdClient.GetInvocations(commandID)
+	if err != nil {
+		return err
+	}
+
+	if err := cmdClient.UpdateRecord(commandID, invocations); err != nil {
+		log.Printf("[WARN] Failed to update the command history: %s", err)
+	}
+
+	if structuredOutput() {
+		result := &showResult{
+			commandResult: commandResult{
+				Command:     command,
+				Invocations: invocations,
+			},
+		}
+		if command.RolloutID != "" {
+			result.Rollout, err = getRollout(cmdClient, command.RolloutID)
+			if err != nil {
+				return err
+			}
+		}
+		return printData(result)
+	}
+
 	fmt.Printf("Command ID: %s\n", command.CommandID)
-	fmt.Printf("Document: %s\n", command.DocumentName)
+	if command.DocumentVersion != "" {
+		fmt.Printf("Document: %s (version %s)\n", command.DocumentName, command.DocumentVersion)
+	} else {
+		fmt.Printf("Document: %s\n", command.DocumentName)
+	}
 	fmt.Printf("Status: %s\n", command.Status)
 	fmt.Printf("Targets: %s\n", command.Targets)
+	if len(command.BatchCommandIDs) > 0 {
+		fmt.Printf("Batches: %s\n", strings.Join(command.BatchCommandIDs, ", "))
+	}
 	if detail {
 		fmt.Printf("Paramedic Command ID: %s\n", command.PcommandID)
 		fmt.Printf("OutputLogGroup: %s\n", command.OutputLogGroup)
 		fmt.Printf("OutputLogStreamPrefix: %s\n", command.OutputLogStreamPrefix)
 		fmt.Printf("SignalS3Bucket: %s\n", command.SignalS3Bucket)
 		fmt.Printf("SignalS3Key: %s\n", command.SignalS3Key)
+		if command.RerunOf != "" {
+			fmt.Printf("RerunOf: %s\n", command.RerunOf)
+		}
+		if command.ExecutionTimeout != "" {
+			fmt.Printf("ExecutionTimeout: %ss\n", command.ExecutionTimeout)
+		}
+	}
+
+	if command.RolloutID != "" {
+		fmt.Print("\n")
+		if err := printRollout(cmdClient, command.RolloutID); err != nil {
+			return err
+		}
 	}
+
 	fmt.Print("\nInstances:\n")
+	printInvocations(invocations)
 
-	invocations, err := cmdClient.GetInvocations(commandID)
+	return nil
+}
+
+type showResult struct {
+	commandResult `yaml:",inline"`
+	Rollout       *rolloutResult `json:"rollout,omitempty" yaml:"rollout,omitempty"`
+}
+
+type rolloutResult struct {
+	RolloutID string           `json:"rolloutId" yaml:"rolloutId"`
+	Stages    []string         `json:"stages" yaml:"stages"`
+	Waves     []*commandResult `json:"waves" yaml:"waves"`
+}
+
+func getRollout(cmdClient *commands.Client, rolloutID string) (*rolloutResult, error) {
+	stages, waves, err := cmdClient.GetRollout(rolloutID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &rolloutResult{
+		RolloutID: rolloutID,
+		Stages:    stages,
+		Waves:     []*commandResult{},
+	}
+	for _, w := range waves {
+		invocations, err := cmdClient.GetInvocations(w.CommandID)
+		if err != nil {
+			return nil, err
+		}
+		r.Waves = append(r.Waves, &commandResult{
+			Command:     w,
+			Invocations: invocations,
+		})
+	}
+	return r, nil
+}
+
+func printRollout(cmdClient *commands.Client, rolloutID string) error {
+	r, err := getRollout(cmdClient, rolloutID)
 	if err != nil {
 		return err
 	}
 
+	if structuredOutput() {
+		return printData(r)
+	}
+
+	fmt.Printf("Rollout: %s (stages: %s)\n", r.RolloutID, strings.Join(r.Stages, ","))
+	for n, w := range r.Waves {
+		counts := map[string]int{}
+		for _, i := range w.Invocations {
+			counts[i.Status]++
+		}
+		fmt.Printf("  Wave %d: %s %s (%s)\n", n+1, w.Command.CommandID, w.Command.Status, formatStatusCounts(counts))
+	}
+
+	return nil
+}
+
+func printInvocations(invocations []*commands.CommandInvocation) {
+	timedOut := 0
 	for _, i := range invocations {
+		if i.Status == "TimedOut" {
+			timedOut++
+			// StatusDetails tells whether delivery or execution timed out
+			fmt.Printf("%s (%s) %s (%s)\n", i.InstanceName, i.InstanceID, i.Status, i.StatusDetails)
+			continue
+		}
 		fmt.Printf("%s (%s) %s\n", i.InstanceName, i.InstanceID, i.Status)
 	}
 
-	return nil
+	if timedOut > 0 {
+		fmt.Printf("\n%d of %d instances timed out\n", timedOut, len(invocations))
+	}
 }
 
 func init() {
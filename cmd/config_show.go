@@ -0,0 +1,97 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configShowCmd = &cobra.Command{
+	Use:           "show",
+	Short:         "Show the resolved configuration and where each value comes from",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          configShowHandler,
+}
+
+type configValue struct {
+	Key    string      `json:"key" yaml:"key"`
+	Value  interface{} `json:"value" yaml:"value"`
+	Source string      `json:"source" yaml:"source"`
+}
+
+func configShowHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	envValues, err := environmentValues()
+	if err != nil {
+		return err
+	}
+
+	values := []*configValue{}
+	for _, k := range configKeys {
+		v := &configValue{Key: k.name, Value: viper.Get(k.name)}
+
+		_, inEnv := envValues[k.name]
+		f := cmd.Flags().Lookup(k.name)
+		switch {
+		case f != nil && f.Changed:
+			v.Source = "flag"
+		case inEnv:
+			v.Source = fmt.Sprintf("environment %s", selectedEnvironment())
+		case viper.InConfig(k.name):
+			v.Source = fmt.Sprintf("config file %s", viper.ConfigFileUsed())
+		default:
+			v.Source = "default"
+			v.Value = k.defaultValue
+		}
+
+		values = append(values, v)
+	}
+
+	if structuredOutput() {
+		return printData(values)
+	}
+
+	if env := selectedEnvironment(); env != "" {
+		fmt.Printf("Environment: %s\n\n", env)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, v := range values {
+		fmt.Fprintf(w, "%s\t%v\t%s\n", v.Key, v.Value, v.Source)
+	}
+	return w.Flush()
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// configShowCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// configShowCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+}
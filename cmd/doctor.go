@@ -0,0 +1,108 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ryotarai/paramedic/doctor"
+	"github.com/ryotarai/paramedic/outputlog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:           "doctor",
+	Short:         "Check AWS resources and instances paramedic depends on",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          doctorHandler,
+}
+
+func doctorHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	d := &doctor.Doctor{
+		Store:          newStore(awsf),
+		SSM:            awsf.SSM(),
+		CloudWatchLogs: awsf.CloudWatchLogs(),
+		Kinesis:        awsf.Kinesis(),
+		S3:             awsf.S3(),
+		OutputLogGroup: viper.GetString("output-log-group"),
+		KinesisStream:  viper.GetString("kinesis-stream"),
+		ScriptS3Bucket: viper.GetString("script-s3-bucket"),
+		SignalS3Bucket: viper.GetString("signal-s3-bucket"),
+		DocumentName:   viper.GetString("document-name"),
+		InstanceIDs:    viper.GetStringSlice("instance-ids"),
+	}
+
+	results := d.Run()
+
+	if structuredOutput() {
+		if err := printData(results); err != nil {
+			return err
+		}
+	} else {
+		printDoctorResults(results)
+	}
+
+	if n := doctor.Failed(results); n > 0 {
+		return &exitError{code: exitCodeError, msg: fmt.Sprintf("%d of %d checks failed", n, len(results))}
+	}
+	return nil
+}
+
+func printDoctorResults(results []*doctor.Result) {
+	for _, r := range results {
+		var mark string
+		switch r.Status {
+		case doctor.StatusPass:
+			mark = "OK  "
+		case doctor.StatusWarn:
+			mark = "WARN"
+		default:
+			mark = "FAIL"
+		}
+		fmt.Printf("[%s] %s: %s\n", mark, r.Check, r.Message)
+		if r.Hint != "" {
+			fmt.Printf("       %s\n", r.Hint)
+		}
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(doctorCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// doctorCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	doctorCmd.Flags().String("output-log-group", "paramedic", "Log group output logs are written to")
+	doctorCmd.Flags().String("kinesis-stream", outputlog.DefaultKinesisStreamName, "Kinesis stream output logs are delivered to")
+	doctorCmd.Flags().String("script-s3-bucket", "", "S3 bucket to store script files")
+	doctorCmd.Flags().String("signal-s3-bucket", "", "S3 bucket to store signal objects")
+	doctorCmd.Flags().String("document-name", "", "Document expected to exist")
+	doctorCmd.Flags().StringSlice("instance-ids", []string{}, "Instances to check SSM agent of")
+}
@@ -0,0 +1,106 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ryotarai/paramedic/documents"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var documentsDiffCmd = &cobra.Command{
+	Use:           "diff <files...>",
+	Short:         "Show changes uploading documents would make",
+	Args:          cobra.MinimumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          documentsDiffHandler,
+}
+
+func documentsDiffHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	if err := requireStringFlags([]string{"script-s3-bucket"}); err != nil {
+		return err
+	}
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	docClient, err := newDocumentsClient(awsf, viper.GetString("script-s3-bucket"), viper.GetString("script-s3-key-prefix"))
+	if err != nil {
+		return err
+	}
+
+	return planDocuments(docClient, args)
+}
+
+// planDocuments prints changes of documents and returns an exitError if any of them are new or changed
+func planDocuments(docClient *documents.Client, files []string) error {
+	plans := []*documents.Plan{}
+	for _, f := range files {
+		def, err := documents.LoadDefinition(f)
+		if err != nil {
+			return err
+		}
+		plan, err := docClient.Plan(def)
+		if err != nil {
+			return err
+		}
+		plans = append(plans, plan)
+	}
+
+	if structuredOutput() {
+		if err := printData(plans); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range plans {
+			if p.Status == documents.PlanNew {
+				fmt.Printf("%s: %s\n", p.Name, p.Status)
+			} else {
+				fmt.Printf("%s: %s (version %s)\n", p.Name, p.Status, p.Version)
+			}
+			fmt.Print(p.DocumentDiff)
+			fmt.Print(p.ScriptDiff)
+		}
+	}
+
+	counts := map[string]int{}
+	for _, p := range plans {
+		counts[p.Status]++
+	}
+	log.Printf("[INFO] %d new, %d changed, %d unchanged", counts[documents.PlanNew], counts[documents.PlanChanged], counts[documents.PlanUnchanged])
+
+	if counts[documents.PlanNew] > 0 || counts[documents.PlanChanged] > 0 {
+		return &exitError{
+			code: exitCodeChanged,
+			msg:  fmt.Sprintf("%d of %d documents will be changed", counts[documents.PlanNew]+counts[documents.PlanChanged], len(plans)),
+		}
+	}
+	return nil
+}
+
+func init() {
+	documentsCmd.AddCommand(documentsDiffCmd)
+
+	documentsDiffCmd.Flags().String("script-s3-bucket", "", "S3 bucket to store a script file")
+	documentsDiffCmd.Flags().String("script-s3-key-prefix", "scripts/", "S3 key prefix to store a script file")
+}
@@ -15,12 +15,12 @@
 package cmd
 
 import (
-	"log"
+	"fmt"
 
 	"github.com/ryotarai/paramedic/documents"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ssm"
-	"github.com/ryotarai/paramedic/awsclient"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -36,20 +36,25 @@ var documentsListCmd = &cobra.Command{
 func documentsListHandler(cmd *cobra.Command, args []string) error {
 	viper.BindPFlags(cmd.Flags())
 
-	awsFactory, err := awsclient.NewFactory()
+	awsFactory, err := newAWSFactory()
 	if err != nil {
 		return err
 	}
 
 	ssmClient := awsFactory.SSM()
 
+	docs := []*documentListing{}
 	err = ssmClient.ListDocumentsPages(&ssm.ListDocumentsInput{}, func(resp *ssm.ListDocumentsOutput, last bool) bool {
 		for _, i := range resp.DocumentIdentifiers {
 			if !documents.IsParamedicDocument(*i.Name) {
 				continue
 			}
-			name := documents.ConvertFromSSMName(*i.Name)
-			log.Printf("[INFO] - %s", name)
+			docs = append(docs, &documentListing{
+				Name:            documents.ConvertFromSSMName(*i.Name),
+				SSMName:         *i.Name,
+				DocumentVersion: aws.StringValue(i.DocumentVersion),
+				Owner:           aws.StringValue(i.Owner),
+			})
 		}
 		return true
 	})
@@ -57,9 +62,24 @@ func documentsListHandler(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if structuredOutput() {
+		return printData(docs)
+	}
+
+	for _, d := range docs {
+		fmt.Println(d.Name)
+	}
+
 	return nil
 }
 
+type documentListing struct {
+	Name            string `json:"name" yaml:"name"`
+	SSMName         string `json:"ssmName" yaml:"ssmName"`
+	DocumentVersion string `json:"documentVersion" yaml:"documentVersion"`
+	Owner           string `json:"owner" yaml:"owner"`
+}
+
 func init() {
 	documentsCmd.AddCommand(documentsListCmd)
 
@@ -0,0 +1,90 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var documentsRollbackCmd = &cobra.Command{
+	Use:           "rollback <name>",
+	Short:         "Change the default version of a document",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          documentsRollbackHandler,
+}
+
+func documentsRollbackHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	if err := requireStringFlags([]string{"to"}); err != nil {
+		return err
+	}
+
+	name := args[0]
+	to := viper.GetString("to")
+	yes := viper.GetBool("yes")
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	docClient, err := newDocumentsClient(awsf, "", "")
+	if err != nil {
+		return err
+	}
+
+	current, err := docClient.Get(name)
+	if err != nil {
+		return err
+	}
+	if current.Version == to {
+		log.Printf("[INFO] Version %s of %s is already the default", to, name)
+		return nil
+	}
+
+	// Validate the version exists before asking
+	if _, err := docClient.GetVersion(name, to); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] The default version of %s will be changed from %s to %s", name, current.Version, to)
+	cont, err := confirm(yes)
+	if err != nil {
+		return err
+	}
+	if !cont {
+		return nil
+	}
+
+	if err := docClient.SetDefaultVersion(name, to); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Version %s of %s is the default now", to, name)
+
+	return nil
+}
+
+func init() {
+	documentsCmd.AddCommand(documentsRollbackCmd)
+
+	documentsRollbackCmd.Flags().String("to", "", "Version to be the default")
+	documentsRollbackCmd.Flags().BoolP("yes", "y", false, "Change without confirmation")
+}
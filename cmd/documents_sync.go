@@ -0,0 +1,338 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ryotarai/paramedic/documents"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Tags added to documents uploaded by documents sync
+const (
+	documentSourceTag = "paramedic:source"
+	documentCommitTag = "paramedic:commit"
+)
+
+// Statuses of documents sync in addition to those of plans
+const (
+	syncOrphaned = "orphaned"
+	syncPruned   = "pruned"
+)
+
+var documentsSyncCmd = &cobra.Command{
+	Use:           "sync <dir>",
+	Short:         "Upload definitions in a directory and report documents not in it",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          documentsSyncHandler,
+}
+
+type syncResult struct {
+	Name   string `json:"name" yaml:"name"`
+	File   string `json:"file,omitempty" yaml:"file,omitempty"`
+	Status string `json:"status" yaml:"status"`
+	// Version is the version uploaded, which is empty if not uploaded
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// Tagged is true if an unchanged document is tagged with its source, as it was uploaded otherwise
+	Tagged bool   `json:"tagged,omitempty" yaml:"tagged,omitempty"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+
+	// prunable is whether the orphaned document was synced from the directory
+	prunable bool
+}
+
+func documentsSyncHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	if err := requireStringFlags([]string{"script-s3-bucket"}); err != nil {
+		return err
+	}
+
+	dir := args[0]
+	prune := viper.GetBool("prune")
+	dryRun := viper.GetBool("dry-run")
+	yes := viper.GetBool("yes")
+	concurrency := viper.GetInt("concurrency")
+	if concurrency < 1 {
+		return errors.New("concurrency must be greater than 0")
+	}
+
+	files, err := definitionFiles(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no definitions are found in %s", dir)
+	}
+
+	defs := map[string]*documents.Definition{}
+	results := []*syncResult{}
+	for _, f := range files {
+		def, err := documents.LoadDefinition(f)
+		if err != nil {
+			return fmt.Errorf("%s: %s", f, err)
+		}
+		if r, ok := findSyncResult(results, def.Name); ok {
+			return fmt.Errorf("%s and %s define the same document %s", r.File, f, def.Name)
+		}
+		defs[def.Name] = def
+		results = append(results, &syncResult{Name: def.Name, File: f})
+	}
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	docClient, err := newDocumentsClient(awsf, viper.GetString("script-s3-bucket"), viper.GetString("script-s3-key-prefix"))
+	if err != nil {
+		return err
+	}
+
+	tags := map[string]string{}
+	root, commit := gitSource(dir)
+	if commit != "" {
+		tags[documentCommitTag] = commit
+	}
+
+	eachConcurrently(results, concurrency, func(r *syncResult) {
+		plan, err := docClient.Plan(defs[r.Name])
+		if err != nil {
+			r.Error = err.Error()
+			return
+		}
+		r.Status = plan.Status
+		if dryRun {
+			return
+		}
+		if r.Status == documents.PlanUnchanged {
+			// Documents uploaded otherwise are tagged, so that they can be pruned once removed from the directory
+			current, err := docClient.Tags(r.Name)
+			if err != nil {
+				r.Error = err.Error()
+				return
+			}
+			source := sourcePath(root, r.File)
+			if current[documentSourceTag] == source {
+				return
+			}
+			if err := docClient.Tag(r.Name, map[string]string{documentSourceTag: source}); err != nil {
+				r.Error = err.Error()
+				return
+			}
+			r.Tagged = true
+			return
+		}
+
+		r.Version, err = docClient.Create(defs[r.Name], true)
+		if err != nil {
+			r.Error = err.Error()
+			return
+		}
+
+		t := map[string]string{documentSourceTag: sourcePath(root, r.File)}
+		for k, v := range tags {
+			t[k] = v
+		}
+		if err := docClient.Tag(r.Name, t); err != nil {
+			r.Error = err.Error()
+		}
+	})
+
+	names, err := docClient.List()
+	if err != nil {
+		return err
+	}
+	orphans := []*syncResult{}
+	for _, name := range names {
+		if _, ok := defs[name]; !ok {
+			orphans = append(orphans, &syncResult{Name: name, Status: syncOrphaned})
+		}
+	}
+
+	if prune && len(orphans) > 0 {
+		// Documents uploaded otherwise or synced from other directories are not pruned
+		sourceDir := path.Clean(sourcePath(root, dir))
+		eachConcurrently(orphans, concurrency, func(r *syncResult) {
+			tags, err := docClient.Tags(r.Name)
+			if err != nil {
+				r.Error = err.Error()
+				return
+			}
+			source, ok := tags[documentSourceTag]
+			r.prunable = ok && path.Dir(source) == sourceDir
+		})
+
+		prunables := []*syncResult{}
+		for _, o := range orphans {
+			if o.prunable {
+				log.Printf("[INFO] %s will be deleted", o.Name)
+				prunables = append(prunables, o)
+			} else if o.Error == "" {
+				log.Printf("[INFO] %s is kept, as it was not synced from %s", o.Name, dir)
+			}
+		}
+
+		if !dryRun && len(prunables) > 0 {
+			cont, err := confirm(yes)
+			if err != nil {
+				return err
+			}
+			if cont {
+				eachConcurrently(prunables, concurrency, func(r *syncResult) {
+					if err := docClient.Delete(r.Name); err != nil {
+						r.Error = err.Error()
+						return
+					}
+					r.Status = syncPruned
+				})
+			}
+		}
+	}
+	results = append(results, orphans...)
+
+	if structuredOutput() {
+		if err := printData(results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			switch {
+			case r.Error != "":
+				fmt.Printf("%s: %s (error: %s)\n", r.Name, r.Status, r.Error)
+			case r.Version != "":
+				fmt.Printf("%s: %s (uploaded version %s)\n", r.Name, r.Status, r.Version)
+			case r.Tagged:
+				fmt.Printf("%s: %s (tagged with the source)\n", r.Name, r.Status)
+			default:
+				fmt.Printf("%s: %s\n", r.Name, r.Status)
+			}
+		}
+	}
+
+	if len(orphans) > 0 && !prune {
+		log.Printf("[INFO] To delete documents not in %s, run with --prune", dir)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d documents failed to sync", failed, len(results))
+	}
+	return nil
+}
+
+// definitionFiles returns YAML files in a directory
+func definitionFiles(dir string) ([]string, error) {
+	files := []string{}
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func findSyncResult(results []*syncResult, name string) (*syncResult, bool) {
+	for _, r := range results {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// eachConcurrently calls fn for each result with at most concurrency goroutines
+func eachConcurrently(results []*syncResult, concurrency int, fn func(*syncResult)) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, r := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *syncResult) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(r)
+		}(r)
+	}
+	wg.Wait()
+}
+
+// gitSource returns the root of the Git repository a directory is in and its HEAD commit.
+// They are empty if the directory is not in a repository.
+func gitSource(dir string) (string, string) {
+	root, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		log.Printf("[DEBUG] %s is not in a Git repository: %s", dir, err)
+		return "", ""
+	}
+	commit, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		log.Printf("[DEBUG] Failed to get the commit of %s: %s", dir, err)
+		return "", ""
+	}
+	return strings.TrimSpace(string(root)), strings.TrimSpace(string(commit))
+}
+
+// sourcePath returns the path of a file relative to the repository root if possible
+func sourcePath(root, file string) string {
+	if root == "" {
+		return filepath.ToSlash(file)
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func init() {
+	documentsCmd.AddCommand(documentsSyncCmd)
+
+	documentsSyncCmd.Flags().String("script-s3-bucket", "", "S3 bucket to store a script file")
+	documentsSyncCmd.Flags().String("script-s3-key-prefix", "scripts/", "S3 key prefix to store a script file")
+	documentsSyncCmd.Flags().Bool("prune", false, "Delete paramedic documents which were synced from the directory but are no longer defined in it")
+	documentsSyncCmd.Flags().Bool("dry-run", false, "Only report what would be changed")
+	documentsSyncCmd.Flags().BoolP("yes", "y", false, "Delete documents without confirmation")
+	documentsSyncCmd.Flags().Int("concurrency", 4, "The number of documents synced at the same time")
+}
@@ -0,0 +1,144 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ryotarai/paramedic/commands"
+	"github.com/ryotarai/paramedic/documents"
+	"github.com/ryotarai/paramedic/localrun"
+	"github.com/ryotarai/paramedic/outputlog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var documentsTestCmd = &cobra.Command{
+	Use:           "test <definition.yaml>",
+	Short:         "Run a script of a document locally without AWS",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          documentsTestHandler,
+}
+
+func documentsTestHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	paramsFile := viper.GetString("params-file")
+	image := viper.GetString("image")
+	signalNo := viper.GetInt("signal")
+	signalAfter := viper.GetDuration("signal-after")
+
+	paramPairs, err := cmd.Flags().GetStringArray("param")
+	if err != nil {
+		return err
+	}
+
+	params, err := loadParams(paramsFile, paramPairs)
+	if err != nil {
+		return err
+	}
+
+	def, err := documents.LoadDefinition(args[0])
+	if err != nil {
+		return err
+	}
+
+	printer := outputlog.NewPrinter(os.Stdout)
+	printer.Format = outputFormat
+
+	runner := &localrun.Runner{
+		Definition: def,
+		Parameters: params,
+		Image:      image,
+		Printer:    printer,
+	}
+
+	runCommand, err := runner.Commands("script")
+	if err != nil {
+		return err
+	}
+	log.Print("[INFO] The script runs with the following commands, where paramedic-agent is replaced with the script")
+	for _, c := range runCommand {
+		log.Printf("[INFO]   %s", c)
+	}
+
+	if err := runner.Start(); err != nil {
+		return err
+	}
+	if image == "" {
+		log.Printf("[INFO] The script started in a subprocess")
+	} else {
+		log.Printf("[INFO] The script started in a container of %s", image)
+	}
+
+	// Interrupting sends the signal as 'paramedic commands cancel' does
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+
+	var signalCh <-chan time.Time
+	if signalAfter > 0 {
+		signalCh = time.After(signalAfter)
+	}
+
+	statusCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		status, err := runner.Wait()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		statusCh <- status
+	}()
+
+	for {
+		select {
+		case <-sigCh:
+			fmt.Fprint(os.Stderr, "Interrupted\n")
+		case <-signalCh:
+		case err := <-errCh:
+			return err
+		case status := <-statusCh:
+			log.Printf("[INFO] The script finished in %s status", status)
+			return invocationsError([]*commands.CommandInvocation{{
+				InstanceID: localrun.InstanceID,
+				Status:     status,
+			}})
+		}
+
+		log.Printf("[INFO] Sending signal %d to the script", signalNo)
+		if err := runner.Signal(syscall.Signal(signalNo)); err != nil {
+			log.Printf("[WARN] %s", err)
+		}
+	}
+}
+
+func init() {
+	documentsCmd.AddCommand(documentsTestCmd)
+
+	documentsTestCmd.Flags().StringArray("param", []string{}, "Document parameter in key=value format (can be specified multiple times)")
+	documentsTestCmd.Flags().String("params-file", "", "YAML or JSON file which contains document parameters")
+	documentsTestCmd.Flags().String("image", "", "Docker image to run the script in (runs in a local subprocess if empty)")
+	documentsTestCmd.Flags().Int("signal", 15, "Signal number to be sent to the script when interrupted or after --signal-after")
+	documentsTestCmd.Flags().Duration("signal-after", 0, "Send the signal to the script after this duration to test cancellation (0 means never)")
+}
@@ -17,7 +17,6 @@ package cmd
 import (
 	"log"
 
-	"github.com/ryotarai/paramedic/awsclient"
 	"github.com/ryotarai/paramedic/documents"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -42,8 +41,9 @@ func documentsUploadHandler(cmd *cobra.Command, args []string) error {
 
 	scriptS3Bucket := viper.GetString("script-s3-bucket")
 	scriptS3KeyPrefix := viper.GetString("script-s3-key-prefix")
+	noDefault := viper.GetBool("no-default")
 
-	awsf, err := awsclient.NewFactory()
+	awsf, err := newAWSFactory()
 	if err != nil {
 		return err
 	}
@@ -53,6 +53,10 @@ func documentsUploadHandler(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if viper.GetBool("plan") {
+		return planDocuments(docClient, args)
+	}
+
 	for _, arg := range args {
 		log.Printf("[INFO] Uploading %s", arg)
 		def, err := documents.LoadDefinition(arg)
@@ -60,9 +64,15 @@ func documentsUploadHandler(cmd *cobra.Command, args []string) error {
 			return err
 		}
 
-		err = docClient.Create(def)
+		version, err := docClient.Create(def, !noDefault)
 		if err != nil {
 			log.Printf("[WARN] %s", err)
+			continue
+		}
+		if noDefault {
+			log.Printf("[INFO] Version %s of %s is staged. To make it the default, run 'paramedic documents rollback %s --to=%s'", version, def.Name, def.Name, version)
+		} else {
+			log.Printf("[INFO] Version %s of %s is the default now", version, def.Name)
 		}
 	}
 
@@ -82,4 +92,6 @@ func init() {
 	// is called directly, e.g.:
 	uploadCmd.Flags().String("script-s3-bucket", "", "S3 bucket to store a script file")
 	uploadCmd.Flags().String("script-s3-key-prefix", "scripts/", "S3 key prefix to store a script file")
+	uploadCmd.Flags().Bool("no-default", false, "Upload a new version without making it the default version")
+	uploadCmd.Flags().Bool("plan", false, "Show changes instead of uploading, as 'paramedic documents diff' does")
 }
@@ -0,0 +1,74 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var documentsVersionsCmd = &cobra.Command{
+	Use:           "versions <name>",
+	Short:         "List versions of a document",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          documentsVersionsHandler,
+}
+
+func documentsVersionsHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	docClient, err := newDocumentsClient(awsf, "", "")
+	if err != nil {
+		return err
+	}
+
+	versions, err := docClient.Versions(args[0])
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return printData(versions)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tDEFAULT\tSCRIPT SHA256\tCREATED AT")
+	for _, v := range versions {
+		def := ""
+		if v.Default {
+			def = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Version, def, v.ScriptSha256, v.CreatedAt.Local().Format(time.RFC3339))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func init() {
+	documentsCmd.AddCommand(documentsVersionsCmd)
+}
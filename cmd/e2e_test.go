@@ -0,0 +1,555 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/ryotarai/paramedic/awsclient/fake"
+	"github.com/ryotarai/paramedic/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// execute runs paramedic with args and returns what is printed to stdout
+func execute(t *testing.T, args ...string) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	outCh := make(chan string)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		outCh <- string(b)
+	}()
+
+	RootCmd.SetArgs(args)
+	err = RootCmd.Execute()
+	w.Close()
+	return <-outCh, err
+}
+
+// useFake makes commands use fakes instead of AWS until the returned function is called
+func useFake() (*fake.AWS, func()) {
+	a := fake.New()
+	awsFactory = a.Factory()
+	pollInterval = time.Millisecond
+	logPropagationDelay = 0
+	return a, func() {
+		awsFactory = nil
+		pollInterval = 0
+		logPropagationDelay = 10 * time.Second
+		resetFlags(RootCmd)
+	}
+}
+
+// resetFlags sets flags of a command and its subcommands back to their defaults, since they persist between executions
+func resetFlags(c *cobra.Command) {
+	c.Flags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		switch f.Value.Type() {
+		case "stringSlice", "stringArray":
+			// Slices are appended to once set, so they are replaced with new ones
+			fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+			if f.Value.Type() == "stringSlice" {
+				fs.StringSlice(f.Name, nil, "")
+			} else {
+				fs.StringArray(f.Name, nil, "")
+			}
+			if def := strings.Trim(f.DefValue, "[]"); def != "" {
+				fs.Set(f.Name, def)
+			}
+			f.Value = fs.Lookup(f.Name).Value
+		default:
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+	for _, sub := range c.Commands() {
+		resetFlags(sub)
+	}
+}
+
+func TestEndToEnd(t *testing.T) {
+	a, reset := useFake()
+	defer reset()
+
+	a.SSM.AddInstance(&fake.Instance{ID: "i-aaa", Name: "app-1", Tags: map[string]string{"Role": "app"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-bbb", Name: "app-2", Tags: map[string]string{"Role": "app"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-ccc", Name: "db-1", Tags: map[string]string{"Role": "db"}})
+	a.SSM.Agent = func(instanceID string, command *ssm.Command) *fake.Execution {
+		return &fake.Execution{
+			Output: []string{"reloading nginx on " + instanceID},
+			Status: ssm.CommandInvocationStatusSuccess,
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "paramedic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	definition := filepath.Join(dir, "reload-nginx.yaml")
+	if err := ioutil.WriteFile(definition, []byte("description: Reload nginx\nscript: systemctl reload nginx\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execute(t, "setup", "--script-s3-bucket=paramedic", "--signal-s3-bucket=paramedic", "--yes"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := execute(t, "documents", "diff", "--script-s3-bucket=paramedic", definition)
+	if e, ok := err.(*exitError); !ok || e.code != exitCodeChanged || !strings.HasPrefix(out, "reload-nginx: new\n") {
+		t.Errorf("got %v and output:\n%s\nwant a new document", err, out)
+	}
+
+	if _, err := execute(t, "documents", "upload", "--script-s3-bucket=paramedic", definition); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := execute(t, "documents", "diff", "--script-s3-bucket=paramedic", definition); err != nil || out != "reload-nginx: unchanged (version 1)\n" {
+		t.Errorf("got %v and output:\n%s\nwant an unchanged document", err, out)
+	}
+	if keys := a.S3.Keys("paramedic", "scripts/reload-nginx-"); len(keys) != 1 {
+		t.Errorf("got scripts %v, want one", keys)
+	}
+
+	// Output logs are followed through Kinesis Streams
+	out, err = execute(t, "commands", "run", "--document-name=reload-nginx", "--tags=Role=app", "--signal-s3-bucket=paramedic", "--yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"reloading nginx on i-aaa", "reloading nginx on i-bbb", "app-1 (i-aaa) Success", "app-2 (i-bbb) Success"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output of commands run does not contain %q:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "i-ccc") {
+		t.Errorf("output of commands run contains an instance not targeted:\n%s", out)
+	}
+
+	records, _, err := newStore(awsFactory).ListCommands(&store.ListCommandsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Status != "Success" || records[0].StatusCounts["Success"] != 2 {
+		t.Fatalf("got records %+v, want one succeeded on 2 instances", records)
+	}
+	commandID := records[0].CommandID
+
+	out, err = execute(t, "commands", "log", "--command-id="+commandID, "--output-log-group=paramedic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out, "reloading nginx on") != 2 {
+		t.Errorf("commands log prints unexpected output:\n%s", out)
+	}
+
+	// A staged version runs only if specified
+	if err := ioutil.WriteFile(definition, []byte("description: Reload nginx\nscript: systemctl reload-or-restart nginx\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, "documents", "upload", "--script-s3-bucket=paramedic", "--no-default", definition); err != nil {
+		t.Fatal(err)
+	}
+	out, err = execute(t, "commands", "run", "--document-name=reload-nginx", "--document-version=2", "--tags=Role=app", "--signal-s3-bucket=paramedic", "--yes", "--no-wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commandID = strings.TrimSpace(out)
+	if v := a.SSM.DocumentVersion(commandID); v != "2" {
+		t.Errorf("got document version %s, want 2", v)
+	}
+	if r, err := newStore(awsFactory).GetCommand(commandID); err != nil || r.DocumentVersion != "2" {
+		t.Errorf("got %+v, %v, want document version 2 in the history", r, err)
+	}
+
+	if _, err := execute(t, "documents", "rollback", "reload-nginx", "--to=2", "--yes"); err != nil {
+		t.Fatal(err)
+	}
+	out, err = execute(t, "documents", "versions", "reload-nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 3 || !strings.HasPrefix(strings.Join(strings.Fields(lines[2]), " "), "2 * ") {
+		t.Errorf("got versions:\n%s\nwant version 2 to be the default", out)
+	}
+
+	// A command which keeps running is cancelled by the signal object
+	a.SSM.Agent = func(instanceID string, command *ssm.Command) *fake.Execution {
+		return &fake.Execution{}
+	}
+	out, err = execute(t, "commands", "run", "--document-name=reload-nginx", "--tags=Role=app", "--signal-s3-bucket=paramedic", "--yes", "--no-wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commandID = strings.TrimSpace(out)
+
+	if _, err := execute(t, "commands", "cancel", "--command-id="+commandID); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newStore(awsFactory).GetCommand(commandID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != "Cancelled" || r.StatusCounts["Cancelled"] != 2 {
+		t.Errorf("got status %s (%v), want Cancelled on 2 instances", r.Status, r.StatusCounts)
+	}
+	if keys := a.S3.Keys("paramedic", "signals/"); len(keys) == 0 {
+		t.Error("no signal object is put")
+	}
+}
+
+func TestDocumentsSync(t *testing.T) {
+	a, reset := useFake()
+	defer reset()
+
+	if _, err := execute(t, "setup", "--script-s3-bucket=paramedic", "--signal-s3-bucket=paramedic", "--yes"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "paramedic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, script string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".yaml"), []byte("script: "+script+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("foo", "echo foo")
+	write("bar", "echo bar")
+	out, err := execute(t, "documents", "sync", "--script-s3-bucket=paramedic", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "bar: new (uploaded version 1)\nfoo: new (uploaded version 1)\n"; out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+	if tags := a.SSM.DocumentTags("paramedic-foo"); tags["paramedic:source"] == "" {
+		t.Errorf("got tags %v, want the source", tags)
+	}
+
+	write("foo", "echo foo2")
+	os.Remove(filepath.Join(dir, "bar.yaml"))
+	write("baz", "echo baz")
+	out, err = execute(t, "documents", "sync", "--script-s3-bucket=paramedic", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "baz: new (uploaded version 1)\nfoo: changed (uploaded version 2)\nbar: orphaned\n"; out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	// Documents synced from other directories or uploaded otherwise are not pruned
+	other, err := ioutil.TempDir("", "paramedic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(other)
+	if err := ioutil.WriteFile(filepath.Join(other, "qux.yaml"), []byte("script: echo qux\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, "documents", "sync", "--script-s3-bucket=paramedic", other); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(other, "quux.yaml"), []byte("script: echo quux\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, "documents", "upload", "--script-s3-bucket=paramedic", filepath.Join(other, "quux.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A document uploaded from the directory otherwise is tagged once synced
+	write("corge", "echo corge")
+	if _, err := execute(t, "documents", "upload", "--script-s3-bucket=paramedic", filepath.Join(dir, "corge.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	out, err = execute(t, "documents", "sync", "--script-s3-bucket=paramedic", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "baz: unchanged\ncorge: unchanged (tagged with the source)\nfoo: unchanged\nbar: orphaned\nquux: orphaned\nqux: orphaned\n"; out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+	os.Remove(filepath.Join(dir, "corge.yaml"))
+
+	out, err = execute(t, "documents", "sync", "--script-s3-bucket=paramedic", "--prune", "--yes", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "baz: unchanged\nfoo: unchanged\nbar: pruned\ncorge: pruned\nquux: orphaned\nqux: orphaned\n"; out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+	if tags := a.SSM.DocumentTags("paramedic-bar"); len(tags) != 0 {
+		t.Errorf("paramedic-bar is not deleted")
+	}
+	if tags := a.SSM.DocumentTags("paramedic-qux"); len(tags) == 0 {
+		t.Errorf("paramedic-qux is deleted")
+	}
+}
+
+func TestCommandsRunFilter(t *testing.T) {
+	a, reset := useFake()
+	defer reset()
+
+	for n := 0; n < 60; n++ {
+		a.SSM.AddInstance(&fake.Instance{ID: fmt.Sprintf("i-%03d", n), Name: fmt.Sprintf("app-%d-1a", n), Tags: map[string]string{"Role": "app"}})
+	}
+	a.SSM.AddInstance(&fake.Instance{ID: "i-canary", Name: "app-canary-1a", Tags: map[string]string{"Role": "app", "Canary": "true"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-1c", Name: "app-0-1c", Tags: map[string]string{"Role": "app"}})
+
+	if _, err := execute(t, "setup", "--script-s3-bucket=paramedic", "--signal-s3-bucket=paramedic", "--yes"); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "paramedic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	definition := filepath.Join(dir, "echo.yaml")
+	if err := ioutil.WriteFile(definition, []byte("script: echo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, "documents", "upload", "--script-s3-bucket=paramedic", definition); err != nil {
+		t.Fatal(err)
+	}
+
+	// 59 instances are sent as 2 batches of instance IDs
+	if _, err := execute(t, "commands", "run", "--document-name=echo", "--tags=Role=app", "--name=app-*-1a",
+		"--exclude-instance-ids=i-000", "--exclude-tags=Canary", "--signal-s3-bucket=paramedic", "--yes"); err != nil {
+		t.Fatal(err)
+	}
+
+	records, _, err := newStore(awsFactory).ListCommands(&store.ListCommandsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	var r *store.CommandRecord
+	for _, record := range records {
+		if len(record.BatchCommandIDs) > 0 {
+			r = record
+		}
+	}
+	if r == nil || len(r.BatchCommandIDs) != 2 || r.BatchCommandIDs[0] != r.CommandID {
+		t.Fatalf("got records %v, want the first batch linked to the other", records)
+	}
+	for _, record := range records {
+		if record.BatchID != r.CommandID {
+			t.Errorf("got batch ID %q, want %q", record.BatchID, r.CommandID)
+		}
+	}
+	if ids := r.Targets["InstanceIds"]; len(ids) != 59 || ids[0] != "i-001" {
+		t.Errorf("got %d target instance IDs, want 59 excluding i-000, i-canary and i-1c", len(ids))
+	}
+	if r.StatusCounts["Success"] != 59 {
+		t.Errorf("got %v, want 59 succeeded", r.StatusCounts)
+	}
+
+	cmdClient, err := newCommandsClient(awsFactory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command, err := cmdClient.Get(r.CommandID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command.Status != "Success" || len(command.Targets["InstanceIds"]) != 59 {
+		t.Errorf("got %s on %d instances, want Success on 59", command.Status, len(command.Targets["InstanceIds"]))
+	}
+	invocations, err := cmdClient.GetInvocations(r.CommandID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invocations) != 59 {
+		t.Errorf("got %d invocations, want 59 of the batches", len(invocations))
+	}
+}
+
+func TestCommandsRunRollout(t *testing.T) {
+	a, reset := useFake()
+	defer reset()
+
+	for n := 0; n < 4; n++ {
+		a.SSM.AddInstance(&fake.Instance{ID: fmt.Sprintf("i-%03d", n), Name: fmt.Sprintf("app-%d", n), Tags: map[string]string{"Role": "app"}})
+	}
+	a.SSM.Agent = func(instanceID string, command *ssm.Command) *fake.Execution {
+		if instanceID == "i-003" {
+			return &fake.Execution{Status: ssm.CommandInvocationStatusFailed}
+		}
+		return &fake.Execution{Status: ssm.CommandInvocationStatusSuccess}
+	}
+
+	if _, err := execute(t, "setup", "--script-s3-bucket=paramedic", "--signal-s3-bucket=paramedic", "--yes"); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "paramedic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	definition := filepath.Join(dir, "echo.yaml")
+	if err := ioutil.WriteFile(definition, []byte("script: echo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, "documents", "upload", "--script-s3-bucket=paramedic", definition); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rollout completes with a failure within the threshold
+	_, err = execute(t, "commands", "run", "--document-name=echo", "--tags=Role=app", "--stages=1,100%", "--stage-max-failures=1",
+		"--signal-s3-bucket=paramedic", "--wait", "--yes")
+	if e, ok := err.(*exitError); !ok || e.code != exitCodeSomeFailed {
+		t.Errorf("got %v, want exit code %d", err, exitCodeSomeFailed)
+	}
+
+	records, _, err := newStore(awsFactory).ListCommands(&store.ListCommandsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Errorf("got %d records, want 2 waves", len(records))
+	}
+}
+
+func TestTargetGroups(t *testing.T) {
+	a, reset := useFake()
+	defer reset()
+
+	a.SSM.AddInstance(&fake.Instance{ID: "i-aaa", Name: "app-1", Tags: map[string]string{"Role": "app"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-bbb", Name: "app-2", Tags: map[string]string{"Role": "app"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-ccc", Name: "app-3", Tags: map[string]string{"Role": "app", "Canary": "true"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-ddd", Name: "db-1", Tags: map[string]string{"Role": "db"}})
+
+	if _, err := execute(t, "setup", "--script-s3-bucket=paramedic", "--signal-s3-bucket=paramedic", "--yes"); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "paramedic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	definition := filepath.Join(dir, "echo.yaml")
+	if err := ioutil.WriteFile(definition, []byte("script: echo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute(t, "documents", "upload", "--script-s3-bucket=paramedic", definition); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execute(t, "targets", "save", "app", "--tags=Role=app", "--exclude-tags=Canary"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := execute(t, "targets", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 || !strings.HasPrefix(strings.Join(strings.Fields(lines[1]), " "), "app --tags=Role=app --exclude-tags=Canary ") {
+		t.Errorf("got target groups:\n%s", out)
+	}
+	out, err = execute(t, "targets", "show", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "i-aaa") || !strings.Contains(out, "i-bbb") || strings.Contains(out, "i-ccc") || !strings.Contains(out, "Online") {
+		t.Errorf("targets show prints unexpected instances:\n%s", out)
+	}
+
+	if _, err := execute(t, "commands", "run", "--document-name=echo", "--target-group=app", "--exclude-instance-ids=i-bbb", "--signal-s3-bucket=paramedic", "--yes"); err != nil {
+		t.Fatal(err)
+	}
+	records, _, err := newStore(awsFactory).ListCommands(&store.ListCommandsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].TargetGroup != "app" || !reflect.DeepEqual(records[0].Targets["InstanceIds"], []string{"i-aaa"}) {
+		t.Errorf("got %+v, want a command on i-aaa by the target group", records)
+	}
+
+	if _, err := execute(t, "commands", "run", "--document-name=echo", "--target-group=app", "--tags=Role=db", "--signal-s3-bucket=paramedic", "--yes"); err == nil {
+		t.Error("--target-group with --tags returns no error")
+	}
+}
+
+func TestInstancesList(t *testing.T) {
+	a, reset := useFake()
+	defer reset()
+
+	a.SSM.AddInstance(&fake.Instance{ID: "i-aaa", Name: "ip-10-0-0-1", IPAddress: "10.0.0.1", Tags: map[string]string{"Name": "web-2", "Role": "app"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-bbb", Name: "ip-10-0-0-2", IPAddress: "10.0.0.2", Tags: map[string]string{"Name": "web-1", "Role": "app"}, OutdatedAgent: true})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-ccc", Name: "ip-10-0-0-3", Tags: map[string]string{"Name": "db-1", "Role": "db"}, PingStatus: ssm.PingStatusConnectionLost})
+	a.SSM.Agent = func(instanceID string, command *ssm.Command) *fake.Execution {
+		return &fake.Execution{Output: []string{"abcd"}, Status: ssm.CommandInvocationStatusSuccess}
+	}
+
+	out, err := execute(t, "instances", "list", "--tags=Role=app", "--show-tags=Role")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "ROLE") || !strings.HasPrefix(lines[1], "i-bbb") || !strings.HasPrefix(lines[2], "i-aaa") {
+		t.Errorf("got instances sorted by name:\n%s", out)
+	}
+	if got, want := strings.Fields(lines[1])[1:3], []string{"web-1", "ip-10-0-0-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Flags keep their values between executions
+	resetFlags(RootCmd)
+	out, err = execute(t, "instances", "list", "--ping-status=connectionlost", "--output=json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"instanceId":"i-ccc"`) || strings.Contains(out, "i-aaa") || strings.Contains(out, "i-bbb") {
+		t.Errorf("got instances in ConnectionLost:\n%s", out)
+	}
+
+	resetFlags(RootCmd)
+	out, err = execute(t, "instances", "list", "--check-agent", "--paramedic-agent-sha256=abcd", "--yes")
+	if err == nil {
+		t.Error("--check-agent returns no error despite problems")
+	}
+	for _, want := range []string{"SSM agent is ConnectionLost", "SSM agent 2.3.0.0 is outdated"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, out)
+		}
+	}
+	if _, err := execute(t, "instances", "list", "--check-agent", "--paramedic-agent-sha256=abcd", "--instance-ids=i-aaa", "--yes"); err != nil {
+		t.Errorf("got %s, want no problem on i-aaa", err)
+	}
+
+	// Declining the confirmation runs no command on the instances
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("n\n")
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+	resetFlags(RootCmd)
+	out, err = execute(t, "instances", "list", "--check-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "" {
+		t.Errorf("got output after declining the check:\n%s", out)
+	}
+}
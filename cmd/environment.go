@@ -0,0 +1,113 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ryotarai/paramedic/outputlog"
+	"github.com/ryotarai/paramedic/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// envName is the environment selected by --env
+var envName string
+
+// configKey is a config key which can be set for each environment
+type configKey struct {
+	name         string
+	defaultValue interface{}
+}
+
+var configKeys = []configKey{
+	{"profile", ""},
+	{"region", ""},
+	{"role-arn", ""},
+	{"external-id", ""},
+	{"role-session-name", "paramedic"},
+	{"mfa-serial", ""},
+	{"script-s3-bucket", ""},
+	{"signal-s3-bucket", ""},
+	{"output-log-group", "paramedic"},
+	{"kinesis-stream", outputlog.DefaultKinesisStreamName},
+	{"table-name", store.DefaultTableName},
+	{"history-ttl", "0s"},
+	{"max-concurrency", "50"},
+	{"require-confirmation", false},
+}
+
+// selectedEnvironment returns the name of the environment selected by --env or PARAMEDIC_ENV
+func selectedEnvironment() string {
+	if envName != "" {
+		return envName
+	}
+	return os.Getenv("PARAMEDIC_ENV")
+}
+
+// environmentValues returns values of the selected environment in the config file
+func environmentValues() (map[string]interface{}, error) {
+	env := selectedEnvironment()
+	if env == "" {
+		return map[string]interface{}{}, nil
+	}
+
+	key := fmt.Sprintf("environments.%s", env)
+	if !viper.IsSet(key) {
+		return nil, fmt.Errorf("environment '%s' is not defined in the config file", env)
+	}
+
+	values := viper.GetStringMap(key)
+	unknown := []string{}
+	for k := range values {
+		if !isConfigKey(k) {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("environment '%s' has unknown keys: %s", env, strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
+
+// applyEnvironment overrides config values with ones of the selected environment.
+// Flags given explicitly take precedence.
+func applyEnvironment(cmd *cobra.Command) error {
+	values, err := environmentValues()
+	if err != nil {
+		return err
+	}
+
+	for k, v := range values {
+		if f := cmd.Flags().Lookup(k); f != nil && f.Changed {
+			continue
+		}
+		viper.Set(k, v)
+	}
+	return nil
+}
+
+func isConfigKey(name string) bool {
+	for _, k := range configKeys {
+		if k.name == name {
+			return true
+		}
+	}
+	return false
+}
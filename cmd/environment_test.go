@@ -0,0 +1,74 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const testConfig = `
+signal-s3-bucket: default-signals
+output-log-group: default-logs
+environments:
+  prod:
+    region: us-west-2
+    signal-s3-bucket: prod-signals
+    require-confirmation: true
+  broken:
+    unknown-key: foo
+`
+
+func TestApplyEnvironment(t *testing.T) {
+	defer viper.Reset()
+	defer func() { envName = "" }()
+
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(bytes.NewBufferString(testConfig)); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("region", "", "")
+	cmd.Flags().Set("region", "ap-northeast-1")
+	viper.BindPFlags(cmd.Flags())
+
+	envName = "prod"
+	if err := applyEnvironment(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	examples := map[string]interface{}{
+		"region":               "ap-northeast-1",
+		"signal-s3-bucket":     "prod-signals",
+		"output-log-group":     "default-logs",
+		"require-confirmation": true,
+	}
+	for k, want := range examples {
+		if got := viper.Get(k); got != want {
+			t.Errorf("%s: got %v, want %v", k, got, want)
+		}
+	}
+
+	for _, env := range []string{"broken", "staging"} {
+		envName = env
+		if err := applyEnvironment(cmd); err == nil {
+			t.Errorf("%s: got no error", env)
+		}
+	}
+}
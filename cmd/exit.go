@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ryotarai/paramedic/commands"
+)
+
+// Exit codes of commands which run commands on instances
+const (
+	exitCodeSuccess     = 0
+	exitCodeError       = 1
+	exitCodeSomeFailed  = 2
+	exitCodeAllFailed   = 3
+	exitCodeTimedOut    = 4
+	exitCodeCancelled   = 5
+	exitCodeInterrupted = 130
+)
+
+// exitCodeChanged is the exit code of documents diff when any documents will be changed
+const exitCodeChanged = 2
+
+// exitError is an error with an exit code of the process
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string {
+	return e.msg
+}
+
+// invocationsExitCode derives an exit code from final statuses of invocations.
+// Cancelled takes precedence over timed out, which takes precedence over failed.
+func invocationsExitCode(invocations []*commands.CommandInvocation) int {
+	success, failed, timedOut, cancelled := 0, 0, 0, 0
+	for _, i := range invocations {
+		switch i.Status {
+		case "Success":
+			success++
+		case "TimedOut":
+			timedOut++
+		case "Cancelled", "Cancelling":
+			cancelled++
+		default:
+			failed++
+		}
+	}
+
+	switch {
+	case cancelled > 0:
+		return exitCodeCancelled
+	case timedOut > 0:
+		return exitCodeTimedOut
+	case failed > 0 && success == 0:
+		return exitCodeAllFailed
+	case failed > 0:
+		return exitCodeSomeFailed
+	}
+	return exitCodeSuccess
+}
+
+// invocationsError returns an exitError unless all invocations succeeded
+func invocationsError(invocations []*commands.CommandInvocation) error {
+	code := invocationsExitCode(invocations)
+	if code == exitCodeSuccess {
+		return nil
+	}
+
+	failed := 0
+	for _, i := range invocations {
+		if i.Status != "Success" {
+			failed++
+		}
+	}
+	return &exitError{
+		code: code,
+		msg:  fmt.Sprintf("the command did not succeed on %d of %d instances", failed, len(invocations)),
+	}
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/ryotarai/paramedic/commands"
+)
+
+func TestInvocationsExitCode(t *testing.T) {
+	examples := []struct {
+		statuses []string
+		want     int
+	}{
+		{statuses: []string{"Success", "Success"}, want: exitCodeSuccess},
+		{statuses: []string{"Success", "Failed"}, want: exitCodeSomeFailed},
+		{statuses: []string{"Failed", "Undeliverable"}, want: exitCodeAllFailed},
+		{statuses: []string{"Success", "TimedOut", "Failed"}, want: exitCodeTimedOut},
+		{statuses: []string{"Cancelled", "TimedOut"}, want: exitCodeCancelled},
+	}
+
+	for _, e := range examples {
+		invocations := []*commands.CommandInvocation{}
+		for _, s := range e.statuses {
+			invocations = append(invocations, &commands.CommandInvocation{Status: s})
+		}
+		if got := invocationsExitCode(invocations); got != e.want {
+			t.Errorf("invocationsExitCode(%v) = %d, want %d", e.statuses, got, e.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/ryotarai/paramedic/awsclient"
+	"github.com/ryotarai/paramedic/commands"
+	"github.com/ryotarai/paramedic/outputlog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// logPropagationDelay is how long output logs are followed after the command finishes
+var logPropagationDelay = 10 * time.Second
+
+const (
+	followSourceAuto       = "auto"
+	followSourceKinesis    = "kinesis"
+	followSourceCloudWatch = "cloudwatch"
+)
+
+func addFollowFlags(cmd *cobra.Command) {
+	cmd.Flags().String("kinesis-stream", outputlog.DefaultKinesisStreamName, "Kinesis stream output logs are delivered to")
+	cmd.Flags().String("follow-source", followSourceAuto, "Source to follow output logs from (auto, kinesis or cloudwatch). 'auto' uses CloudWatch Logs if the Kinesis stream does not exist")
+}
+
+// newFollowReader returns a reader to follow output logs according to --follow-source
+func newFollowReader(awsf *awsclient.Factory, outputLogGroup, logStreamPrefix string, startTime time.Time) (outputlog.Reader, error) {
+	source := viper.GetString("follow-source")
+	streamName := viper.GetString("kinesis-stream")
+	if streamName == "" {
+		streamName = outputlog.DefaultKinesisStreamName
+	}
+
+	switch source {
+	case "", followSourceAuto:
+		exists, err := kinesisStreamExists(awsf.Kinesis(), streamName)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			source = followSourceKinesis
+		} else {
+			log.Printf("[INFO] Kinesis stream '%s' is not found. Output logs will be polled from CloudWatch Logs", streamName)
+			source = followSourceCloudWatch
+		}
+	case followSourceKinesis, followSourceCloudWatch:
+	default:
+		return nil, fmt.Errorf("follow-source must be one of auto, kinesis and cloudwatch")
+	}
+
+	if source == followSourceKinesis {
+		return &outputlog.KinesisReader{
+			Kinesis:         awsf.Kinesis(),
+			StreamName:      streamName,
+			StartTimestamp:  startTime,
+			LogGroup:        outputLogGroup,
+			LogStreamPrefix: logStreamPrefix,
+		}, nil
+	}
+
+	return &outputlog.CloudWatchLogsPollingReader{
+		CloudWatchLogs:  awsf.CloudWatchLogs(),
+		StartTimestamp:  startTime,
+		LogGroup:        outputLogGroup,
+		LogStreamPrefix: logStreamPrefix,
+	}, nil
+}
+
+func kinesisStreamExists(k awsclient.Kinesis, streamName string) (bool, error) {
+	_, err := k.DescribeStream(&kinesis.DescribeStreamInput{
+		StreamName: aws.String(streamName),
+		Limit:      aws.Int64(1),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == kinesis.ErrCodeResourceNotFoundException {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// followCommand prints output logs of a command until it finishes and notifies exitCh
+func followCommand(awsf *awsclient.Factory, cmdClient *commands.Client, command *commands.Command, outputLogGroup string, startTime time.Time, exitCh chan struct{}) {
+	defer func() { exitCh <- struct{}{} }()
+
+	logStreamPrefix := fmt.Sprintf("%s/", command.PcommandID)
+	reader, err := newFollowReader(awsf, outputLogGroup, logStreamPrefix, startTime)
+	if err != nil {
+		log.Printf("[WARN] %s", err)
+		return
+	}
+
+	printer := outputlog.NewPrinter(os.Stdout)
+	printer.Format = outputFormat
+
+	stopCh := make(chan struct{})
+	go func() {
+		command := <-cmdClient.WaitStatus(command.CommandID, []string{"Success", "Cancelled", "Failed", "TimedOut", "Cancelling"})
+		log.Printf("[DEBUG] The command is now in %s status.", command.Status)
+		time.Sleep(logPropagationDelay)
+		stopCh <- struct{}{}
+	}()
+
+	err = outputlog.Follow(reader, printer, stopCh)
+	if err != nil {
+		log.Printf("[WARN] %s", err)
+	}
+}
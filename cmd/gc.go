@@ -0,0 +1,140 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ryotarai/paramedic/gc"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var gcCmd = &cobra.Command{
+	Use:           "gc",
+	Short:         "Delete scripts and signal objects which are no longer used",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          gcHandler,
+}
+
+type gcSummary struct {
+	Scripts     int          `json:"scripts" yaml:"scripts"`
+	ScriptBytes int64        `json:"scriptBytes" yaml:"scriptBytes"`
+	Signals     int          `json:"signals" yaml:"signals"`
+	SignalBytes int64        `json:"signalBytes" yaml:"signalBytes"`
+	DryRun      bool         `json:"dryRun" yaml:"dryRun"`
+	Objects     []*gc.Object `json:"objects" yaml:"objects"`
+}
+
+func gcHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	dryRun := viper.GetBool("dry-run")
+	yes := viper.GetBool("yes")
+	config := &gc.Config{
+		ScriptS3Bucket:    viper.GetString("script-s3-bucket"),
+		ScriptS3KeyPrefix: viper.GetString("script-s3-key-prefix"),
+		SignalS3Bucket:    viper.GetString("signal-s3-bucket"),
+		SignalS3KeyPrefix: viper.GetString("signal-s3-key-prefix"),
+		Retention:         viper.GetDuration("retention"),
+	}
+	if config.ScriptS3Bucket == "" && config.SignalS3Bucket == "" {
+		return errors.New("--script-s3-bucket or --signal-s3-bucket is required")
+	}
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+	docClient, err := newDocumentsClient(awsf, config.ScriptS3Bucket, config.ScriptS3KeyPrefix)
+	if err != nil {
+		return err
+	}
+
+	g := &gc.GC{
+		Documents: docClient,
+		Store:     newStore(awsf),
+		SSM:       awsf.SSM(),
+		S3:        awsf.S3(),
+		Config:    config,
+	}
+
+	objects, err := g.Plan()
+	if err != nil {
+		return err
+	}
+
+	summary := &gcSummary{DryRun: dryRun, Objects: objects}
+	for _, o := range objects {
+		switch o.Kind {
+		case gc.KindScript:
+			summary.Scripts++
+			summary.ScriptBytes += o.Size
+		case gc.KindSignal:
+			summary.Signals++
+			summary.SignalBytes += o.Size
+		}
+	}
+
+	if !structuredOutput() {
+		for _, o := range objects {
+			fmt.Printf("- s3://%s/%s (%d bytes)\n", o.Bucket, o.Key, o.Size)
+		}
+	}
+
+	if len(objects) > 0 && !dryRun {
+		cont, err := confirm(yes)
+		if err != nil {
+			return err
+		}
+		if !cont {
+			return nil
+		}
+
+		if err := g.Delete(objects); err != nil {
+			return err
+		}
+	}
+
+	if structuredOutput() {
+		return printData(summary)
+	}
+
+	verb := "Reclaimed"
+	if dryRun {
+		verb = "Would reclaim"
+	}
+	fmt.Printf("%s %d scripts (%d bytes) and %d signal objects (%d bytes)\n", verb, summary.Scripts, summary.ScriptBytes, summary.Signals, summary.SignalBytes)
+	if len(objects) > 0 && !dryRun {
+		log.Print("[INFO] GC is completed")
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(gcCmd)
+
+	gcCmd.Flags().String("script-s3-bucket", "", "S3 bucket scripts are stored in (empty to skip scripts)")
+	gcCmd.Flags().String("script-s3-key-prefix", "scripts/", "S3 key prefix of scripts")
+	gcCmd.Flags().String("signal-s3-bucket", "", "S3 bucket signal objects are stored in (empty to skip signal objects)")
+	gcCmd.Flags().String("signal-s3-key-prefix", "signals/", "S3 key prefix of signal objects")
+	gcCmd.Flags().Duration("retention", 30*24*time.Hour, "Scripts of commands run within this period and objects modified within it are kept")
+	gcCmd.Flags().Bool("dry-run", false, "Only show objects to be deleted")
+	gcCmd.Flags().BoolP("yes", "y", false, "Delete objects without confirmation")
+}
@@ -0,0 +1,225 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ryotarai/paramedic/commands"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var instancesListCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "List managed instances with their SSM agent",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          instancesListHandler,
+}
+
+// instanceSorters are orders instances can be sorted in by --sort-by
+var instanceSorters = map[string]func(a, b *commands.Instance) bool{
+	"name":          func(a, b *commands.Instance) bool { return a.Name() < b.Name() },
+	"id":            func(a, b *commands.Instance) bool { return a.InstanceID < b.InstanceID },
+	"computer-name": func(a, b *commands.Instance) bool { return a.ComputerName < b.ComputerName },
+	"ping-status":   func(a, b *commands.Instance) bool { return a.PingStatus < b.PingStatus },
+	"last-ping":     func(a, b *commands.Instance) bool { return a.LastPingAt.Before(b.LastPingAt) },
+	"platform":      func(a, b *commands.Instance) bool { return a.PlatformName < b.PlatformName },
+	"agent-version": func(a, b *commands.Instance) bool { return a.AgentVersion < b.AgentVersion },
+	"ip":            func(a, b *commands.Instance) bool { return a.IPAddress < b.IPAddress },
+}
+
+type instanceResult struct {
+	commands.Instance `yaml:",inline"`
+	// Problems are set by --check-agent
+	Problems []string `json:"problems,omitempty" yaml:"problems,omitempty"`
+}
+
+func instancesListHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	pingStatuses := viper.GetStringSlice("ping-status")
+	sortBy := viper.GetString("sort-by")
+	showTags := viper.GetStringSlice("show-tags")
+	checkAgent := viper.GetBool("check-agent")
+	yes := viper.GetBool("yes")
+
+	less, ok := instanceSorters[sortBy]
+	if !ok {
+		keys := []string{}
+		for k := range instanceSorters {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("--sort-by must be one of %s", strings.Join(keys, ", "))
+	}
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	g, err := targetGroupFromFlags(cmd, awsf)
+	if err != nil {
+		return err
+	}
+	tagExpr, filter, err := targetGroupTargets(g)
+	if err != nil {
+		return err
+	}
+
+	cmdClient, err := newCommandsClient(awsf)
+	if err != nil {
+		return err
+	}
+
+	instances, err := cmdClient.GetInstances(g.InstanceIDs, tagExpr)
+	if err != nil {
+		return err
+	}
+	instances, err = cmdClient.FilterInstances(instances, filter)
+	if err != nil {
+		return err
+	}
+	if len(pingStatuses) > 0 {
+		instances = filterByPingStatus(instances, pingStatuses)
+	}
+
+	if err := cmdClient.AddTags(instances); err != nil {
+		return err
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		return less(instances[i], instances[j])
+	})
+
+	problems := map[string][]string{}
+	if checkAgent {
+		// Checking paramedic-agent runs a command on the instances
+		log.Printf("[INFO] AWS-RunShellScript will run on the online Linux instances among %d instances to check paramedic-agent", len(instances))
+		cont, err := confirm(yes)
+		if err != nil {
+			return err
+		}
+		if !cont {
+			return nil
+		}
+
+		var commandIDs []string
+		problems, commandIDs, err = cmdClient.CheckAgents(instances, &commands.CheckAgentsOptions{
+			ParamedicAgentSha256: viper.GetString("paramedic-agent-sha256"),
+			Timeout:              viper.GetDuration("check-timeout"),
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range commandIDs {
+			fmt.Fprintf(os.Stderr, "paramedic-agent was checked by command %s\n", id)
+		}
+	}
+
+	if structuredOutput() {
+		results := []*instanceResult{}
+		for _, i := range instances {
+			results = append(results, &instanceResult{Instance: *i, Problems: problems[i.InstanceID]})
+		}
+		if err := printData(results); err != nil {
+			return err
+		}
+	} else {
+		printInstances(instances, showTags, checkAgent, problems)
+	}
+
+	if len(problems) > 0 {
+		return &exitError{code: exitCodeError, msg: fmt.Sprintf("%d of %d instances have problems with their agents", len(problems), len(instances))}
+	}
+	return nil
+}
+
+// filterByPingStatus returns instances whose ping status is one of statuses, case-insensitively
+func filterByPingStatus(instances []*commands.Instance, statuses []string) []*commands.Instance {
+	filtered := []*commands.Instance{}
+	for _, i := range instances {
+		for _, s := range statuses {
+			if strings.EqualFold(i.PingStatus, s) {
+				filtered = append(filtered, i)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func printInstances(instances []*commands.Instance, showTags []string, checkAgent bool, problems map[string][]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	header := []string{"INSTANCE ID", "NAME", "COMPUTER NAME", "PING STATUS", "LAST PING", "PLATFORM", "AGENT VERSION", "IP"}
+	for _, k := range showTags {
+		header = append(header, strings.ToUpper(k))
+	}
+	if checkAgent {
+		header = append(header, "PROBLEMS")
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, i := range instances {
+		platform := strings.TrimSpace(fmt.Sprintf("%s %s", i.PlatformName, i.PlatformVersion))
+		row := []string{
+			i.InstanceID,
+			orDash(i.Name()),
+			orDash(i.ComputerName),
+			i.PingStatus,
+			i.LastPingAt.Local().Format(time.RFC3339),
+			orDash(platform),
+			orDash(i.AgentVersion),
+			orDash(i.IPAddress),
+		}
+		for _, k := range showTags {
+			row = append(row, orDash(i.Tags[k]))
+		}
+		if checkAgent {
+			row = append(row, orDash(strings.Join(problems[i.InstanceID], "; ")))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	instancesCmd.AddCommand(instancesListCmd)
+
+	addTargetFlags(instancesListCmd)
+	instancesListCmd.Flags().String("target-group", "", "Saved target group to list instances of")
+	instancesListCmd.Flags().StringSlice("ping-status", []string{}, "Ping statuses to list instances in (e.g. 'ConnectionLost,Inactive')")
+	instancesListCmd.Flags().String("sort-by", "name", "Sort instances by name, id, computer-name, ping-status, last-ping, platform, agent-version or ip")
+	instancesListCmd.Flags().StringSlice("show-tags", []string{}, "Tag keys to show as columns")
+	instancesListCmd.Flags().Bool("check-agent", false, "Check SSM agent and paramedic-agent on instances, exiting non-zero if any problem is found")
+	instancesListCmd.Flags().String("paramedic-agent-sha256", "", "SHA256 of the expected paramedic-agent binary, with which --check-agent reports others as outdated")
+	instancesListCmd.Flags().Duration("check-timeout", time.Minute, "How long --check-agent waits for paramedic-agent to be checked")
+	instancesListCmd.Flags().BoolP("yes", "y", false, "Run the command of --check-agent without confirmation")
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ryotarai/paramedic/commands"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Output formats of --output
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+var outputFormat string
+
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputText, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("output must be one of %s, %s and %s", outputText, outputJSON, outputYAML)
+}
+
+// structuredOutput returns true if data is printed as JSON or YAML instead of text
+func structuredOutput() bool {
+	return outputFormat != outputText
+}
+
+// printData prints v to stdout as a JSON line or a YAML document,
+// so that multiple values can be printed in a stream
+func printData(v interface{}) error {
+	switch outputFormat {
+	case outputJSON:
+		return json.NewEncoder(os.Stdout).Encode(v)
+	case outputYAML:
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("---\n%s", b)
+		return nil
+	}
+	return fmt.Errorf("output %s is not structured", outputFormat)
+}
+
+// resultOutputType is the type of the command result printed after output logs of the command
+const resultOutputType = "result"
+
+// commandResult is the output of a command with its invocations
+type commandResult struct {
+	// Type is set when the result is printed to the same stream as output logs, whose type is outputlog.EventOutputType
+	Type        string                        `json:"type,omitempty" yaml:"type,omitempty"`
+	Command     *commands.Command             `json:"command" yaml:"command"`
+	Invocations []*commands.CommandInvocation `json:"invocations" yaml:"invocations"`
+}
+
+// printCommandResult prints invocations of a finished command
+func printCommandResult(command *commands.Command, invocations []*commands.CommandInvocation) error {
+	if structuredOutput() {
+		return printData(&commandResult{
+			Type:        resultOutputType,
+			Command:     command,
+			Invocations: invocations,
+		})
+	}
+
+	printInvocations(invocations)
+	fmt.Print("\n")
+	fmt.Printf("To see output logs, run 'paramedic commands log --command-id=%s'\n", command.CommandID)
+	return nil
+}
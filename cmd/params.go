@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// loadParams builds document parameters from a params file and key=value pairs.
+// Pairs take precedence over the file.
+func loadParams(file string, pairs []string) (map[string]string, error) {
+	params := map[string]string{}
+
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &params); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %s", file, err)
+		}
+	}
+
+	for _, p := range pairs {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("parameter '%s' must be in key=value format", p)
+		}
+		params[parts[0]] = parts[1]
+	}
+
+	return params, nil
+}
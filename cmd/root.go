@@ -23,6 +23,7 @@ import (
 
 	"github.com/hashicorp/logutils"
 	homedir "github.com/mitchellh/go-homedir"
+	"github.com/ryotarai/paramedic/store"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -42,13 +43,20 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Logs are written to stderr so that stdout has only data
 		filter := &logutils.LevelFilter{
 			Levels:   []logutils.LogLevel{"DEBUG", "INFO", "WARN", "ERROR"},
 			MinLevel: logutils.LogLevel(strings.ToUpper(logLevel)),
-			Writer:   os.Stdout,
+			Writer:   os.Stderr,
 		}
 		log.SetOutput(filter)
+
+		if err := applyEnvironment(cmd); err != nil {
+			return err
+		}
+
+		return validateOutputFormat()
 	},
 }
 
@@ -56,8 +64,12 @@ to quickly create a Cobra application.`,
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
+		code := exitCodeError
+		if e, ok := err.(*exitError); ok {
+			code = e.code
+		}
 		log.Printf("[ERROR] %s", err)
-		os.Exit(1)
+		os.Exit(code)
 	}
 }
 
@@ -69,6 +81,22 @@ func init() {
 	// will be global for your application.
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.paramedic.yaml)")
 	RootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "INFO", "Log level (one of DEBUG, INFO, WARN and ERROR)")
+	RootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format (one of text, json and yaml)")
+
+	RootCmd.PersistentFlags().StringVar(&envName, "env", "", "Environment defined in the config file (default is $PARAMEDIC_ENV)")
+
+	// AWS configuration, which can be set in the config file as well
+	RootCmd.PersistentFlags().String("profile", "", "AWS profile in the shared config")
+	RootCmd.PersistentFlags().String("region", "", "AWS region")
+	RootCmd.PersistentFlags().String("role-arn", "", "IAM role to be assumed")
+	RootCmd.PersistentFlags().String("external-id", "", "External ID to assume the role with")
+	RootCmd.PersistentFlags().String("role-session-name", "paramedic", "Session name to assume the role with")
+	RootCmd.PersistentFlags().String("mfa-serial", "", "Serial number of the MFA device to assume the role with (the token is prompted)")
+	RootCmd.PersistentFlags().String("table-name", store.DefaultTableName, "DynamoDB table to store the command history")
+	RootCmd.PersistentFlags().StringSlice("endpoint-url", []string{}, "Endpoint URL for all services, or SERVICE=URL for one of ssm, s3, dynamodb, logs, kinesis, sts, iam and ec2 (can be specified multiple times)")
+	for _, name := range []string{"profile", "region", "role-arn", "external-id", "role-session-name", "mfa-serial", "table-name", "endpoint-url"} {
+		viper.BindPFlag(name, RootCmd.PersistentFlags().Lookup(name))
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -109,6 +137,6 @@ func initConfig() {
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
@@ -0,0 +1,154 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ryotarai/paramedic/outputlog"
+	"github.com/ryotarai/paramedic/setup"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// setupCmd represents the setup command
+var setupCmd = &cobra.Command{
+	Use:           "setup",
+	Short:         "Create AWS resources paramedic uses",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          setupHandler,
+}
+
+func setupHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	if err := requireStringFlags([]string{"output-log-group", "script-s3-bucket", "signal-s3-bucket"}); err != nil {
+		return err
+	}
+
+	dryRun := viper.GetBool("dry-run")
+	yes := viper.GetBool("yes")
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	s := &setup.Setup{
+		Store:          newStore(awsf),
+		CloudWatchLogs: awsf.CloudWatchLogs(),
+		Kinesis:        awsf.Kinesis(),
+		IAM:            awsf.IAM(),
+		S3:             awsf.S3(),
+		Region:         awsf.Region(),
+		Config: &setup.Config{
+			TableReadCapacity:    viper.GetInt64("table-read-capacity"),
+			TableWriteCapacity:   viper.GetInt64("table-write-capacity"),
+			OutputLogGroup:       viper.GetString("output-log-group"),
+			LogRetentionDays:     viper.GetInt64("log-retention-days"),
+			KinesisStream:        viper.GetString("kinesis-stream"),
+			KinesisShardCount:    viper.GetInt64("kinesis-shard-count"),
+			SubscriptionRoleName: viper.GetString("subscription-role-name"),
+			ScriptS3Bucket:       viper.GetString("script-s3-bucket"),
+			SignalS3Bucket:       viper.GetString("signal-s3-bucket"),
+			SignalS3KeyPrefix:    viper.GetString("signal-s3-key-prefix"),
+			SignalExpirationDays: viper.GetInt64("signal-expiration-days"),
+		},
+	}
+
+	steps, err := s.Plan()
+	if err != nil {
+		return err
+	}
+
+	changes := 0
+	for _, st := range steps {
+		if st.Action != setup.ActionNone {
+			changes++
+		}
+	}
+
+	if structuredOutput() {
+		if err := printData(steps); err != nil {
+			return err
+		}
+	} else {
+		printSetupPlan(steps)
+	}
+
+	if changes == 0 {
+		log.Print("[INFO] All resources already exist")
+		return nil
+	}
+	if dryRun {
+		return nil
+	}
+
+	cont, err := confirm(yes)
+	if err != nil {
+		return err
+	}
+	if !cont {
+		return nil
+	}
+
+	if err := s.Apply(steps); err != nil {
+		return err
+	}
+
+	log.Print("[INFO] Setup is completed")
+	return nil
+}
+
+func printSetupPlan(steps []*setup.Step) {
+	for _, st := range steps {
+		switch st.Action {
+		case setup.ActionCreate:
+			fmt.Printf("+ %s %s\n", st.Resource, st.Name)
+		case setup.ActionUpdate:
+			fmt.Printf("~ %s %s\n", st.Resource, st.Name)
+		default:
+			fmt.Printf("  %s %s (exists)\n", st.Resource, st.Name)
+		}
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(setupCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// setupCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	setupCmd.Flags().Int64("table-read-capacity", 0, "Provisioned read capacity units of the DynamoDB table (0 means on-demand billing)")
+	setupCmd.Flags().Int64("table-write-capacity", 0, "Provisioned write capacity units of the DynamoDB table (0 means on-demand billing)")
+	setupCmd.Flags().String("output-log-group", "paramedic", "Log group output logs are written to")
+	setupCmd.Flags().Int64("log-retention-days", 30, "Retention of output logs in days (0 means forever)")
+	setupCmd.Flags().String("kinesis-stream", outputlog.DefaultKinesisStreamName, "Kinesis stream output logs are delivered to (empty to skip Kinesis Streams)")
+	setupCmd.Flags().Int64("kinesis-shard-count", 1, "The number of shards of the Kinesis stream")
+	setupCmd.Flags().String("subscription-role-name", "paramedic-logs", "IAM role CloudWatch Logs delivers output logs to Kinesis Streams with")
+	setupCmd.Flags().String("script-s3-bucket", "", "S3 bucket to store script files")
+	setupCmd.Flags().String("signal-s3-bucket", "", "S3 bucket to store signal objects")
+	setupCmd.Flags().String("signal-s3-key-prefix", "signals/", "S3 key prefix of signal objects")
+	setupCmd.Flags().Int64("signal-expiration-days", 7, "Days after which signal objects are deleted")
+	setupCmd.Flags().Bool("dry-run", false, "Only show the plan")
+	setupCmd.Flags().BoolP("yes", "y", false, "Create resources without confirmation")
+}
@@ -0,0 +1,92 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var storeMigrateCmd = &cobra.Command{
+	Use:           "migrate",
+	Short:         "Migrate the DynamoDB table to the schema of this version",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          storeMigrateHandler,
+}
+
+func storeMigrateHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	dryRun := viper.GetBool("dry-run")
+	yes := viper.GetBool("yes")
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	s := newStore(awsf)
+
+	pending, err := s.PendingMigrations()
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		log.Printf("[INFO] %s table is up to date", s.TableName())
+		return nil
+	}
+
+	for _, m := range pending {
+		fmt.Printf("%d: %s\n", m.Version, m.Description)
+	}
+	if dryRun {
+		return nil
+	}
+
+	cont, err := confirm(yes)
+	if err != nil {
+		return err
+	}
+	if !cont {
+		return nil
+	}
+
+	if err := s.Migrate(pending); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] %s table is migrated", s.TableName())
+	return nil
+}
+
+func init() {
+	storeCmd.AddCommand(storeMigrateCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// storeMigrateCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	storeMigrateCmd.Flags().Bool("dry-run", false, "Only show pending migrations")
+	storeMigrateCmd.Flags().BoolP("yes", "y", false, "Migrate without confirmation")
+}
@@ -0,0 +1,108 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"log"
+
+	"github.com/ryotarai/paramedic/awsclient"
+	"github.com/ryotarai/paramedic/commands"
+	"github.com/ryotarai/paramedic/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var targetsCmd = &cobra.Command{
+	Use:   "targets",
+	Short: "Manage saved target groups",
+}
+
+// addTargetFlags adds flags to target instances, which targetGroupFromFlags reads
+func addTargetFlags(c *cobra.Command) {
+	c.Flags().StringSlice("instance-ids", []string{}, "Instance IDs")
+	c.Flags().StringSlice("tags", []string{}, "Instance tags, ANDed (e.g. 'Role=app|worker,!Env=prod,Team')")
+	c.Flags().StringSlice("exclude-instance-ids", []string{}, "Instance IDs to exclude")
+	c.Flags().StringSlice("exclude-tags", []string{}, "Instance tags to exclude instances matching any of them (e.g. 'Env=prod,Canary')")
+	c.Flags().String("name", "", "Glob (e.g. 'app-*-1a') or regular expression between slashes (e.g. '/^app-[0-9]+$/') instance names match")
+}
+
+// targetGroupFromFlags returns targets specified by flags added by addTargetFlags.
+// If the command has --target-group and it is specified, the saved target group is loaded and exclusions are added to it.
+func targetGroupFromFlags(c *cobra.Command, awsf *awsclient.Factory) (*store.TargetGroupRecord, error) {
+	g := &store.TargetGroupRecord{
+		InstanceIDs:        viper.GetStringSlice("instance-ids"),
+		Tags:               viper.GetStringSlice("tags"),
+		ExcludeInstanceIDs: viper.GetStringSlice("exclude-instance-ids"),
+		ExcludeTags:        viper.GetStringSlice("exclude-tags"),
+		InstanceName:       viper.GetString("name"),
+	}
+
+	name := ""
+	if c.Flags().Lookup("target-group") != nil {
+		name = viper.GetString("target-group")
+	}
+	if name == "" {
+		return g, nil
+	}
+	if len(g.InstanceIDs) > 0 || len(g.Tags) > 0 || g.InstanceName != "" {
+		return nil, errors.New("--target-group can't be specified with --instance-ids, --tags or --name")
+	}
+
+	saved, err := newStore(awsf).GetTargetGroup(name)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] Target group %s: %s", name, targetGroupString(saved))
+	// Exclusions are added to those of the target group
+	saved.ExcludeInstanceIDs = append(saved.ExcludeInstanceIDs, g.ExcludeInstanceIDs...)
+	saved.ExcludeTags = append(saved.ExcludeTags, g.ExcludeTags...)
+	return saved, nil
+}
+
+// requireTargets returns an error if a target group targets no instance explicitly
+func requireTargets(g *store.TargetGroupRecord) error {
+	if len(g.InstanceIDs) == 0 && len(g.Tags) == 0 && g.InstanceName == "" {
+		return errors.New("None of instance IDs, tags and a name is specified")
+	}
+	return nil
+}
+
+// targetGroupTargets returns the tag expression and the filter of a target group
+func targetGroupTargets(g *store.TargetGroupRecord) (commands.TagExpression, *commands.InstanceFilter, error) {
+	tagExpr, err := commands.ParseTagExpression(g.Tags)
+	if err != nil {
+		return nil, nil, err
+	}
+	excludeTagExpr, err := commands.ParseTagExpression(g.ExcludeTags)
+	if err != nil {
+		return nil, nil, err
+	}
+	if g.InstanceName != "" {
+		if _, err := commands.NameMatcher(g.InstanceName); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return tagExpr, &commands.InstanceFilter{
+		ExcludeInstanceIDs: g.ExcludeInstanceIDs,
+		ExcludeTags:        excludeTagExpr,
+		Name:               g.InstanceName,
+	}, nil
+}
+
+func init() {
+	RootCmd.AddCommand(targetsCmd)
+}
@@ -0,0 +1,87 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ryotarai/paramedic/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var targetsListCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "List saved target groups",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          targetsListHandler,
+}
+
+func targetsListHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	groups, err := newStore(awsf).ListTargetGroups()
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return printData(groups)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTARGETS\tUPDATED AT")
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", g.Name, targetGroupString(g), g.UpdatedAt.Local().Format(time.RFC3339))
+	}
+	w.Flush()
+
+	return nil
+}
+
+// targetGroupString returns targets of a target group in flags of commands run
+func targetGroupString(g *store.TargetGroupRecord) string {
+	flags := []string{}
+	if len(g.InstanceIDs) > 0 {
+		flags = append(flags, "--instance-ids="+strings.Join(g.InstanceIDs, ","))
+	}
+	if len(g.Tags) > 0 {
+		flags = append(flags, "--tags="+strings.Join(g.Tags, ","))
+	}
+	if g.InstanceName != "" {
+		flags = append(flags, "--name="+g.InstanceName)
+	}
+	if len(g.ExcludeInstanceIDs) > 0 {
+		flags = append(flags, "--exclude-instance-ids="+strings.Join(g.ExcludeInstanceIDs, ","))
+	}
+	if len(g.ExcludeTags) > 0 {
+		flags = append(flags, "--exclude-tags="+strings.Join(g.ExcludeTags, ","))
+	}
+	return strings.Join(flags, " ")
+}
+
+func init() {
+	targetsCmd.AddCommand(targetsListCmd)
+}
@@ -0,0 +1,78 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var targetsSaveCmd = &cobra.Command{
+	Use:           "save <name>",
+	Short:         "Save targets as a target group shared in the command history table",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          targetsSaveHandler,
+}
+
+func targetsSaveHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	g, err := targetGroupFromFlags(cmd, awsf)
+	if err != nil {
+		return err
+	}
+	g.Name = args[0]
+	g.UpdatedAt = time.Now()
+	if err := requireTargets(g); err != nil {
+		return err
+	}
+	if _, _, err := targetGroupTargets(g); err != nil {
+		return err
+	}
+
+	cmdClient, err := newCommandsClient(awsf)
+	if err != nil {
+		return err
+	}
+
+	g.UpdatedBy, err = cmdClient.CallerIdentity()
+	if err != nil {
+		return err
+	}
+
+	if err := newStore(awsf).PutTargetGroup(g); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Target group '%s' is saved", g.Name)
+	log.Printf("[INFO] To run a command on it, run 'paramedic commands run --target-group=%s'", g.Name)
+	return nil
+}
+
+func init() {
+	targetsCmd.AddCommand(targetsSaveCmd)
+
+	addTargetFlags(targetsSaveCmd)
+}
@@ -0,0 +1,94 @@
+// Copyright © 2017 Ryota Arai <ryota.arai@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ryotarai/paramedic/commands"
+	"github.com/ryotarai/paramedic/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var targetsShowCmd = &cobra.Command{
+	Use:           "show <name>",
+	Short:         "Show a target group and the instances it targets now",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          targetsShowHandler,
+}
+
+type targetGroupResult struct {
+	store.TargetGroupRecord `yaml:",inline"`
+	Instances               []*commands.Instance `json:"instances" yaml:"instances"`
+}
+
+func targetsShowHandler(cmd *cobra.Command, args []string) error {
+	viper.BindPFlags(cmd.Flags())
+
+	awsf, err := newAWSFactory()
+	if err != nil {
+		return err
+	}
+
+	g, err := newStore(awsf).GetTargetGroup(args[0])
+	if err != nil {
+		return err
+	}
+	tagExpr, filter, err := targetGroupTargets(g)
+	if err != nil {
+		return err
+	}
+
+	cmdClient, err := newCommandsClient(awsf)
+	if err != nil {
+		return err
+	}
+
+	instances, err := cmdClient.GetInstances(g.InstanceIDs, tagExpr)
+	if err != nil {
+		return err
+	}
+	instances, err = cmdClient.FilterInstances(instances, filter)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return printData(&targetGroupResult{TargetGroupRecord: *g, Instances: instances})
+	}
+
+	fmt.Printf("Name: %s\n", g.Name)
+	fmt.Printf("Targets: %s\n", targetGroupString(g))
+	fmt.Printf("Updated: %s by %s\n", g.UpdatedAt.Local(), g.UpdatedBy)
+	fmt.Print("\n")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE ID\tNAME\tPING STATUS")
+	for _, i := range instances {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", i.InstanceID, i.ComputerName, i.PingStatus)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func init() {
+	targetsCmd.AddCommand(targetsShowCmd)
+}
@@ -27,6 +27,13 @@ var versionCmd = &cobra.Command{
 	Short: "Show version",
 	Long:  "Show version",
 	Run: func(cmd *cobra.Command, args []string) {
+		if structuredOutput() {
+			printData(map[string]string{
+				"version":   paramedic.Version,
+				"gitCommit": paramedic.GitCommit,
+			})
+			return
+		}
 		fmt.Printf("paramedic v%s (%s)\n", paramedic.Version, paramedic.GitCommit)
 	},
 }
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// checkAgentScript prints "missing" or SHA256 of paramedic-agent found in PATH
+const checkAgentScript = `path=$(command -v paramedic-agent) || { echo missing; exit 0; }
+sha256sum "$path" | cut -d ' ' -f 1`
+
+// minCheckAgentTimeout is the minimum timeout SSM accepts for commands
+const minCheckAgentTimeout = 30 * time.Second
+
+type CheckAgentsOptions struct {
+	// ParamedicAgentSha256 is SHA256 of the expected paramedic-agent binary.
+	// If it is empty, only existence of paramedic-agent is checked.
+	ParamedicAgentSha256 string
+	// Timeout is how long to wait for the check command to finish on instances
+	Timeout time.Duration
+}
+
+// CheckAgents checks SSM agent and paramedic-agent on instances, and returns problems found by instance ID
+// and IDs of the commands which checked paramedic-agent.
+// paramedic-agent is checked by running AWS-RunShellScript on online Linux instances.
+func (c *Client) CheckAgents(instances []*Instance, opts *CheckAgentsOptions) (map[string][]string, []string, error) {
+	problems := map[string][]string{}
+	commandIDs := []string{}
+	ids := []string{}
+	for _, i := range instances {
+		if i.PingStatus != ssm.PingStatusOnline {
+			problems[i.InstanceID] = append(problems[i.InstanceID], fmt.Sprintf("SSM agent is %s", i.PingStatus))
+			continue
+		}
+		if !i.AgentIsLatest {
+			problems[i.InstanceID] = append(problems[i.InstanceID], fmt.Sprintf("SSM agent %s is outdated", i.AgentVersion))
+		}
+		if i.PlatformType != ssm.PlatformTypeLinux {
+			log.Printf("[DEBUG] paramedic-agent on %s (%s) is not checked", i.InstanceID, i.PlatformType)
+			continue
+		}
+		ids = append(ids, i.InstanceID)
+	}
+	// Instances are checked by a command per batch, as SSM accepts a limited number of instance IDs in a command
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > MaxInstanceIDs {
+			n = MaxInstanceIDs
+		}
+		commandID, err := c.checkParamedicAgents(ids[:n], opts, problems)
+		if err != nil {
+			return nil, nil, err
+		}
+		commandIDs = append(commandIDs, commandID)
+		ids = ids[n:]
+	}
+	return problems, commandIDs, nil
+}
+
+// checkParamedicAgents checks paramedic-agent on instances by a command, adds problems found to problems and returns the command ID
+func (c *Client) checkParamedicAgents(ids []string, opts *CheckAgentsOptions, problems map[string][]string) (string, error) {
+	// The command may time out later than opts.Timeout, since invocations still running then are cancelled
+	commandTimeout := opts.Timeout
+	if commandTimeout < minCheckAgentTimeout {
+		commandTimeout = minCheckAgentTimeout
+	}
+	resp, err := c.SSM.SendCommand(&ssm.SendCommandInput{
+		DocumentName:   aws.String("AWS-RunShellScript"),
+		Comment:        aws.String("paramedic: check paramedic-agent"),
+		Targets:        []*ssm.Target{instanceIDTarget(ids)},
+		MaxConcurrency: aws.String("100%"),
+		MaxErrors:      aws.String("100%"),
+		TimeoutSeconds: aws.Int64(int64(commandTimeout / time.Second)),
+		Parameters: map[string][]*string{
+			"commands": aws.StringSlice([]string{checkAgentScript}),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	commandID := *resp.Command.CommandId
+	log.Printf("[INFO] Checking paramedic-agent on %d instances by command %s", len(ids), commandID)
+
+	running, err := c.waitInvocations([]string{commandID}, ids, opts.Timeout)
+	if err != nil {
+		return "", err
+	}
+	if len(running) > 0 {
+		if _, err := c.SSM.CancelCommand(&ssm.CancelCommandInput{
+			CommandId:   aws.String(commandID),
+			InstanceIds: aws.StringSlice(running),
+		}); err != nil {
+			log.Printf("[WARN] Failed to cancel command %s: %s", commandID, err)
+		}
+	}
+
+	checked := map[string]bool{}
+	err = c.SSM.ListCommandInvocationsPages(&ssm.ListCommandInvocationsInput{
+		CommandId: aws.String(commandID),
+		Details:   aws.Bool(true),
+	}, func(resp *ssm.ListCommandInvocationsOutput, last bool) bool {
+		for _, i := range resp.CommandInvocations {
+			id := *i.InstanceId
+			checked[id] = true
+			if p := paramedicAgentProblem(i, opts.ParamedicAgentSha256); p != "" {
+				problems[id] = append(problems[id], p)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, id := range ids {
+		if !checked[id] {
+			problems[id] = append(problems[id], "paramedic-agent couldn't be checked (no invocation)")
+		}
+	}
+	return commandID, nil
+}
+
+// paramedicAgentProblem returns a problem of paramedic-agent found from the output of the check command
+func paramedicAgentProblem(i *ssm.CommandInvocation, sha256 string) string {
+	status := aws.StringValue(i.Status)
+	if status != ssm.CommandInvocationStatusSuccess || len(i.CommandPlugins) == 0 {
+		return fmt.Sprintf("paramedic-agent couldn't be checked (%s)", status)
+	}
+
+	output := strings.TrimSpace(aws.StringValue(i.CommandPlugins[0].Output))
+	switch {
+	case output == "missing":
+		return "paramedic-agent is missing"
+	case sha256 != "" && !strings.EqualFold(output, sha256):
+		return fmt.Sprintf("paramedic-agent is outdated (sha256 %s)", output)
+	}
+	return ""
+}
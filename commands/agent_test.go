@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/ryotarai/paramedic/awsclient/fake"
+)
+
+func TestCheckAgents(t *testing.T) {
+	a := fake.New()
+	a.SSM.AddInstance(&fake.Instance{ID: "i-ok", Name: "ok"})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-missing", Name: "missing"})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-outdated", Name: "outdated", OutdatedAgent: true})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-lost", Name: "lost", PingStatus: ssm.PingStatusConnectionLost})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-windows", Name: "windows", PlatformType: ssm.PlatformTypeWindows})
+	a.SSM.Agent = func(instanceID string, command *ssm.Command) *fake.Execution {
+		switch instanceID {
+		case "i-missing":
+			return &fake.Execution{Output: []string{"missing"}, Status: ssm.CommandInvocationStatusSuccess}
+		case "i-outdated":
+			return &fake.Execution{Output: []string{"0123"}, Status: ssm.CommandInvocationStatusSuccess}
+		}
+		return &fake.Execution{Output: []string{"abcd"}, Status: ssm.CommandInvocationStatusSuccess}
+	}
+	c := &Client{SSM: a.SSM, PollInterval: time.Millisecond}
+
+	instances, err := c.GetInstances(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, _, err := c.CheckAgents(instances, &CheckAgentsOptions{ParamedicAgentSha256: "ABCD", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"i-missing":  {"paramedic-agent is missing"},
+		"i-outdated": {"SSM agent 2.3.0.0 is outdated", "paramedic-agent is outdated (sha256 0123)"},
+		"i-lost":     {"SSM agent is ConnectionLost"},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("got %v, want %v", problems, want)
+	}
+}
+
+func TestCheckAgentsTimeout(t *testing.T) {
+	a := fake.New()
+	a.SSM.AddInstance(&fake.Instance{ID: "i-a", Name: "a"})
+	a.SSM.Agent = func(instanceID string, command *ssm.Command) *fake.Execution {
+		// keeps running until cancelled
+		return &fake.Execution{}
+	}
+	c := &Client{SSM: a.SSM, PollInterval: time.Millisecond}
+
+	instances, err := c.GetInstances(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, _, err := c.CheckAgents(instances, &CheckAgentsOptions{Timeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{"i-a": {"paramedic-agent couldn't be checked (Cancelled)"}}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("got %v, want %v", problems, want)
+	}
+}
+
+func TestCheckAgentsBatches(t *testing.T) {
+	a := fake.New()
+	for n := 0; n < MaxInstanceIDs+10; n++ {
+		a.SSM.AddInstance(&fake.Instance{ID: fmt.Sprintf("i-%03d", n), Name: fmt.Sprintf("app-%d", n)})
+	}
+	a.SSM.Agent = func(instanceID string, command *ssm.Command) *fake.Execution {
+		if instanceID == "i-055" {
+			return &fake.Execution{Output: []string{"missing"}, Status: ssm.CommandInvocationStatusSuccess}
+		}
+		return &fake.Execution{Output: []string{"abcd"}, Status: ssm.CommandInvocationStatusSuccess}
+	}
+	c := &Client{SSM: a.SSM, PollInterval: time.Millisecond}
+
+	instances, err := c.GetInstances(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, commandIDs, err := c.CheckAgents(instances, &CheckAgentsOptions{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{"i-055": {"paramedic-agent is missing"}}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("got %v, want %v", problems, want)
+	}
+	if len(commandIDs) != 2 {
+		t.Errorf("got %d commands, want 2", len(commandIDs))
+	}
+}
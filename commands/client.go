@@ -11,40 +11,194 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/google/uuid"
 	"github.com/ryotarai/paramedic/awsclient"
+	"github.com/ryotarai/paramedic/documents"
 	"github.com/ryotarai/paramedic/store"
 )
 
+// MaxInstanceIDs is the maximum number of instance IDs SSM accepts in a target.
+// Targets of a command are ANDed, so more instances are sent as batches of commands.
+const MaxInstanceIDs = 50
+
 type Client struct {
 	SSM   awsclient.SSM
 	S3    awsclient.S3
+	STS   awsclient.STS
+	EC2   awsclient.EC2
 	Store *store.Store
+
+	// PollInterval overrides intervals to check the status of commands if it is not zero
+	PollInterval time.Duration
 }
 
-// Get a command by ID
+func (c *Client) pollInterval(d time.Duration) time.Duration {
+	if c.PollInterval > 0 {
+		return c.PollInterval
+	}
+	return d
+}
+
+// Get a command by ID. The status and targets of a command sent as batches are those of all the batches.
 func (c *Client) Get(commandID string) (*Command, error) {
-	resp, err := c.SSM.ListCommands(&ssm.ListCommandsInput{
-		CommandId: aws.String(commandID),
-	})
+	sdkCommands, err := c.listCommands([]string{commandID})
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := c.Store.GetCommand(commandID)
+	if err != nil {
+		return nil, err
+	}
+
+	command := commandFromSDK(sdkCommands[0], r.PcommandID)
+	command.DocumentVersion = r.DocumentVersion
+	command.RolloutID = r.RolloutID
+	command.RerunOf = r.RerunOf
+	if len(r.BatchCommandIDs) > 0 {
+		rest, err := c.listCommands(r.BatchCommandIDs[1:])
+		if err != nil {
+			return nil, err
+		}
+		sdkCommands = append(sdkCommands, rest...)
+
+		command.BatchCommandIDs = r.BatchCommandIDs
+		command.Status = batchStatus(sdkCommands)
+		command.Targets = batchTargets(sdkCommands)
+	}
+	return command, nil
+}
+
+// listCommands returns SSM commands by their IDs
+func (c *Client) listCommands(commandIDs []string) ([]*ssm.Command, error) {
+	sdkCommands := []*ssm.Command{}
+	for _, id := range commandIDs {
+		resp, err := c.SSM.ListCommands(&ssm.ListCommandsInput{
+			CommandId: aws.String(id),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Commands) == 0 {
+			return nil, errors.New("command is not found")
+		}
+		sdkCommands = append(sdkCommands, resp.Commands[0])
+	}
+	return sdkCommands, nil
+}
+
+// batchCommandIDs returns IDs of the SSM commands a command is sent as
+func (c *Client) batchCommandIDs(commandID string) ([]string, error) {
+	if c.Store == nil {
+		return []string{commandID}, nil
+	}
+	r, err := c.Store.GetCommand(commandID)
 	if err != nil {
 		return nil, err
 	}
+	if len(r.BatchCommandIDs) > 0 {
+		return r.BatchCommandIDs, nil
+	}
+	return []string{commandID}, nil
+}
+
+// batchStatusOrder is the order of statuses the status of batches is chosen in.
+// Batches are in progress while any of them is, and otherwise fail if any of them does.
+var batchStatusOrder = []string{"InProgress", "Pending", "Cancelling", "Failed", "TimedOut", "Cancelled", "Success"}
 
-	if len(resp.Commands) == 0 {
-		return nil, errors.New("command is not found")
+func batchStatus(sdkCommands []*ssm.Command) string {
+	statuses := map[string]bool{}
+	for _, c := range sdkCommands {
+		statuses[aws.StringValue(c.Status)] = true
+	}
+	for _, st := range batchStatusOrder {
+		if statuses[st] {
+			return st
+		}
+	}
+	return aws.StringValue(sdkCommands[0].Status)
+}
+
+// batchTargets merges InstanceIds targets of batches. The other targets are the same in all the batches.
+func batchTargets(sdkCommands []*ssm.Command) map[string][]string {
+	targets := map[string][]string{}
+	for _, c := range sdkCommands {
+		for _, t := range c.Targets {
+			if aws.StringValue(t.Key) == "InstanceIds" {
+				targets[*t.Key] = append(targets[*t.Key], aws.StringValueSlice(t.Values)...)
+				continue
+			}
+			targets[*t.Key] = aws.StringValueSlice(t.Values)
+		}
 	}
+	return targets
+}
 
+// RerunOptions returns options to send a command again with the same document, parameters and options.
+// Targets are not set.
+func (c *Client) RerunOptions(commandID string) (*SendOptions, error) {
 	r, err := c.Store.GetCommand(commandID)
 	if err != nil {
 		return nil, err
 	}
+	if r.DocumentName == "" {
+		return nil, fmt.Errorf("command %s is not found in the history", commandID)
+	}
 
-	return commandFromSDK(resp.Commands[0], r.PcommandID), nil
+	return &SendOptions{
+		DocumentName:      documents.ConvertToSSMName(r.DocumentName),
+		DocumentVersion:   r.DocumentVersion,
+		MaxConcurrency:    r.MaxConcurrency,
+		MaxErrors:         r.MaxErrors,
+		OutputLogGroup:    r.OutputLogGroup,
+		SignalS3Bucket:    r.SignalS3Bucket,
+		SignalS3KeyPrefix: r.SignalS3KeyPrefix,
+		Parameters:        r.Parameters,
+		ExecutionTimeout:  time.Duration(r.ExecutionTimeout) * time.Second,
+		RerunOf:           commandID,
+	}, nil
 }
 
-// GetInvocations finds command invocations by command ID
+// GetRollout returns stages and commands of each wave of a staged rollout
+func (c *Client) GetRollout(rolloutID string) ([]string, []*Command, error) {
+	r, err := c.Store.GetCommand(rolloutID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmds := []*Command{}
+	for _, id := range r.RolloutCommandIDs {
+		command, err := c.Get(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		cmds = append(cmds, command)
+	}
+
+	return r.RolloutStages, cmds, nil
+}
+
+// GetInvocations finds command invocations by command ID, including those of all the batches of the command
 func (c *Client) GetInvocations(commandID string) ([]*CommandInvocation, error) {
+	ids, err := c.batchCommandIDs(commandID)
+	if err != nil {
+		return nil, err
+	}
+
+	invocations := []*CommandInvocation{}
+	for _, id := range ids {
+		is, err := c.listInvocations(id)
+		if err != nil {
+			return nil, err
+		}
+		invocations = append(invocations, is...)
+	}
+	return invocations, nil
+}
+
+// listInvocations returns invocations of an SSM command
+func (c *Client) listInvocations(commandID string) ([]*CommandInvocation, error) {
 	invocations := []*CommandInvocation{}
 
 	err := c.SSM.ListCommandInvocationsPages(&ssm.ListCommandInvocationsInput{
@@ -61,8 +215,9 @@ func (c *Client) GetInvocations(commandID string) ([]*CommandInvocation, error)
 	return invocations, nil
 }
 
-// Cancel a command by sending signal
-func (c *Client) Cancel(command *Command, signal int) error {
+// Cancel a command by sending signal.
+// If instanceIDs is not empty, only the invocations on the instances are signaled.
+func (c *Client) Cancel(command *Command, signal int, instanceIDs []string) error {
 	status := command.Status
 	if status != "Pending" && status != "InProgress" {
 		return fmt.Errorf("can't cancel the command because its status is %s", status)
@@ -77,21 +232,106 @@ func (c *Client) Cancel(command *Command, signal int) error {
 		return err
 	}
 
-	log.Printf("[DEBUG] Putting a signal object to s3://%s/%s", command.SignalS3Bucket, command.SignalS3Key)
-	_, err = c.S3.PutObject(&s3.PutObjectInput{
-		Body:   bytes.NewReader(j),
-		Bucket: aws.String(command.SignalS3Bucket),
-		Key:    aws.String(command.SignalS3Key),
-	})
+	keys := []string{command.SignalS3Key}
+	if len(instanceIDs) > 0 {
+		keys = []string{}
+		for _, id := range instanceIDs {
+			keys = append(keys, instanceSignalS3Key(command.SignalS3Key, id))
+		}
+	}
+
+	for _, key := range keys {
+		log.Printf("[DEBUG] Putting a signal object to s3://%s/%s", command.SignalS3Bucket, key)
+		_, err = c.S3.PutObject(&s3.PutObjectInput{
+			Body:   bytes.NewReader(j),
+			Bucket: aws.String(command.SignalS3Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForceCancel cancels invocations with SSM CancelCommand, which is used when the agent doesn't respond to signals.
+// If instanceIDs is empty, all invocations are cancelled.
+func (c *Client) ForceCancel(commandID string, instanceIDs []string) error {
+	ids, err := c.batchCommandIDs(commandID)
 	if err != nil {
 		return err
 	}
 
+	for _, id := range ids {
+		input := &ssm.CancelCommandInput{
+			CommandId: aws.String(id),
+		}
+		if len(instanceIDs) > 0 {
+			// Each batch is cancelled only on its own instances
+			invocations, err := c.listInvocations(id)
+			if err != nil {
+				return err
+			}
+			targeted := []string{}
+			for _, i := range invocations {
+				for _, instanceID := range instanceIDs {
+					if i.InstanceID == instanceID {
+						targeted = append(targeted, instanceID)
+					}
+				}
+			}
+			if len(targeted) == 0 {
+				continue
+			}
+			input.InstanceIds = aws.StringSlice(targeted)
+		}
+
+		if _, err := c.SSM.CancelCommand(input); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// GetInstances returns filtered instances
-func (c *Client) GetInstances(instanceIDs []string, tags map[string][]string) ([]*Instance, error) {
+// WaitInvocations waits for invocations on the instances (or all the invocations if instanceIDs is empty)
+// to finish until timeout, and returns IDs of instances still running
+func (c *Client) WaitInvocations(commandID string, instanceIDs []string, timeout time.Duration) ([]string, error) {
+	ids, err := c.batchCommandIDs(commandID)
+	if err != nil {
+		return nil, err
+	}
+	return c.waitInvocations(ids, instanceIDs, timeout)
+}
+
+// waitInvocations waits for invocations of SSM commands
+func (c *Client) waitInvocations(commandIDs []string, instanceIDs []string, timeout time.Duration) ([]string, error) {
+	interval := c.pollInterval(5 * time.Second)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		invocations := []*CommandInvocation{}
+		for _, id := range commandIDs {
+			is, err := c.listInvocations(id)
+			if err != nil {
+				return nil, err
+			}
+			invocations = append(invocations, is...)
+		}
+
+		running := runningInstanceIDs(invocations, instanceIDs)
+		if len(running) == 0 || !time.Now().Before(deadline) {
+			return running, nil
+		}
+
+		log.Printf("[DEBUG] %d invocations are still running", len(running))
+		time.Sleep(interval)
+	}
+}
+
+// GetInstances returns instances filtered by instance IDs and a tag expression.
+// Conditions which SSM can't filter by are evaluated by querying instances matching each of them.
+func (c *Client) GetInstances(instanceIDs []string, tags TagExpression) ([]*Instance, error) {
 	filters := []*ssm.InstanceInformationStringFilter{}
 
 	if len(instanceIDs) > 0 {
@@ -101,24 +341,66 @@ func (c *Client) GetInstances(instanceIDs []string, tags map[string][]string) ([
 		})
 	}
 
-	for k, v := range tags {
-		filters = append(filters, &ssm.InstanceInformationStringFilter{
-			Key:    aws.String(fmt.Sprintf("tag:%s", k)),
-			Values: aws.StringSlice(v),
-		})
+	rest := TagExpression{}
+	for _, t := range tags {
+		if t.Negate || len(t.Values) == 0 {
+			rest = append(rest, t)
+			continue
+		}
+		filters = append(filters, tagFilter(t))
+	}
+
+	instances, err := c.describeInstances(filters)
+	if err != nil {
+		return nil, err
 	}
 
+	for _, t := range rest {
+		if len(instances) == 0 {
+			break
+		}
+
+		matched, err := c.describeInstances([]*ssm.InstanceInformationStringFilter{tagFilter(t)})
+		if err != nil {
+			return nil, err
+		}
+		ids := map[string]bool{}
+		for _, i := range matched {
+			ids[i.InstanceID] = true
+		}
+
+		filtered := []*Instance{}
+		for _, i := range instances {
+			if ids[i.InstanceID] != t.Negate {
+				filtered = append(filtered, i)
+			}
+		}
+		instances = filtered
+	}
+
+	return instances, nil
+}
+
+func tagFilter(t *TagCondition) *ssm.InstanceInformationStringFilter {
+	if len(t.Values) == 0 {
+		return &ssm.InstanceInformationStringFilter{
+			Key:    aws.String("tag-key"),
+			Values: aws.StringSlice([]string{t.Key}),
+		}
+	}
+	return &ssm.InstanceInformationStringFilter{
+		Key:    aws.String(fmt.Sprintf("tag:%s", t.Key)),
+		Values: aws.StringSlice(t.Values),
+	}
+}
+
+func (c *Client) describeInstances(filters []*ssm.InstanceInformationStringFilter) ([]*Instance, error) {
 	instances := []*Instance{}
 	err := c.SSM.DescribeInstanceInformationPages(&ssm.DescribeInstanceInformationInput{
 		Filters: filters,
 	}, func(resp *ssm.DescribeInstanceInformationOutput, last bool) bool {
 		for _, info := range resp.InstanceInformationList {
-			i := &Instance{
-				InstanceID:   *info.InstanceId,
-				ComputerName: *info.ComputerName,
-				PingStatus:   *info.PingStatus,
-			}
-			instances = append(instances, i)
+			instances = append(instances, instanceFromSDK(info))
 		}
 		return true
 	})
@@ -131,7 +413,7 @@ func (c *Client) GetInstances(instanceIDs []string, tags map[string][]string) ([
 
 // GetInstanceIDToNameMap returns a map between instance ID and name
 func (c *Client) GetInstanceIDToNameMap() (map[string]string, error) {
-	instances, err := c.GetInstances([]string{}, map[string][]string{})
+	instances, err := c.GetInstances([]string{}, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -143,70 +425,291 @@ func (c *Client) GetInstanceIDToNameMap() (map[string]string, error) {
 	return m, nil
 }
 
+// CallerIdentity returns ARN of the IAM identity paramedic is running as
+func (c *Client) CallerIdentity() (string, error) {
+	resp, err := c.STS.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return *resp.Arn, nil
+}
+
 // SendOptions is options for Send
 type SendOptions struct {
 	DocumentName      string
+	DocumentVersion   string
+	ScriptS3Key       string
 	InstanceIDs       []string
-	Tags              map[string][]string
+	Tags              TagExpression
 	MaxConcurrency    string
 	MaxErrors         string
 	OutputLogGroup    string
 	SignalS3Bucket    string
 	SignalS3KeyPrefix string
+	Parameters        map[string]string
+	// ExecutionTimeout overrides the timeout of the document if it is not zero
+	ExecutionTimeout time.Duration
+	// Filter excludes instances, which are targeted by their IDs if it is not empty
+	Filter *InstanceFilter
+	// ResolvedInstanceIDs are the instances the targets were resolved to for confirmation.
+	// If the targets are resolved to instance IDs, the command runs on them without resolving the targets again.
+	ResolvedInstanceIDs []string
+
+	// Rollout is true if the command is a wave of a staged rollout
+	Rollout bool
+	// RolloutID is empty for the first wave
+	RolloutID     string
+	RolloutStages []string
+
+	// RerunOf is the ID of the command this command re-runs
+	RerunOf string
+	// TargetGroup is the name of the saved target group the command is run on
+	TargetGroup string
 }
 
 // Send a new command
 func (c *Client) Send(opts *SendOptions) (*Command, error) {
 	pcommandID := uuid.New().String()
 
-	targets := []*ssm.Target{}
-	if len(opts.InstanceIDs) > 0 {
-		targets = append(targets, &ssm.Target{
-			Key:    aws.String("InstanceIds"),
-			Values: aws.StringSlice(opts.InstanceIDs),
-		})
+	requestedBy, err := c.CallerIdentity()
+	if err != nil {
+		return nil, err
 	}
 
-	for k, v := range opts.Tags {
-		targets = append(targets, &ssm.Target{
-			Key:    aws.String(fmt.Sprintf("tag:%s", k)),
-			Values: aws.StringSlice(v),
+	batches, err := c.targetBatches(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := map[string][]*string{
+		"outputLogGroup":        []*string{aws.String(opts.OutputLogGroup)},
+		"outputLogStreamPrefix": []*string{aws.String(fmt.Sprintf("%s/", pcommandID))},
+		"signalS3Bucket":        []*string{aws.String(opts.SignalS3Bucket)},
+		"signalS3Key":           []*string{aws.String(fmt.Sprintf("%s%s.json", opts.SignalS3KeyPrefix, pcommandID))},
+	}
+	for k, v := range opts.Parameters {
+		parameters[k] = []*string{aws.String(v)}
+	}
+	if opts.ExecutionTimeout > 0 {
+		parameters["executionTimeout"] = []*string{aws.String(fmt.Sprintf("%d", int64(opts.ExecutionTimeout/time.Second)))}
+	}
+
+	// All the batches share the pcommand ID, so they are signaled and logged as one command
+	sdkCommands := []*ssm.Command{}
+	var sendErr error
+	for _, targets := range batches {
+		// TODO: write output to S3
+		input := &ssm.SendCommandInput{
+			DocumentName:   aws.String(opts.DocumentName),
+			Targets:        targets,
+			MaxConcurrency: aws.String(opts.MaxConcurrency),
+			MaxErrors:      aws.String(opts.MaxErrors),
+			Parameters:     parameters,
+		}
+		var resp *ssm.SendCommandOutput
+		if opts.DocumentVersion != "" {
+			// Pin the version parameters are validated against, which may not be the default
+			resp, err = c.SSM.SendCommandWithDocumentVersion(input, opts.DocumentVersion)
+		} else {
+			resp, err = c.SSM.SendCommand(input)
+		}
+		if err != nil {
+			sendErr = err
+			break
+		}
+		sdkCommands = append(sdkCommands, resp.Command)
+	}
+	if len(sdkCommands) == 0 {
+		return nil, sendErr
+	}
+
+	command := commandFromSDK(sdkCommands[0], pcommandID)
+	command.DocumentVersion = opts.DocumentVersion
+
+	batchCommandIDs := []string{}
+	if len(batches) > 1 {
+		for _, sc := range sdkCommands {
+			batchCommandIDs = append(batchCommandIDs, *sc.CommandId)
+		}
+		command.BatchCommandIDs = batchCommandIDs
+		command.Targets = batchTargets(sdkCommands)
+	}
+
+	rolloutID := ""
+	if opts.Rollout {
+		rolloutID = opts.RolloutID
+		if rolloutID == "" {
+			rolloutID = command.CommandID
+		}
+		command.RolloutID = rolloutID
+	}
+
+	startedAt := time.Now()
+	for i, sc := range sdkCommands {
+		batch := commandFromSDK(sc, pcommandID)
+		record := &store.CommandRecord{
+			CommandID:       batch.CommandID,
+			PcommandID:      pcommandID,
+			DocumentName:    batch.DocumentName,
+			DocumentVersion: opts.DocumentVersion,
+			ScriptS3Key:     opts.ScriptS3Key,
+			Targets:         batch.Targets,
+			TargetTags:      opts.Tags.Strings(),
+			RequestedBy:     requestedBy,
+			StartedAt:       startedAt,
+			Status:          batch.Status,
+
+			Parameters:        opts.Parameters,
+			ExecutionTimeout:  int64(opts.ExecutionTimeout / time.Second),
+			MaxConcurrency:    opts.MaxConcurrency,
+			MaxErrors:         opts.MaxErrors,
+			OutputLogGroup:    opts.OutputLogGroup,
+			SignalS3Bucket:    opts.SignalS3Bucket,
+			SignalS3KeyPrefix: opts.SignalS3KeyPrefix,
+			RerunOf:           opts.RerunOf,
+			TargetGroup:       opts.TargetGroup,
+			RolloutID:         rolloutID,
+		}
+		if len(batches) > 1 {
+			record.BatchID = command.CommandID
+			if i == 0 {
+				// The first batch stands for the whole command
+				record.BatchCommandIDs = batchCommandIDs
+				record.Targets = command.Targets
+			}
+		}
+		if i == 0 && opts.Rollout && opts.RolloutID == "" {
+			record.RolloutStages = opts.RolloutStages
+		}
+
+		err = c.Store.PutCommand(record)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Rollout {
+		err = c.Store.AddRolloutCommand(rolloutID, command.CommandID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if sendErr != nil {
+		return nil, fmt.Errorf("only %d of %d batches of command %s were sent: %s", len(sdkCommands), len(batches), command.CommandID, sendErr)
+	}
+
+	return command, nil
+}
+
+// targetBatches returns targets of each SSM command a command is sent as.
+// Instances are targeted by their IDs if SSM can't express the tag expression or the filter,
+// and instance IDs are split into batches of MaxInstanceIDs.
+func (c *Client) targetBatches(opts *SendOptions) ([][]*ssm.Target, error) {
+	if opts.resolvesTargets() {
+		ids := opts.ResolvedInstanceIDs
+		if len(ids) == 0 {
+			instances, err := c.GetInstances(opts.InstanceIDs, opts.Tags)
+			if err != nil {
+				return nil, err
+			}
+			instances, err = c.FilterInstances(instances, opts.Filter)
+			if err != nil {
+				return nil, err
+			}
+			if len(instances) == 0 {
+				return nil, errors.New("no instance matches the targets")
+			}
+
+			for _, i := range instances {
+				ids = append(ids, i.InstanceID)
+			}
+		}
+
+		batches := [][]*ssm.Target{}
+		for _, b := range instanceIDBatches(ids) {
+			batches = append(batches, []*ssm.Target{instanceIDTarget(b)})
+		}
+		return batches, nil
+	}
+
+	tagTargets := []*ssm.Target{}
+	for _, t := range opts.Tags {
+		tagTargets = append(tagTargets, &ssm.Target{
+			Key:    aws.String(fmt.Sprintf("tag:%s", t.Key)),
+			Values: aws.StringSlice(t.Values),
 		})
 	}
+	if len(opts.InstanceIDs) == 0 {
+		return [][]*ssm.Target{tagTargets}, nil
+	}
 
-	// TODO: write output to S3
-	resp, err := c.SSM.SendCommand(&ssm.SendCommandInput{
-		DocumentName:   aws.String(opts.DocumentName),
-		Targets:        targets,
-		MaxConcurrency: aws.String(opts.MaxConcurrency),
-		MaxErrors:      aws.String(opts.MaxErrors),
-		Parameters: map[string][]*string{
-			"outputLogGroup":        []*string{aws.String(opts.OutputLogGroup)},
-			"outputLogStreamPrefix": []*string{aws.String(fmt.Sprintf("%s/", pcommandID))},
-			"signalS3Bucket":        []*string{aws.String(opts.SignalS3Bucket)},
-			"signalS3Key":           []*string{aws.String(fmt.Sprintf("%s%s.json", opts.SignalS3KeyPrefix, pcommandID))},
-		},
-	})
-	if err != nil {
-		return nil, err
+	batches := [][]*ssm.Target{}
+	for _, b := range instanceIDBatches(opts.InstanceIDs) {
+		targets := append([]*ssm.Target{instanceIDTarget(b)}, tagTargets...)
+		batches = append(batches, targets)
 	}
+	return batches, nil
+}
 
-	commandID := *resp.Command.CommandId
+func (o *SendOptions) resolvesTargets() bool {
+	return !o.Tags.SSMExpressible() || !o.Filter.Empty()
+}
 
-	err = c.Store.PutCommand(&store.CommandRecord{
-		CommandID:  commandID,
-		PcommandID: pcommandID,
-	})
+// instanceIDBatches splits instance IDs into batches SSM accepts in a target
+func instanceIDBatches(ids []string) [][]string {
+	batches := [][]string{}
+	for len(ids) > MaxInstanceIDs {
+		batches = append(batches, ids[:MaxInstanceIDs])
+		ids = ids[MaxInstanceIDs:]
+	}
+	return append(batches, ids)
+}
+
+func instanceIDTarget(ids []string) *ssm.Target {
+	return &ssm.Target{
+		Key:    aws.String("InstanceIds"),
+		Values: aws.StringSlice(ids),
+	}
+}
+
+// UpdateRecord stores the latest status of a command to the command history.
+// The record of each batch has the status of the batch, and that of the first batch has the status of all the batches.
+func (c *Client) UpdateRecord(commandID string, invocations []*CommandInvocation) error {
+	ids, err := c.batchCommandIDs(commandID)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	sdkCommands, err := c.listCommands(ids)
+	if err != nil {
+		return err
+	}
+
+	if len(sdkCommands) > 1 {
+		for _, sc := range sdkCommands[1:] {
+			counts := map[string]int{}
+			for _, i := range invocations {
+				if i.CommandID == *sc.CommandId {
+					counts[i.Status]++
+				}
+			}
+			if err := c.Store.UpdateCommandStatus(*sc.CommandId, *sc.Status, counts); err != nil {
+				return err
+			}
+		}
+	}
+
+	counts := map[string]int{}
+	for _, i := range invocations {
+		counts[i.Status]++
 	}
 
-	return commandFromSDK(resp.Command, pcommandID), nil
+	return c.Store.UpdateCommandStatus(commandID, batchStatus(sdkCommands), counts)
 }
 
 // WaitStatus waits a command to be in specified status
 func (c *Client) WaitStatus(commandID string, statuses []string) chan *Command {
-	interval := 15 * time.Second
+	interval := c.pollInterval(15 * time.Second)
 
 	ch := make(chan *Command)
 
@@ -214,23 +717,15 @@ func (c *Client) WaitStatus(commandID string, statuses []string) chan *Command {
 		for {
 			log.Printf("[DEBUG] Checking status of command %s", commandID)
 
-			resp, err := c.SSM.ListCommands(&ssm.ListCommandsInput{
-				CommandId: aws.String(commandID),
-			})
+			cmd, err := c.Get(commandID)
 			if err != nil {
 				log.Printf("[WARN] %s", err)
-			}
-			if len(resp.Commands) == 0 {
-				log.Printf("[WARN] Command %s is not found", commandID)
+				time.Sleep(interval)
+				continue
 			}
 
 			for _, st := range statuses {
-				if *resp.Commands[0].Status == st {
-					cmd, err := c.Get(commandID)
-					if err != nil {
-						log.Printf("[WARN] %s", err)
-					}
-
+				if cmd.Status == st {
 					ch <- cmd
 					return
 				}
@@ -1,6 +1,9 @@
 package commands
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/ryotarai/paramedic/documents"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -8,16 +11,26 @@ import (
 )
 
 type Command struct {
-	CommandID    string
-	PcommandID   string
-	Status       string
-	Targets      map[string][]string
-	DocumentName string
+	CommandID    string              `json:"commandId" yaml:"commandId"`
+	PcommandID   string              `json:"pcommandId" yaml:"pcommandId"`
+	Status       string              `json:"status" yaml:"status"`
+	Targets      map[string][]string `json:"targets" yaml:"targets"`
+	DocumentName string              `json:"documentName" yaml:"documentName"`
+	// DocumentVersion is the version in the command history, which is empty for old commands
+	DocumentVersion string `json:"documentVersion,omitempty" yaml:"documentVersion,omitempty"`
 
-	OutputLogGroup        string
-	OutputLogStreamPrefix string
-	SignalS3Bucket        string
-	SignalS3Key           string
+	OutputLogGroup        string `json:"outputLogGroup" yaml:"outputLogGroup"`
+	OutputLogStreamPrefix string `json:"outputLogStreamPrefix" yaml:"outputLogStreamPrefix"`
+	SignalS3Bucket        string `json:"signalS3Bucket" yaml:"signalS3Bucket"`
+	SignalS3Key           string `json:"signalS3Key" yaml:"signalS3Key"`
+	// ExecutionTimeout is empty if the document's default is used
+	ExecutionTimeout string `json:"executionTimeout,omitempty" yaml:"executionTimeout,omitempty"`
+	// RolloutID is the command ID of the first wave if the command is a part of a staged rollout
+	RolloutID string `json:"rolloutId,omitempty" yaml:"rolloutId,omitempty"`
+	// RerunOf is the ID of the command this command re-runs
+	RerunOf string `json:"rerunOf,omitempty" yaml:"rerunOf,omitempty"`
+	// BatchCommandIDs are IDs of the SSM commands the command is sent as, if instance IDs are split into batches
+	BatchCommandIDs []string `json:"batchCommandIds,omitempty" yaml:"batchCommandIds,omitempty"`
 }
 
 func commandFromSDK(c *ssm.Command, pcommandID string) *Command {
@@ -28,6 +41,11 @@ func commandFromSDK(c *ssm.Command, pcommandID string) *Command {
 
 	doc := documents.ConvertFromSSMName(*c.DocumentName)
 
+	executionTimeout := ""
+	if v, ok := c.Parameters["executionTimeout"]; ok && len(v) > 0 {
+		executionTimeout = *v[0]
+	}
+
 	return &Command{
 		CommandID:             *c.CommandId,
 		PcommandID:            pcommandID,
@@ -38,21 +56,92 @@ func commandFromSDK(c *ssm.Command, pcommandID string) *Command {
 		SignalS3Key:           *c.Parameters["signalS3Key"][0],
 		Targets:               targets,
 		DocumentName:          doc,
+		ExecutionTimeout:      executionTimeout,
 	}
 }
 
 type CommandInvocation struct {
-	CommandID    string
-	InstanceID   string
-	InstanceName string
-	Status       string
+	CommandID     string `json:"commandId" yaml:"commandId"`
+	InstanceID    string `json:"instanceId" yaml:"instanceId"`
+	InstanceName  string `json:"instanceName" yaml:"instanceName"`
+	Status        string `json:"status" yaml:"status"`
+	StatusDetails string `json:"statusDetails" yaml:"statusDetails"`
 }
 
 func commandInvocationFromSDK(c *ssm.CommandInvocation) *CommandInvocation {
 	return &CommandInvocation{
-		CommandID:    *c.CommandId,
-		InstanceID:   *c.InstanceId,
-		InstanceName: *c.InstanceName,
-		Status:       *c.Status,
+		CommandID:     *c.CommandId,
+		InstanceID:    *c.InstanceId,
+		InstanceName:  *c.InstanceName,
+		Status:        *c.Status,
+		StatusDetails: aws.StringValue(c.StatusDetails),
+	}
+}
+
+// failedStatuses are final statuses of invocations which did not succeed
+var failedStatuses = []string{"Failed", "TimedOut", "Cancelled", "Undeliverable", "Terminated"}
+
+// InvocationsFinished returns true if all the invocations reached final statuses
+func InvocationsFinished(invocations []*CommandInvocation) bool {
+	for _, i := range invocations {
+		if i.Status != "Success" && !containsString(failedStatuses, i.Status) {
+			return false
+		}
+	}
+	return true
+}
+
+// InstanceIDsByStatus returns instance IDs of invocations selected by "failed" (any final status but Success),
+// "timed-out" or "all"
+func InstanceIDsByStatus(invocations []*CommandInvocation, only string) ([]string, error) {
+	var match func(status string) bool
+	switch only {
+	case "failed":
+		match = func(status string) bool { return containsString(failedStatuses, status) }
+	case "timed-out":
+		match = func(status string) bool { return status == "TimedOut" }
+	case "all":
+		match = func(status string) bool { return true }
+	default:
+		return nil, fmt.Errorf("'%s' is not one of failed, timed-out and all", only)
+	}
+
+	ids := []string{}
+	for _, i := range invocations {
+		if match(i.Status) {
+			ids = append(ids, i.InstanceID)
+		}
+	}
+	return ids, nil
+}
+
+// runningInstanceIDs returns IDs of instances whose invocations are not finished yet.
+// If instanceIDs is not empty, only the instances are checked.
+func runningInstanceIDs(invocations []*CommandInvocation, instanceIDs []string) []string {
+	ids := []string{}
+	for _, i := range invocations {
+		if len(instanceIDs) > 0 && !containsString(instanceIDs, i.InstanceID) {
+			continue
+		}
+		switch i.Status {
+		case "Pending", "InProgress", "Delayed":
+			ids = append(ids, i.InstanceID)
+		}
+	}
+	return ids
+}
+
+// instanceSignalS3Key returns a key of the signal object for an invocation on an instance
+// (e.g. signals/PCOMMAND_ID.json => signals/PCOMMAND_ID/INSTANCE_ID.json)
+func instanceSignalS3Key(signalS3Key, instanceID string) string {
+	return fmt.Sprintf("%s/%s.json", strings.TrimSuffix(signalS3Key, ".json"), instanceID)
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
 	}
+	return false
 }
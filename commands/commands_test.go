@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInstanceIDsByStatus(t *testing.T) {
+	invocations := []*CommandInvocation{
+		{InstanceID: "i-a", Status: "Success"},
+		{InstanceID: "i-b", Status: "Failed"},
+		{InstanceID: "i-c", Status: "TimedOut"},
+		{InstanceID: "i-d", Status: "Cancelled"},
+		{InstanceID: "i-e", Status: "InProgress"},
+		{InstanceID: "i-f", Status: "Undeliverable"},
+	}
+
+	examples := map[string][]string{
+		"failed":    {"i-b", "i-c", "i-d", "i-f"},
+		"timed-out": {"i-c"},
+		"all":       {"i-a", "i-b", "i-c", "i-d", "i-e", "i-f"},
+	}
+	for only, want := range examples {
+		got, err := InstanceIDsByStatus(invocations, only)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("InstanceIDsByStatus(%s) = %v, want %v", only, got, want)
+		}
+	}
+
+	if _, err := InstanceIDsByStatus(invocations, "unknown"); err == nil {
+		t.Error("InstanceIDsByStatus(unknown) returns no error")
+	}
+}
+
+func TestInvocationsFinished(t *testing.T) {
+	invocations := []*CommandInvocation{
+		{InstanceID: "i-a", Status: "Success"},
+		{InstanceID: "i-b", Status: "Terminated"},
+	}
+	if !InvocationsFinished(invocations) {
+		t.Error("got not finished, want finished")
+	}
+	for _, status := range []string{"Pending", "InProgress", "Delayed", "Cancelling"} {
+		if InvocationsFinished(append(invocations, &CommandInvocation{InstanceID: "i-c", Status: status})) {
+			t.Errorf("%s: got finished, want not finished", status)
+		}
+	}
+}
+
+func TestRunningInstanceIDs(t *testing.T) {
+	invocations := []*CommandInvocation{
+		{InstanceID: "i-a", Status: "Success"},
+		{InstanceID: "i-b", Status: "InProgress"},
+		{InstanceID: "i-c", Status: "Pending"},
+		{InstanceID: "i-d", Status: "Cancelled"},
+	}
+
+	if got, want := runningInstanceIDs(invocations, nil), []string{"i-b", "i-c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := runningInstanceIDs(invocations, []string{"i-a", "i-c"}), []string{"i-c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInstanceSignalS3Key(t *testing.T) {
+	got := instanceSignalS3Key("signals/abc.json", "i-a")
+	if want := "signals/abc/i-a.json"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// InstanceFilter excludes instances on the client side, which SSM targets can't express
+type InstanceFilter struct {
+	ExcludeInstanceIDs []string
+	// ExcludeTags excludes instances matching any of its conditions
+	ExcludeTags TagExpression
+	// Name is a glob (e.g. app-*-1a) or a regular expression between slashes (e.g. /^app-\d+$/)
+	// which computer names of instances match
+	Name string
+}
+
+// Empty returns true if the filter excludes no instance
+func (f *InstanceFilter) Empty() bool {
+	return f == nil || (len(f.ExcludeInstanceIDs) == 0 && len(f.ExcludeTags) == 0 && f.Name == "")
+}
+
+// NameMatcher returns a function which returns true if a name matches a glob or a regular expression between slashes
+func NameMatcher(pattern string) (func(string) bool, error) {
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern '%s': %s", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid name pattern '%s': %s", pattern, err)
+	}
+	return func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}, nil
+}
+
+// FilterInstances returns instances which the filter doesn't exclude
+func (c *Client) FilterInstances(instances []*Instance, f *InstanceFilter) ([]*Instance, error) {
+	if f.Empty() {
+		return instances, nil
+	}
+
+	match := func(string) bool { return true }
+	if f.Name != "" {
+		var err error
+		match, err = NameMatcher(f.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	excluded := map[string]bool{}
+	for _, id := range f.ExcludeInstanceIDs {
+		excluded[id] = true
+	}
+	for _, t := range f.ExcludeTags {
+		matched, err := c.GetInstances(nil, TagExpression{t})
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range matched {
+			excluded[i.InstanceID] = true
+		}
+	}
+
+	filtered := []*Instance{}
+	for _, i := range instances {
+		if !excluded[i.InstanceID] && match(i.ComputerName) {
+			filtered = append(filtered, i)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/ryotarai/paramedic/awsclient/fake"
+)
+
+func TestNameMatcher(t *testing.T) {
+	examples := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"app-*-1a", "app-web-1a", true},
+		{"app-*-1a", "app-web-1c", false},
+		{"app-?", "app-1", true},
+		{"/^app-[0-9]+$/", "app-12", true},
+		{"/^app-[0-9]+$/", "app-12-1a", false},
+		{"/db/", "main-db-1", true},
+	}
+	for _, ex := range examples {
+		match, err := NameMatcher(ex.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := match(ex.name); got != ex.want {
+			t.Errorf("%s matches %s: got %v, want %v", ex.pattern, ex.name, got, ex.want)
+		}
+	}
+
+	for _, pattern := range []string{"app-[", "/app-(/"} {
+		if _, err := NameMatcher(pattern); err == nil {
+			t.Errorf("NameMatcher(%s) returns no error", pattern)
+		}
+	}
+}
+
+func TestFilterInstances(t *testing.T) {
+	a := fake.New()
+	a.SSM.AddInstance(&fake.Instance{ID: "i-a", Name: "app-web-1a", Tags: map[string]string{"Role": "app"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-b", Name: "app-web-1c", Tags: map[string]string{"Role": "app"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-c", Name: "app-api-1a", Tags: map[string]string{"Role": "app", "Canary": "true"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-d", Name: "app-api-1a", Tags: map[string]string{"Role": "app"}})
+	c := &Client{SSM: a.SSM}
+
+	instances, err := c.GetInstances(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	examples := map[string]struct {
+		filter *InstanceFilter
+		want   []string
+	}{
+		"empty":       {filter: &InstanceFilter{}, want: []string{"i-a", "i-b", "i-c", "i-d"}},
+		"instance ID": {filter: &InstanceFilter{ExcludeInstanceIDs: []string{"i-b"}}, want: []string{"i-a", "i-c", "i-d"}},
+		"tags":        {filter: &InstanceFilter{ExcludeTags: TagExpression{{Key: "Canary"}}}, want: []string{"i-a", "i-b", "i-d"}},
+		"name":        {filter: &InstanceFilter{Name: "app-*-1a"}, want: []string{"i-a", "i-c", "i-d"}},
+		"all": {
+			filter: &InstanceFilter{ExcludeInstanceIDs: []string{"i-a"}, ExcludeTags: TagExpression{{Key: "Canary"}}, Name: "app-*-1a"},
+			want:   []string{"i-d"},
+		},
+	}
+	for name, ex := range examples {
+		filtered, err := c.FilterInstances(instances, ex.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, i := range filtered {
+			got = append(got, i.InstanceID)
+		}
+		if !reflect.DeepEqual(got, ex.want) {
+			t.Errorf("%s: got %v, want %v", name, got, ex.want)
+		}
+	}
+}
+
+func TestTargetBatches(t *testing.T) {
+	ids := []string{}
+	for i := 0; i < 120; i++ {
+		ids = append(ids, fmt.Sprintf("i-%03d", i))
+	}
+	c := &Client{SSM: fake.New().SSM}
+
+	for name, opts := range map[string]*SendOptions{
+		"instance IDs": {InstanceIDs: ids, Tags: TagExpression{{Key: "Role", Values: []string{"app"}}}},
+		"resolved":     {Filter: &InstanceFilter{Name: "app-*"}, ResolvedInstanceIDs: ids},
+	} {
+		batches, err := c.targetBatches(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := []int{}
+		all := []string{}
+		for _, targets := range batches {
+			got = append(got, len(targets[0].Values))
+			all = append(all, aws.StringValueSlice(targets[0].Values)...)
+			// Targets are ANDed, so every batch has the tag
+			if len(targets) != len(opts.Tags)+1 {
+				t.Errorf("%s: got targets %v, want the instance IDs and the tags", name, targets)
+			}
+		}
+		if want := []int{50, 50, 20}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got batches of %v, want %v", name, got, want)
+		}
+		if !reflect.DeepEqual(all, ids) {
+			t.Errorf("%s: got %v, want %v", name, all, ids)
+		}
+	}
+
+	batches, err := c.targetBatches(&SendOptions{Tags: TagExpression{{Key: "Role", Values: []string{"app"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || len(batches[0]) != 1 {
+		t.Errorf("got %v, want a batch of the tag", batches)
+	}
+}
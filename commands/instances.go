@@ -1,7 +1,96 @@
 package commands
 
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// describeTagsBatchSize is the number of instances whose tags are described at once
+const describeTagsBatchSize = 200
+
 type Instance struct {
-	InstanceID   string
-	ComputerName string
-	PingStatus   string
+	InstanceID      string    `json:"instanceId" yaml:"instanceId"`
+	ComputerName    string    `json:"computerName" yaml:"computerName"`
+	PingStatus      string    `json:"pingStatus" yaml:"pingStatus"`
+	LastPingAt      time.Time `json:"lastPingAt" yaml:"lastPingAt"`
+	PlatformType    string    `json:"platformType,omitempty" yaml:"platformType,omitempty"`
+	PlatformName    string    `json:"platformName,omitempty" yaml:"platformName,omitempty"`
+	PlatformVersion string    `json:"platformVersion,omitempty" yaml:"platformVersion,omitempty"`
+	AgentVersion    string    `json:"agentVersion,omitempty" yaml:"agentVersion,omitempty"`
+	// AgentIsLatest is false if SSM agent on the instance is outdated
+	AgentIsLatest bool   `json:"agentIsLatest" yaml:"agentIsLatest"`
+	IPAddress     string `json:"ipAddress,omitempty" yaml:"ipAddress,omitempty"`
+	ResourceType  string `json:"resourceType,omitempty" yaml:"resourceType,omitempty"`
+	// Tags are tags of the EC2 instance, which are set by AddTags
+	Tags map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+func instanceFromSDK(info *ssm.InstanceInformation) *Instance {
+	return &Instance{
+		InstanceID:      *info.InstanceId,
+		ComputerName:    *info.ComputerName,
+		PingStatus:      *info.PingStatus,
+		LastPingAt:      aws.TimeValue(info.LastPingDateTime),
+		PlatformType:    aws.StringValue(info.PlatformType),
+		PlatformName:    aws.StringValue(info.PlatformName),
+		PlatformVersion: aws.StringValue(info.PlatformVersion),
+		AgentVersion:    aws.StringValue(info.AgentVersion),
+		AgentIsLatest:   aws.BoolValue(info.IsLatestVersion),
+		IPAddress:       aws.StringValue(info.IPAddress),
+		ResourceType:    aws.StringValue(info.ResourceType),
+	}
+}
+
+// Name returns the Name tag of the instance
+func (i *Instance) Name() string {
+	return i.Tags["Name"]
+}
+
+// AddTags sets tags of EC2 instances. Managed instances which are not EC2 instances don't have tags.
+func (c *Client) AddTags(instances []*Instance) error {
+	byID := map[string]*Instance{}
+	ids := []string{}
+	for _, i := range instances {
+		i.Tags = map[string]string{}
+		if i.ResourceType == ssm.ResourceTypeEc2instance {
+			byID[i.InstanceID] = i
+			ids = append(ids, i.InstanceID)
+		}
+	}
+
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > describeTagsBatchSize {
+			n = describeTagsBatchSize
+		}
+
+		err := c.EC2.DescribeTagsPages(&ec2.DescribeTagsInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("resource-type"),
+					Values: aws.StringSlice([]string{ec2.ResourceTypeInstance}),
+				},
+				{
+					Name:   aws.String("resource-id"),
+					Values: aws.StringSlice(ids[:n]),
+				},
+			},
+		}, func(resp *ec2.DescribeTagsOutput, last bool) bool {
+			for _, t := range resp.Tags {
+				if i, ok := byID[aws.StringValue(t.ResourceId)]; ok {
+					i.Tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		ids = ids[n:]
+	}
+	return nil
 }
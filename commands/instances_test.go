@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ryotarai/paramedic/awsclient/fake"
+)
+
+func TestAddTags(t *testing.T) {
+	a := fake.New()
+	a.SSM.AddInstance(&fake.Instance{ID: "i-a", Name: "a", Tags: map[string]string{"Name": "web-a", "Role": "app"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "mi-b", Name: "b", Tags: map[string]string{"Role": "app"}})
+	c := &Client{SSM: a.SSM, EC2: a.EC2}
+
+	instances, err := c.GetInstances(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddTags(instances); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := instances[0].Tags, map[string]string{"Name": "web-a", "Role": "app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := instances[0].Name(); got != "web-a" {
+		t.Errorf("got name %s, want web-a", got)
+	}
+	// managed instances don't have EC2 tags
+	if got := instances[1].Tags; len(got) != 0 {
+		t.Errorf("got %v, want no tag", got)
+	}
+}
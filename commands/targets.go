@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagCondition is a condition on a tag of instances
+type TagCondition struct {
+	Key string
+	// Values are ORed. The condition checks only existence of the key if empty
+	Values []string
+	// Negate excludes instances matching the condition
+	Negate bool
+}
+
+// TagExpression is a list of conditions which are ANDed
+type TagExpression []*TagCondition
+
+// ParseTagExpression parses terms of a tag expression. Each term is one of:
+//
+//	Key=value1|value2  the tag is one of the values
+//	Key                the tag exists
+//	!Key=value         the tag is not the value
+//	!Key               the tag doesn't exist
+//
+// Values of terms on the same key are merged, so that "Role=app,Role=worker" is "Role=app|worker"
+func ParseTagExpression(terms []string) (TagExpression, error) {
+	expr := TagExpression{}
+	for _, term := range terms {
+		c, err := parseTagCondition(strings.TrimSpace(term))
+		if err != nil {
+			return nil, err
+		}
+
+		merged := false
+		for _, e := range expr {
+			if e.Key == c.Key && e.Negate == c.Negate && len(e.Values) > 0 && len(c.Values) > 0 {
+				e.Values = appendUnique(e.Values, c.Values...)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			expr = append(expr, c)
+		}
+	}
+	return expr, nil
+}
+
+func parseTagCondition(term string) (*TagCondition, error) {
+	c := &TagCondition{}
+	if strings.HasPrefix(term, "!") {
+		c.Negate = true
+		term = strings.TrimSpace(term[1:])
+	}
+
+	parts := strings.SplitN(term, "=", 2)
+	c.Key = strings.TrimSpace(parts[0])
+	if c.Key == "" {
+		return nil, fmt.Errorf("invalid tag condition '%s': tag key is empty", term)
+	}
+	if strings.ContainsAny(c.Key, "|!") {
+		return nil, fmt.Errorf("invalid tag condition '%s': tag key contains '|' or '!'", term)
+	}
+
+	if len(parts) == 2 {
+		for _, v := range strings.Split(parts[1], "|") {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				return nil, fmt.Errorf("invalid tag condition '%s': tag value is empty", term)
+			}
+			c.Values = appendUnique(c.Values, v)
+		}
+	}
+	return c, nil
+}
+
+// SSMExpressible returns true if SSM can target instances by the expression without resolving them to instance IDs
+func (e TagExpression) SSMExpressible() bool {
+	for _, c := range e {
+		if c.Negate || len(c.Values) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Strings returns the expression in "key=value" format, one term per value of positive conditions
+func (e TagExpression) Strings() []string {
+	ss := []string{}
+	for _, c := range e {
+		if !c.Negate && len(c.Values) > 0 {
+			for _, v := range c.Values {
+				ss = append(ss, fmt.Sprintf("%s=%s", c.Key, v))
+			}
+			continue
+		}
+		ss = append(ss, c.String())
+	}
+	return ss
+}
+
+func (c *TagCondition) String() string {
+	s := c.Key
+	if len(c.Values) > 0 {
+		s += "=" + strings.Join(c.Values, "|")
+	}
+	if c.Negate {
+		s = "!" + s
+	}
+	return s
+}
+
+func appendUnique(ss []string, vs ...string) []string {
+	for _, v := range vs {
+		found := false
+		for _, s := range ss {
+			if s == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ss = append(ss, v)
+		}
+	}
+	return ss
+}
@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/ryotarai/paramedic/awsclient/fake"
+)
+
+func TestParseTagExpression(t *testing.T) {
+	examples := map[string]struct {
+		terms []string
+		want  TagExpression
+	}{
+		"single": {
+			terms: []string{"Role=app"},
+			want:  TagExpression{{Key: "Role", Values: []string{"app"}}},
+		},
+		"values": {
+			terms: []string{"Role=app|worker", "Env=prod"},
+			want: TagExpression{
+				{Key: "Role", Values: []string{"app", "worker"}},
+				{Key: "Env", Values: []string{"prod"}},
+			},
+		},
+		"merged": {
+			terms: []string{"Role=app", "Role=worker|app"},
+			want:  TagExpression{{Key: "Role", Values: []string{"app", "worker"}}},
+		},
+		"negated": {
+			terms: []string{"Role=app", "!Env=prod|staging"},
+			want: TagExpression{
+				{Key: "Role", Values: []string{"app"}},
+				{Key: "Env", Values: []string{"prod", "staging"}, Negate: true},
+			},
+		},
+		"existence": {
+			terms: []string{"Team", "!Deprecated"},
+			want: TagExpression{
+				{Key: "Team"},
+				{Key: "Deprecated", Negate: true},
+			},
+		},
+		"value containing '='": {
+			terms: []string{"Query=a=b"},
+			want:  TagExpression{{Key: "Query", Values: []string{"a=b"}}},
+		},
+	}
+	for name, ex := range examples {
+		got, err := ParseTagExpression(ex.terms)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, ex.want) {
+			t.Errorf("%s: got %v, want %v", name, got.Strings(), ex.want.Strings())
+		}
+	}
+
+	for _, terms := range [][]string{{"=app"}, {"Role="}, {"Role=app|"}, {"!"}, {"Role|Env=app"}} {
+		if _, err := ParseTagExpression(terms); err == nil {
+			t.Errorf("ParseTagExpression(%v) returns no error", terms)
+		}
+	}
+}
+
+func TestTagExpressionStrings(t *testing.T) {
+	expr, err := ParseTagExpression([]string{"Role=app|worker", "!Env=prod", "Team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expr.Strings(), []string{"Role=app", "Role=worker", "!Env=prod", "Team"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if expr.SSMExpressible() {
+		t.Error("SSM can't express negation and existence")
+	}
+}
+
+func TestGetInstances(t *testing.T) {
+	a := fake.New()
+	a.SSM.AddInstance(&fake.Instance{ID: "i-a", Name: "app-1", Tags: map[string]string{"Role": "app", "Env": "prod", "Team": "web"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-b", Name: "app-2", Tags: map[string]string{"Role": "app", "Env": "staging"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-c", Name: "worker-1", Tags: map[string]string{"Role": "worker", "Env": "staging", "Team": "web"}})
+	a.SSM.AddInstance(&fake.Instance{ID: "i-d", Name: "db-1", Tags: map[string]string{"Role": "db", "Env": "prod"}})
+	c := &Client{SSM: a.SSM}
+
+	examples := map[string][]string{
+		"Role=app|worker":           {"i-a", "i-b", "i-c"},
+		"Role=app|worker,!Env=prod": {"i-b", "i-c"},
+		"Team":                      {"i-a", "i-c"},
+		"!Team,Env=prod":            {"i-d"},
+		"Role=app,Role=db":          {"i-a", "i-b", "i-d"},
+	}
+	for terms, want := range examples {
+		expr, err := ParseTagExpression(strings.Split(terms, ","))
+		if err != nil {
+			t.Fatal(err)
+		}
+		instances, err := c.GetInstances(nil, expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, i := range instances {
+			got = append(got, i.InstanceID)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", terms, got, want)
+		}
+	}
+}
+
+func TestTargetsResolvedInstanceIDs(t *testing.T) {
+	a := fake.New()
+	a.SSM.AddInstance(&fake.Instance{ID: "i-a", Name: "app-1", Tags: map[string]string{"Role": "app"}})
+	c := &Client{SSM: a.SSM}
+
+	opts := &SendOptions{
+		Tags:                TagExpression{{Key: "Canary", Negate: true}},
+		ResolvedInstanceIDs: []string{"i-a"},
+	}
+	// An instance launched after the confirmation is not targeted
+	a.SSM.AddInstance(&fake.Instance{ID: "i-b", Name: "app-2", Tags: map[string]string{"Role": "app"}})
+
+	batches, err := c.targetBatches(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || !reflect.DeepEqual(aws.StringValueSlice(batches[0][0].Values), []string{"i-a"}) {
+		t.Errorf("got targets %v, want i-a", batches)
+	}
+
+	opts.ResolvedInstanceIDs = nil
+	batches, err = c.targetBatches(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || !reflect.DeepEqual(aws.StringValueSlice(batches[0][0].Values), []string{"i-a", "i-b"}) {
+		t.Errorf("got targets %v, want i-a and i-b", batches)
+	}
+}
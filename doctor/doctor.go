@@ -0,0 +1,319 @@
+package doctor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/ryotarai/paramedic/awsclient"
+	"github.com/ryotarai/paramedic/commands"
+	"github.com/ryotarai/paramedic/documents"
+	"github.com/ryotarai/paramedic/store"
+)
+
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// Result is a result of a check
+type Result struct {
+	Check   string `json:"check" yaml:"check"`
+	Status  string `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+	// Hint tells how to fix the problem
+	Hint string `json:"hint,omitempty" yaml:"hint,omitempty"`
+}
+
+// Doctor checks AWS resources and instances paramedic depends on
+type Doctor struct {
+	Store          *store.Store
+	SSM            awsclient.SSM
+	CloudWatchLogs awsclient.CloudWatchLogs
+	Kinesis        awsclient.Kinesis
+	S3             awsclient.S3
+
+	OutputLogGroup string
+	KinesisStream  string
+	ScriptS3Bucket string
+	SignalS3Bucket string
+	// DocumentName is checked if it is not empty
+	DocumentName string
+	InstanceIDs  []string
+}
+
+// Run runs all checks
+func (d *Doctor) Run() []*Result {
+	results := []*Result{}
+
+	table := d.checkTable()
+	results = append(results, table)
+	if table.Status == StatusPass {
+		results = append(results, d.checkSchema())
+	}
+	results = append(results, d.checkLogGroup())
+
+	stream := d.checkStream()
+	results = append(results, stream)
+	if stream.Status == StatusPass {
+		results = append(results, d.checkSubscriptionFilter())
+	}
+
+	results = append(results, d.checkBucket("script S3 bucket", d.ScriptS3Bucket))
+	results = append(results, d.checkBucket("signal S3 bucket", d.SignalS3Bucket))
+	results = append(results, d.checkDocuments())
+	results = append(results, d.checkInstances()...)
+
+	return results
+}
+
+// Failed returns the number of failed checks
+func Failed(results []*Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == StatusFail {
+			n++
+		}
+	}
+	return n
+}
+
+func pass(check, format string, a ...interface{}) *Result {
+	return &Result{Check: check, Status: StatusPass, Message: fmt.Sprintf(format, a...)}
+}
+
+func fail(check, hint, format string, a ...interface{}) *Result {
+	return &Result{Check: check, Status: StatusFail, Message: fmt.Sprintf(format, a...), Hint: hint}
+}
+
+func (d *Doctor) checkTable() *Result {
+	check := "DynamoDB table"
+
+	exists, err := d.Store.TableExists()
+	if err != nil {
+		return fail(check, "", "%s", err)
+	}
+	if !exists {
+		return fail(check, "Run 'paramedic setup'", "table %s does not exist", d.Store.TableName())
+	}
+	return pass(check, "table %s exists", d.Store.TableName())
+}
+
+func (d *Doctor) checkSchema() *Result {
+	check := "DynamoDB schema"
+
+	version, err := d.Store.GetSchemaVersion()
+	if err != nil {
+		return fail(check, "", "%s", err)
+	}
+	if version < store.SchemaVersion {
+		return fail(check, "Run 'paramedic store migrate'", "schema version is %d, but %d is expected", version, store.SchemaVersion)
+	}
+	if version > store.SchemaVersion {
+		return fail(check, "Upgrade paramedic", "schema version is %d, which is newer than %d", version, store.SchemaVersion)
+	}
+	return pass(check, "schema version is %d", version)
+}
+
+func (d *Doctor) checkLogGroup() *Result {
+	check := "CloudWatch Logs log group"
+
+	found := false
+	err := d.CloudWatchLogs.DescribeLogGroupsPages(&cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: aws.String(d.OutputLogGroup),
+	}, func(resp *cloudwatchlogs.DescribeLogGroupsOutput, last bool) bool {
+		for _, g := range resp.LogGroups {
+			if *g.LogGroupName == d.OutputLogGroup {
+				found = true
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return fail(check, "", "%s", err)
+	}
+	if !found {
+		return fail(check, "Run 'paramedic setup' or specify an existing log group with --output-log-group", "log group %s does not exist", d.OutputLogGroup)
+	}
+	return pass(check, "log group %s exists", d.OutputLogGroup)
+}
+
+func (d *Doctor) checkStream() *Result {
+	check := "Kinesis stream"
+
+	resp, err := d.Kinesis.DescribeStream(&kinesis.DescribeStreamInput{
+		StreamName: aws.String(d.KinesisStream),
+		Limit:      aws.Int64(1),
+	})
+	if isAWSErrorCode(err, kinesis.ErrCodeResourceNotFoundException) {
+		return &Result{
+			Check:   check,
+			Status:  StatusWarn,
+			Message: fmt.Sprintf("stream %s does not exist, so output logs are followed by polling CloudWatch Logs", d.KinesisStream),
+			Hint:    "Run 'paramedic setup' to follow output logs with less latency",
+		}
+	}
+	if err != nil {
+		return fail(check, "", "%s", err)
+	}
+
+	status := aws.StringValue(resp.StreamDescription.StreamStatus)
+	if status != kinesis.StreamStatusActive && status != kinesis.StreamStatusUpdating {
+		return fail(check, "Wait for the stream to be ACTIVE", "stream %s is %s", d.KinesisStream, status)
+	}
+	return pass(check, "stream %s is %s", d.KinesisStream, status)
+}
+
+func (d *Doctor) checkSubscriptionFilter() *Result {
+	check := "CloudWatch Logs subscription filter"
+
+	stream, err := d.Kinesis.DescribeStream(&kinesis.DescribeStreamInput{
+		StreamName: aws.String(d.KinesisStream),
+		Limit:      aws.Int64(1),
+	})
+	if err != nil {
+		return fail(check, "", "%s", err)
+	}
+	streamARN := aws.StringValue(stream.StreamDescription.StreamARN)
+
+	resp, err := d.CloudWatchLogs.DescribeSubscriptionFilters(&cloudwatchlogs.DescribeSubscriptionFiltersInput{
+		LogGroupName: aws.String(d.OutputLogGroup),
+	})
+	if isAWSErrorCode(err, cloudwatchlogs.ErrCodeResourceNotFoundException) {
+		return fail(check, "Run 'paramedic setup'", "log group %s does not exist", d.OutputLogGroup)
+	}
+	if err != nil {
+		return fail(check, "", "%s", err)
+	}
+
+	for _, f := range resp.SubscriptionFilters {
+		if aws.StringValue(f.DestinationArn) != streamARN {
+			continue
+		}
+		if aws.StringValue(f.FilterPattern) != "" {
+			return fail(check, "Set an empty filter pattern so that all output logs are delivered", "filter %s has pattern '%s'", *f.FilterName, *f.FilterPattern)
+		}
+		return pass(check, "filter %s delivers output logs to stream %s", *f.FilterName, d.KinesisStream)
+	}
+
+	return fail(check, "Run 'paramedic setup'", "no filter of log group %s delivers output logs to stream %s", d.OutputLogGroup, d.KinesisStream)
+}
+
+func (d *Doctor) checkBucket(check, bucket string) *Result {
+	if bucket == "" {
+		return fail(check, "Set the bucket in the config file", "bucket is not configured")
+	}
+
+	_, err := d.S3.HeadBucket(&s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if isAWSErrorCode(err, "NotFound") {
+		return fail(check, "Run 'paramedic setup'", "bucket %s does not exist", bucket)
+	}
+	if isAWSErrorCode(err, "Forbidden") {
+		return fail(check, "Allow s3:ListBucket on the bucket to the IAM identity", "bucket %s is not accessible", bucket)
+	}
+	if err != nil {
+		return fail(check, "", "%s", err)
+	}
+	return pass(check, "bucket %s is reachable", bucket)
+}
+
+func (d *Doctor) checkDocuments() *Result {
+	check := "documents"
+
+	names := []string{}
+	err := d.SSM.ListDocumentsPages(&ssm.ListDocumentsInput{}, func(resp *ssm.ListDocumentsOutput, last bool) bool {
+		for _, i := range resp.DocumentIdentifiers {
+			if documents.IsParamedicDocument(*i.Name) {
+				names = append(names, documents.ConvertFromSSMName(*i.Name))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return fail(check, "", "%s", err)
+	}
+
+	if d.DocumentName != "" {
+		for _, n := range names {
+			if n == d.DocumentName {
+				return pass(check, "document %s exists", d.DocumentName)
+			}
+		}
+		return fail(check, "Run 'paramedic documents upload'", "document %s does not exist", d.DocumentName)
+	}
+
+	if len(names) == 0 {
+		return fail(check, "Run 'paramedic documents upload'", "no documents exist")
+	}
+	return pass(check, "%d documents exist", len(names))
+}
+
+func (d *Doctor) checkInstances() []*Result {
+	if len(d.InstanceIDs) == 0 {
+		return []*Result{}
+	}
+
+	// SSM accepts a limited number of instance IDs in a filter
+	infos := map[string]*ssm.InstanceInformation{}
+	ids := d.InstanceIDs
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > commands.MaxInstanceIDs {
+			n = commands.MaxInstanceIDs
+		}
+		err := d.SSM.DescribeInstanceInformationPages(&ssm.DescribeInstanceInformationInput{
+			Filters: []*ssm.InstanceInformationStringFilter{
+				{
+					Key:    aws.String("InstanceIds"),
+					Values: aws.StringSlice(ids[:n]),
+				},
+			},
+		}, func(resp *ssm.DescribeInstanceInformationOutput, last bool) bool {
+			for _, i := range resp.InstanceInformationList {
+				infos[*i.InstanceId] = i
+			}
+			return true
+		})
+		if err != nil {
+			return []*Result{fail("instances", "", "%s", err)}
+		}
+		ids = ids[n:]
+	}
+
+	results := []*Result{}
+	for _, id := range d.InstanceIDs {
+		check := fmt.Sprintf("instance %s", id)
+
+		info, ok := infos[id]
+		if !ok {
+			results = append(results, fail(check, "Install and start SSM agent, and attach an instance profile which allows SSM", "instance is not managed by SSM"))
+			continue
+		}
+
+		ping := aws.StringValue(info.PingStatus)
+		platform := aws.StringValue(info.PlatformType)
+		switch {
+		case ping != ssm.PingStatusOnline:
+			results = append(results, fail(check, "Check that SSM agent is running on the instance", "SSM agent is %s", ping))
+		case platform != ssm.PlatformTypeLinux:
+			results = append(results, fail(check, "paramedic runs documents only on Linux", "platform is %s", platform))
+		default:
+			results = append(results, pass(check, "SSM agent %s is %s on %s", aws.StringValue(info.AgentVersion), ping, strings.TrimSpace(aws.StringValue(info.PlatformName)+" "+aws.StringValue(info.PlatformVersion))))
+		}
+	}
+	return results
+}
+
+func isAWSErrorCode(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
@@ -0,0 +1,185 @@
+package doctor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/ryotarai/paramedic/awsclient"
+	"github.com/ryotarai/paramedic/store"
+)
+
+const streamARN = "arn:aws:kinesis:ap-northeast-1:123456789012:stream/paramedic-logs"
+
+// account is an in-memory stand-in for the AWS services doctor checks
+type account struct {
+	awsclient.DynamoDB
+	awsclient.SSM
+	awsclient.CloudWatchLogs
+	awsclient.Kinesis
+	awsclient.S3
+
+	filterPattern string
+	buckets       map[string]string // map[name]error code
+	instances     []*ssm.InstanceInformation
+}
+
+func (a *account) ListTablesPages(input *dynamodb.ListTablesInput, fn func(*dynamodb.ListTablesOutput, bool) bool) error {
+	fn(&dynamodb.ListTablesOutput{TableNames: aws.StringSlice([]string{"ParamedicCommands"})}, true)
+	return nil
+}
+
+func (a *account) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{"SchemaVersion": {N: aws.String("1")}},
+	}, nil
+}
+
+func (a *account) DescribeLogGroupsPages(input *cloudwatchlogs.DescribeLogGroupsInput, fn func(*cloudwatchlogs.DescribeLogGroupsOutput, bool) bool) error {
+	fn(&cloudwatchlogs.DescribeLogGroupsOutput{
+		LogGroups: []*cloudwatchlogs.LogGroup{{LogGroupName: aws.String("paramedic")}},
+	}, true)
+	return nil
+}
+
+func (a *account) DescribeSubscriptionFilters(input *cloudwatchlogs.DescribeSubscriptionFiltersInput) (*cloudwatchlogs.DescribeSubscriptionFiltersOutput, error) {
+	return &cloudwatchlogs.DescribeSubscriptionFiltersOutput{
+		SubscriptionFilters: []*cloudwatchlogs.SubscriptionFilter{
+			{
+				FilterName:     aws.String("paramedic"),
+				FilterPattern:  aws.String(a.filterPattern),
+				DestinationArn: aws.String(streamARN),
+			},
+		},
+	}, nil
+}
+
+func (a *account) DescribeStream(input *kinesis.DescribeStreamInput) (*kinesis.DescribeStreamOutput, error) {
+	return &kinesis.DescribeStreamOutput{
+		StreamDescription: &kinesis.StreamDescription{
+			StreamARN:    aws.String(streamARN),
+			StreamStatus: aws.String(kinesis.StreamStatusActive),
+		},
+	}, nil
+}
+
+func (a *account) HeadBucket(input *s3.HeadBucketInput) (*s3.HeadBucketOutput, error) {
+	if code := a.buckets[*input.Bucket]; code != "" {
+		return nil, awserr.New(code, code, nil)
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (a *account) ListDocumentsPages(input *ssm.ListDocumentsInput, fn func(*ssm.ListDocumentsOutput, bool) bool) error {
+	fn(&ssm.ListDocumentsOutput{
+		DocumentIdentifiers: []*ssm.DocumentIdentifier{
+			{Name: aws.String("AWS-RunShellScript")},
+			{Name: aws.String("paramedic-reload-nginx")},
+		},
+	}, true)
+	return nil
+}
+
+func (a *account) DescribeInstanceInformationPages(input *ssm.DescribeInstanceInformationInput, fn func(*ssm.DescribeInstanceInformationOutput, bool) bool) error {
+	ids := map[string]bool{}
+	for _, f := range input.Filters {
+		if aws.StringValue(f.Key) != "InstanceIds" {
+			continue
+		}
+		if len(f.Values) > 50 {
+			return awserr.New("ValidationException", "InstanceIds must contain at most 50 items", nil)
+		}
+		for _, v := range f.Values {
+			ids[*v] = true
+		}
+	}
+
+	list := []*ssm.InstanceInformation{}
+	for _, i := range a.instances {
+		if ids[*i.InstanceId] {
+			list = append(list, i)
+		}
+	}
+	fn(&ssm.DescribeInstanceInformationOutput{InstanceInformationList: list}, true)
+	return nil
+}
+
+func statuses(results []*Result) []string {
+	s := []string{}
+	for _, r := range results {
+		s = append(s, r.Check+":"+r.Status)
+	}
+	return s
+}
+
+func TestDoctor(t *testing.T) {
+	a := &account{
+		filterPattern: "[ERROR]",
+		buckets:       map[string]string{"signals": "Forbidden"},
+		instances: []*ssm.InstanceInformation{
+			{InstanceId: aws.String("i-a"), PingStatus: aws.String("Online"), PlatformType: aws.String("Linux")},
+			{InstanceId: aws.String("i-b"), PingStatus: aws.String("ConnectionLost"), PlatformType: aws.String("Linux")},
+			{InstanceId: aws.String("i-c"), PingStatus: aws.String("Online"), PlatformType: aws.String("Windows")},
+		},
+	}
+	d := &Doctor{
+		Store:          store.New(a, ""),
+		SSM:            a,
+		CloudWatchLogs: a,
+		Kinesis:        a,
+		S3:             a,
+		OutputLogGroup: "paramedic",
+		KinesisStream:  "paramedic-logs",
+		ScriptS3Bucket: "scripts",
+		SignalS3Bucket: "signals",
+		DocumentName:   "reload-nginx",
+		InstanceIDs:    []string{"i-a", "i-b", "i-c", "i-d"},
+	}
+
+	results := d.Run()
+	want := []string{
+		"DynamoDB table:pass",
+		"DynamoDB schema:fail",
+		"CloudWatch Logs log group:pass",
+		"Kinesis stream:pass",
+		"CloudWatch Logs subscription filter:fail",
+		"script S3 bucket:pass",
+		"signal S3 bucket:fail",
+		"documents:pass",
+		"instance i-a:pass",
+		"instance i-b:fail",
+		"instance i-c:fail",
+		"instance i-d:fail",
+	}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := Failed(results); got != 6 {
+		t.Errorf("got %d failures, want 6", got)
+	}
+}
+
+func TestDoctorInstancesInBatches(t *testing.T) {
+	a := &account{}
+	d := &Doctor{SSM: a}
+	for n := 0; n < 60; n++ {
+		id := fmt.Sprintf("i-%03d", n)
+		a.instances = append(a.instances, &ssm.InstanceInformation{InstanceId: aws.String(id), PingStatus: aws.String("Online"), PlatformType: aws.String("Linux")})
+		d.InstanceIDs = append(d.InstanceIDs, id)
+	}
+
+	results := d.checkInstances()
+	if len(results) != 60 {
+		t.Fatalf("got %d results, want 60", len(results))
+	}
+	if got := Failed(results); got != 0 {
+		t.Errorf("got %d failures, want none: %v", got, statuses(results))
+	}
+}
@@ -1,9 +1,15 @@
 package documents
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -20,16 +26,18 @@ type Client struct {
 	ScriptS3KeyPrefix string
 }
 
-func (c *Client) Create(d *Definition) error {
+// Create uploads a definition as a new version of the document and returns the version.
+// The version becomes the default version if setDefault is true.
+func (c *Client) Create(d *Definition, setDefault bool) (string, error) {
 	err := c.uploadScript(d)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	name := ConvertToSSMName(d.Name)
 	content, err := d.DocumentContent(c.ScriptS3Bucket, c.scriptKey(d))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	_, err = c.SSM.DescribeDocument(&ssm.DescribeDocumentInput{
@@ -37,20 +45,15 @@ func (c *Client) Create(d *Definition) error {
 	})
 	if err == nil {
 		// existing
-		if err := c.updateDocument(name, content); err != nil {
-			return err
-		}
-	} else {
-		aErr, ok := err.(awserr.Error)
-		if !ok || aErr.Code() != ssm.ErrCodeInvalidDocument {
-			return err
-		}
-		// document does not exist
-		if err := c.createDocument(name, content); err != nil {
-			return err
-		}
+		return c.updateDocument(name, content, setDefault)
 	}
-	return nil
+
+	aErr, ok := err.(awserr.Error)
+	if !ok || aErr.Code() != ssm.ErrCodeInvalidDocument {
+		return "", err
+	}
+	// document does not exist
+	return c.createDocument(name, content)
 }
 
 func (c *Client) scriptKey(d *Definition) string {
@@ -72,38 +75,421 @@ func (c *Client) uploadScript(d *Definition) error {
 	return nil
 }
 
-func (c *Client) updateDocument(name, content string) error {
+func (c *Client) updateDocument(name, content string, setDefault bool) (string, error) {
 	log.Printf("[INFO] Updating a document '%s'", name)
+	var version string
 	resp, err := c.SSM.UpdateDocument(&ssm.UpdateDocumentInput{
 		Name:            aws.String(name),
 		Content:         aws.String(content),
 		DocumentVersion: aws.String("$LATEST"),
 	})
-	if err != nil {
-		return err
+	if err == nil {
+		version = *resp.DocumentDescription.DocumentVersion
+	} else {
+		aErr, ok := err.(awserr.Error)
+		if !ok || aErr.Code() != ssm.ErrCodeDuplicateDocumentContent {
+			return "", err
+		}
+		// e.g. a change is reverted
+		version, err = c.findVersion(name, content)
+		if err != nil {
+			return "", err
+		}
+		log.Printf("[INFO] Version %s of '%s' has the same content", version, name)
 	}
 
-	_, err = c.SSM.UpdateDocumentDefaultVersion(&ssm.UpdateDocumentDefaultVersionInput{
-		Name:            aws.String(name),
-		DocumentVersion: resp.DocumentDescription.DocumentVersion,
-	})
-	if err != nil {
-		return err
+	if setDefault {
+		if err := c.SetDefaultVersion(ConvertFromSSMName(name), version); err != nil {
+			return "", err
+		}
 	}
 
-	return nil
+	return version, nil
 }
 
-func (c *Client) createDocument(name, content string) error {
+// findVersion returns the version of a document which has the content
+func (c *Client) findVersion(name, content string) (string, error) {
+	input := &ssm.ListDocumentVersionsInput{
+		Name: aws.String(name),
+	}
+	for {
+		resp, err := c.SSM.ListDocumentVersions(input)
+		if err != nil {
+			return "", err
+		}
+		for _, v := range resp.DocumentVersions {
+			doc, err := c.SSM.GetDocument(&ssm.GetDocumentInput{
+				Name:            aws.String(name),
+				DocumentVersion: v.DocumentVersion,
+			})
+			if err != nil {
+				return "", err
+			}
+			if aws.StringValue(doc.Content) == content {
+				return *v.DocumentVersion, nil
+			}
+		}
+		if resp.NextToken == nil {
+			break
+		}
+		input.NextToken = resp.NextToken
+	}
+	return "", fmt.Errorf("no version of '%s' has the same content", name)
+}
+
+func (c *Client) createDocument(name, content string) (string, error) {
 	log.Printf("[INFO] Creating a document '%s'", name)
 
-	_, err := c.SSM.CreateDocument(&ssm.CreateDocumentInput{
+	resp, err := c.SSM.CreateDocument(&ssm.CreateDocumentInput{
 		Content:      aws.String(content),
 		DocumentType: aws.String("Command"),
 		Name:         aws.String(name),
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	return *resp.DocumentDescription.DocumentVersion, nil
+}
+
+// SetDefaultVersion makes a version of a document the default, which commands run without --document-version
+func (c *Client) SetDefaultVersion(name, version string) error {
+	_, err := c.SSM.UpdateDocumentDefaultVersion(&ssm.UpdateDocumentDefaultVersionInput{
+		Name:            aws.String(ConvertToSSMName(name)),
+		DocumentVersion: aws.String(version),
+	})
+	return err
+}
+
+// List returns names of paramedic documents
+func (c *Client) List() ([]string, error) {
+	names := []string{}
+	err := c.SSM.ListDocumentsPages(&ssm.ListDocumentsInput{}, func(resp *ssm.ListDocumentsOutput, last bool) bool {
+		for _, i := range resp.DocumentIdentifiers {
+			if IsParamedicDocument(*i.Name) {
+				names = append(names, ConvertFromSSMName(*i.Name))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete deletes a document with all of its versions
+func (c *Client) Delete(name string) error {
+	log.Printf("[INFO] Deleting a document '%s'", ConvertToSSMName(name))
+	_, err := c.SSM.DeleteDocument(&ssm.DeleteDocumentInput{
+		Name: aws.String(ConvertToSSMName(name)),
+	})
+	return err
+}
+
+// Tag adds tags to a document
+func (c *Client) Tag(name string, tags map[string]string) error {
+	input := &ssm.AddTagsToResourceInput{
+		// The vendored SDK predates tagging documents
+		ResourceType: aws.String("Document"),
+		ResourceId:   aws.String(ConvertToSSMName(name)),
+	}
+	keys := []string{}
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		input.Tags = append(input.Tags, &ssm.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(tags[k]),
+		})
+	}
+	_, err := c.SSM.AddTagsToResource(input)
+	return err
+}
+
+// Tags returns tags of a document
+func (c *Client) Tags(name string) (map[string]string, error) {
+	resp, err := c.SSM.ListTagsForResource(&ssm.ListTagsForResourceInput{
+		ResourceType: aws.String("Document"),
+		ResourceId:   aws.String(ConvertToSSMName(name)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, t := range resp.TagList {
+		tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return tags, nil
+}
+
+// Version is a version of a document
+type Version struct {
+	Version   string    `json:"version" yaml:"version"`
+	Default   bool      `json:"default" yaml:"default"`
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
+	// ScriptS3Key and ScriptSha256 are empty if the version doesn't refer to a script uploaded by paramedic
+	ScriptS3Key  string `json:"scriptS3Key,omitempty" yaml:"scriptS3Key,omitempty"`
+	ScriptSha256 string `json:"scriptSha256,omitempty" yaml:"scriptSha256,omitempty"`
+}
+
+// Versions returns versions of a document in ascending order
+func (c *Client) Versions(name string) ([]*Version, error) {
+	versions := []*Version{}
+	input := &ssm.ListDocumentVersionsInput{
+		Name: aws.String(ConvertToSSMName(name)),
+	}
+	for {
+		resp, err := c.SSM.ListDocumentVersions(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range resp.DocumentVersions {
+			versions = append(versions, &Version{
+				Version:   aws.StringValue(v.DocumentVersion),
+				Default:   aws.BoolValue(v.IsDefaultVersion),
+				CreatedAt: aws.TimeValue(v.CreatedDate),
+			})
+		}
+		if resp.NextToken == nil {
+			break
+		}
+		input.NextToken = resp.NextToken
+	}
+
+	for _, v := range versions {
+		resp, err := c.SSM.GetDocument(&ssm.GetDocumentInput{
+			Name:            aws.String(ConvertToSSMName(name)),
+			DocumentVersion: aws.String(v.Version),
+		})
+		if err != nil {
+			return nil, err
+		}
+		_, key, err := scriptLocation(*resp.Content)
+		if err != nil {
+			return nil, err
+		}
+		v.ScriptS3Key = key
+		v.ScriptSha256 = scriptSha256FromKey(key)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		a, _ := strconv.Atoi(versions[i].Version)
+		b, _ := strconv.Atoi(versions[j].Version)
+		return a < b
+	})
+
+	return versions, nil
+}
+
+// scriptSha256FromKey extracts SHA256 of a script from its S3 key (e.g. scripts/name-<sha256>)
+func scriptSha256FromKey(key string) string {
+	i := strings.LastIndex(key, "-")
+	if i < 0 || len(key)-i-1 != sha256.Size*2 {
+		return ""
+	}
+	return key[i+1:]
+}
+
+// Document is a document uploaded to SSM
+type Document struct {
+	Name       string
+	Version    string
+	Parameters map[string]*Parameter
+	// Rollout is nil if the document has no default rollout
+	Rollout *Rollout
+	// ScriptS3Key is the S3 key of the script the document runs
+	ScriptS3Key string
+}
+
+// Get returns the default version of a document
+func (c *Client) Get(name string) (*Document, error) {
+	return c.GetVersion(name, "")
+}
+
+// GetVersion returns a version of a document, which is the default version if empty
+func (c *Client) GetVersion(name, version string) (*Document, error) {
+	input := &ssm.GetDocumentInput{
+		Name: aws.String(ConvertToSSMName(name)),
+	}
+	if version != "" {
+		input.DocumentVersion = aws.String(version)
+	}
+	resp, err := c.SSM.GetDocument(input)
+	if err != nil {
+		return nil, err
+	}
+
+	content := struct {
+		Parameters map[string]*Parameter `json:"parameters"`
+	}{}
+	err = json.Unmarshal([]byte(*resp.Content), &content)
+	if err != nil {
+		return nil, err
+	}
+
+	_, scriptKey, err := scriptLocation(*resp.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{
+		Name:        name,
+		Version:     *resp.DocumentVersion,
+		Parameters:  content.Parameters,
+		ScriptS3Key: scriptKey,
+	}
+
+	if p, ok := content.Parameters["rolloutStages"]; ok && p.Default != nil {
+		doc.Rollout = &Rollout{
+			Stages: strings.Split(*p.Default, ","),
+		}
+		if p, ok := content.Parameters["rolloutMaxFailures"]; ok && p.Default != nil {
+			doc.Rollout.MaxFailures = *p.Default
+		}
+	}
+
+	return doc, nil
+}
+
+// Statuses of a plan
+const (
+	PlanNew       = "new"
+	PlanUnchanged = "unchanged"
+	PlanChanged   = "changed"
+)
+
+// Plan is what uploading a definition changes from the default version of the document
+type Plan struct {
+	Name    string `json:"name" yaml:"name"`
+	Status  string `json:"status" yaml:"status"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// DocumentDiff and ScriptDiff are unified diffs, which are empty if unchanged
+	DocumentDiff string `json:"documentDiff,omitempty" yaml:"documentDiff,omitempty"`
+	ScriptDiff   string `json:"scriptDiff,omitempty" yaml:"scriptDiff,omitempty"`
+}
+
+// Plan compares a definition with the default version of the document and its script in S3
+func (c *Client) Plan(d *Definition) (*Plan, error) {
+	name := ConvertToSSMName(d.Name)
+	plan := &Plan{Name: d.Name}
+
+	content, err := d.DocumentContent(c.ScriptS3Bucket, c.scriptKey(d))
+	if err != nil {
+		return nil, err
+	}
+	newContent, err := indentJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	oldContent, oldScript := "", ""
+	resp, err := c.SSM.GetDocument(&ssm.GetDocumentInput{
+		Name: aws.String(name),
+	})
+	if err != nil {
+		aErr, ok := err.(awserr.Error)
+		if !ok || aErr.Code() != ssm.ErrCodeInvalidDocument {
+			return nil, err
+		}
+		plan.Status = PlanNew
+	} else {
+		plan.Version = aws.StringValue(resp.DocumentVersion)
+		oldContent, err = indentJSON(aws.StringValue(resp.Content))
+		if err != nil {
+			return nil, err
+		}
+		oldScript, err = c.getScript(aws.StringValue(resp.Content))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	documentFrom := fmt.Sprintf("%s (version %s)", name, plan.Version)
+	scriptFrom := fmt.Sprintf("%s (version %s)", d.Name, plan.Version)
+	if plan.Status == PlanNew {
+		documentFrom, scriptFrom = "/dev/null", "/dev/null"
+	}
+	plan.DocumentDiff = UnifiedDiff(oldContent, newContent, documentFrom, name)
+	plan.ScriptDiff = UnifiedDiff(oldScript, d.Script, scriptFrom, d.Name)
+
+	if plan.Status == "" {
+		plan.Status = PlanUnchanged
+		if plan.DocumentDiff != "" || plan.ScriptDiff != "" {
+			plan.Status = PlanChanged
+		}
+	}
+
+	return plan, nil
+}
+
+// getScript downloads the script a document content refers to.
+// It returns an empty script if the content refers to none.
+func (c *Client) getScript(content string) (string, error) {
+	bucket, key, err := scriptLocation(content)
+	if err != nil {
+		return "", err
+	}
+	if bucket == "" || key == "" {
+		return "", nil
+	}
+
+	log.Printf("[DEBUG] Downloading a script from s3://%s/%s", bucket, key)
+	resp, err := c.S3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// indentJSON formats JSON so that it can be compared line by line
+func indentJSON(s string) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return "", err
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// scriptLocation returns the S3 bucket and key of the script a document content refers to
+func scriptLocation(content string) (string, string, error) {
+	doc := struct {
+		MainSteps []struct {
+			Inputs struct {
+				RunCommand []string `json:"runCommand"`
+			} `json:"inputs"`
+		} `json:"mainSteps"`
+	}{}
+	if err := json.Unmarshal([]byte(content), &doc); err != nil {
+		return "", "", err
+	}
+
+	var bucket, key string
+	for _, s := range doc.MainSteps {
+		for _, c := range s.Inputs.RunCommand {
+			if strings.HasPrefix(c, "export PARAMEDIC_SCRIPT_S3_BUCKET=") {
+				bucket = strings.TrimPrefix(c, "export PARAMEDIC_SCRIPT_S3_BUCKET=")
+			}
+			if strings.HasPrefix(c, "export PARAMEDIC_SCRIPT_S3_KEY=") {
+				key = strings.TrimPrefix(c, "export PARAMEDIC_SCRIPT_S3_KEY=")
+			}
+		}
+	}
+	return bucket, key, nil
 }
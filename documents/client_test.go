@@ -0,0 +1,106 @@
+package documents
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ryotarai/paramedic/awsclient/fake"
+)
+
+func TestPlan(t *testing.T) {
+	a := fake.New()
+	if _, err := a.S3.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket")}); err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{SSM: a.SSM, S3: a.S3, ScriptS3Bucket: "bucket", ScriptS3KeyPrefix: "scripts/"}
+
+	d := &Definition{Name: "foo", Description: "Foo", Script: "echo foo\n"}
+	plan, err := c.Plan(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Status != PlanNew || !strings.Contains(plan.ScriptDiff, "+echo foo\n") {
+		t.Errorf("got %+v, want a new document", plan)
+	}
+
+	if _, err := c.Create(d, true); err != nil {
+		t.Fatal(err)
+	}
+	plan, err = c.Plan(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Status != PlanUnchanged || plan.DocumentDiff != "" || plan.ScriptDiff != "" || plan.Version != "1" {
+		t.Errorf("got %+v, want unchanged version 1", plan)
+	}
+
+	d.Script = "echo bar\n"
+	plan, err = c.Plan(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Status != PlanChanged {
+		t.Errorf("got %s, want %s", plan.Status, PlanChanged)
+	}
+	if !strings.Contains(plan.ScriptDiff, "-echo foo\n+echo bar\n") {
+		t.Errorf("unexpected script diff:\n%s", plan.ScriptDiff)
+	}
+	if !strings.Contains(plan.DocumentDiff, "+          \"export PARAMEDIC_SCRIPT_S3_KEY=scripts/foo-"+d.ScriptSha256()) {
+		t.Errorf("unexpected document diff:\n%s", plan.DocumentDiff)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	a := fake.New()
+	if _, err := a.S3.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket")}); err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{SSM: a.SSM, S3: a.S3, ScriptS3Bucket: "bucket", ScriptS3KeyPrefix: "scripts/"}
+
+	d := &Definition{Name: "foo", Script: "echo foo\n"}
+	if v, err := c.Create(d, true); err != nil || v != "1" {
+		t.Fatalf("got version %s, %v, want 1", v, err)
+	}
+	first := d.ScriptSha256()
+
+	d.Script = "echo bar\n"
+	if v, err := c.Create(d, false); err != nil || v != "2" {
+		t.Fatalf("got version %s, %v, want 2", v, err)
+	}
+
+	versions, err := c.Versions("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("got %d versions, want 2", len(versions))
+	}
+	if v := versions[0]; v.Version != "1" || !v.Default || v.ScriptSha256 != first {
+		t.Errorf("got %+v, want the default version 1", v)
+	}
+	if v := versions[1]; v.Version != "2" || v.Default || v.ScriptSha256 != d.ScriptSha256() {
+		t.Errorf("got %+v, want the staged version 2", v)
+	}
+
+	if err := c.SetDefaultVersion("foo", "2"); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := c.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != "2" {
+		t.Errorf("got default version %s, want 2", doc.Version)
+	}
+
+	// Reverting a change makes the existing version the default
+	d.Script = "echo foo\n"
+	if v, err := c.Create(d, true); err != nil || v != "1" {
+		t.Errorf("got version %s, %v, want 1", v, err)
+	}
+	if doc, err := c.Get("foo"); err != nil || doc.Version != "1" {
+		t.Errorf("got %+v, %v, want the default version 1", doc, err)
+	}
+}
@@ -7,17 +7,37 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ryotarai/paramedic/rollout"
 	yaml "gopkg.in/yaml.v2"
 )
 
+// DefaultTimeout is the execution timeout of a document without timeout
+const DefaultTimeout = time.Hour
+
+// MaxTimeout is the maximum execution timeout SSM accepts
+const MaxTimeout = 48 * time.Hour
+
 type Definition struct {
 	Name        string `yaml:"name"`
 	Description string `yaml:"description"`
 	Script      string `yaml:"script"`
 	ScriptFile  string `yaml:"scriptFile"`
 	Timeout     string `yaml:"timeout"`
+
+	Parameters map[string]*Parameter `yaml:"parameters"`
+	Rollout    *Rollout              `yaml:"rollout"`
+}
+
+// Rollout is the default staged rollout of a document
+type Rollout struct {
+	Stages      []string `yaml:"stages"`
+	MaxFailures string   `yaml:"maxFailures"`
 }
 
 func LoadDefinition(file string) (*Definition, error) {
@@ -56,56 +76,175 @@ func LoadDefinition(file string) (*Definition, error) {
 		d.Script = string(b)
 	}
 
+	if _, err := d.TimeoutDuration(); err != nil {
+		return nil, err
+	}
+
+	if err := d.validateParameters(); err != nil {
+		return nil, err
+	}
+
+	if d.Rollout != nil {
+		if len(d.Rollout.Stages) == 0 {
+			// Commands would run on all instances at once without stages
+			return nil, errors.New("rollout stages are empty")
+		}
+		if _, err := rollout.ParseStages(strings.Join(d.Rollout.Stages, ",")); err != nil {
+			return nil, fmt.Errorf("rollout stages are invalid: %s", err)
+		}
+		if _, err := rollout.ParseThreshold(d.Rollout.MaxFailures); err != nil {
+			return nil, fmt.Errorf("rollout maxFailures is invalid: %s", err)
+		}
+	}
+
 	return d, nil
 }
 
+// TimeoutDuration returns the execution timeout of the document (e.g. timeout: 10m)
+func (d *Definition) TimeoutDuration() (time.Duration, error) {
+	if d.Timeout == "" {
+		return DefaultTimeout, nil
+	}
+
+	t, err := time.ParseDuration(d.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("timeout is invalid: %s", err)
+	}
+	if err := ValidateTimeout(t); err != nil {
+		return 0, err
+	}
+	return t, nil
+}
+
+// ValidateTimeout validates an execution timeout
+func ValidateTimeout(t time.Duration) error {
+	if t < time.Second || t > MaxTimeout {
+		return fmt.Errorf("timeout must be between 1s and %s", MaxTimeout)
+	}
+	return nil
+}
+
+func (d *Definition) validateParameters() error {
+	envNames := map[string]string{}
+	for name, p := range d.Parameters {
+		if p == nil {
+			return fmt.Errorf("parameter '%s' is empty", name)
+		}
+		if err := p.validate(name); err != nil {
+			return err
+		}
+
+		env := ParameterEnvName(name)
+		if other, ok := envNames[env]; ok {
+			return fmt.Errorf("parameters '%s' and '%s' are exported as the same environment variable %s", other, name, env)
+		}
+		envNames[env] = name
+	}
+	return nil
+}
+
+func (d *Definition) parameterNames() []string {
+	names := []string{}
+	for name := range d.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (d *Definition) ScriptSha256() string {
 	sum := sha256.Sum256([]byte(d.Script))
 	return fmt.Sprintf("%x", sum)
 }
 
 func (d *Definition) DocumentContent(bucket, key string) (string, error) {
-	allowedPattern := "[\\w-/\\.]+"
+	// Values of these parameters are not quoted in the script
+	allowedPattern := `^[\w/.-]+$`
+
+	timeout, err := d.TimeoutDuration()
+	if err != nil {
+		return "", err
+	}
+
+	parameters := map[string]interface{}{
+		"outputLogGroup": map[string]string{
+			"type":           "String",
+			"description":    "(Required) Log group name",
+			"allowedPattern": allowedPattern,
+		},
+		"outputLogStreamPrefix": map[string]string{
+			"type":           "String",
+			"description":    "(Required) Log stream name prefix",
+			"allowedPattern": allowedPattern,
+		},
+		"signalS3Bucket": map[string]string{
+			"type":           "String",
+			"description":    "(Required) S3 bucket the signal object is stored in",
+			"allowedPattern": allowedPattern,
+		},
+		"signalS3Key": map[string]string{
+			"type":           "String",
+			"description":    "(Required) S3 object key the signal object is stored at",
+			"allowedPattern": allowedPattern,
+		},
+		"executionTimeout": map[string]string{
+			"type":           "String",
+			"description":    "(Optional) Execution timeout in seconds",
+			"default":        strconv.FormatInt(int64(timeout/time.Second), 10),
+			"allowedPattern": "^[1-9][0-9]*$",
+		},
+	}
+
+	runCommand := []string{
+		"export PARAMEDIC_OUTPUT_LOG_GROUP={{outputLogGroup}}",
+		"export PARAMEDIC_OUTPUT_LOG_STREAM_PREFIX={{outputLogStreamPrefix}}",
+		"export PARAMEDIC_SIGNAL_S3_BUCKET={{signalS3Bucket}}",
+		"export PARAMEDIC_SIGNAL_S3_KEY={{signalS3Key}}",
+		fmt.Sprintf("export PARAMEDIC_SCRIPT_S3_BUCKET=%s", bucket),
+		fmt.Sprintf("export PARAMEDIC_SCRIPT_S3_KEY=%s", key),
+		"export PARAMEDIC_TIMEOUT_SECONDS={{executionTimeout}}",
+		// Unix time the script is killed by SSM at, so that the agent can stop it gracefully beforehand
+		"export PARAMEDIC_DEADLINE=$(($(date +%s) + {{executionTimeout}}))",
+	}
+
+	// Rollout is not used on instances, but stored as defaults of parameters
+	// so that commands run can find it from the document
+	if d.Rollout != nil {
+		maxFailures := d.Rollout.MaxFailures
+		if maxFailures == "" {
+			maxFailures = "0"
+		}
+		parameters["rolloutStages"] = map[string]string{
+			"type":        "String",
+			"description": "(Optional) Stages of rollout",
+			"default":     strings.Join(d.Rollout.Stages, ","),
+		}
+		parameters["rolloutMaxFailures"] = map[string]string{
+			"type":        "String",
+			"description": "(Optional) The maximum number of failed instances in each stage of rollout",
+			"default":     maxFailures,
+		}
+	}
+
+	for _, name := range d.parameterNames() {
+		parameters[name] = d.Parameters[name].ssmParameter()
+		// SSM rejects values containing single quotes by allowedPattern (see quoteSafePattern)
+		runCommand = append(runCommand, fmt.Sprintf("export %s='{{%s}}'", ParameterEnvName(name), name))
+	}
+
+	runCommand = append(runCommand, "exec paramedic-agent")
 
 	j := map[string]interface{}{
 		"schemaVersion": "2.2",
 		"description":   d.Description,
-		"parameters": map[string]map[string]string{
-			"outputLogGroup": map[string]string{
-				"type":           "String",
-				"description":    "(Required) Log group name",
-				"allowedPattern": allowedPattern,
-			},
-			"outputLogStreamPrefix": map[string]string{
-				"type":           "String",
-				"description":    "(Required) Log stream name prefix",
-				"allowedPattern": allowedPattern,
-			},
-			"signalS3Bucket": map[string]string{
-				"type":           "String",
-				"description":    "(Required) S3 bucket the signal object is stored in",
-				"allowedPattern": allowedPattern,
-			},
-			"signalS3Key": map[string]string{
-				"type":           "String",
-				"description":    "(Required) S3 object key the signal object is stored at",
-				"allowedPattern": allowedPattern,
-			},
-		},
+		"parameters":    parameters,
 		"mainSteps": []interface{}{
 			map[string]interface{}{
 				"action": "aws:runShellScript",
 				"name":   "script",
 				"inputs": map[string]interface{}{
-					"runCommand": []string{
-						"export PARAMEDIC_OUTPUT_LOG_GROUP={{outputLogGroup}}",
-						"export PARAMEDIC_OUTPUT_LOG_STREAM_PREFIX={{outputLogStreamPrefix}}",
-						"export PARAMEDIC_SIGNAL_S3_BUCKET={{signalS3Bucket}}",
-						"export PARAMEDIC_SIGNAL_S3_KEY={{signalS3Key}}",
-						fmt.Sprintf("export PARAMEDIC_SCRIPT_S3_BUCKET=%s", bucket),
-						fmt.Sprintf("export PARAMEDIC_SCRIPT_S3_KEY=%s", key),
-						"exec paramedic-agent",
-					},
+					"runCommand":     runCommand,
+					"timeoutSeconds": "{{executionTimeout}}",
 				},
 			},
 		},
@@ -117,3 +256,54 @@ func (d *Definition) DocumentContent(bucket, key string) (string, error) {
 	}
 	return string(b), nil
 }
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// RunCommand renders the runShellScript commands of the document as SSM does,
+// replacing placeholders with values or defaults of parameters.
+// Values of internal parameters (e.g. outputLogGroup) must be included in values.
+func (d *Definition) RunCommand(bucket, key string, values map[string]string) ([]string, error) {
+	content, err := d.DocumentContent(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := struct {
+		Parameters map[string]*Parameter `json:"parameters"`
+		MainSteps  []struct {
+			Inputs struct {
+				RunCommand []string `json:"runCommand"`
+			} `json:"inputs"`
+		} `json:"mainSteps"`
+	}{}
+	if err := json.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.MainSteps) == 0 {
+		return nil, errors.New("the document has no steps")
+	}
+
+	missing := []string{}
+	render := func(s string) string {
+		return placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+			name := placeholderPattern.FindStringSubmatch(m)[1]
+			if v, ok := values[name]; ok {
+				return v
+			}
+			if p, ok := doc.Parameters[name]; ok && p.Default != nil {
+				return *p.Default
+			}
+			missing = append(missing, name)
+			return m
+		})
+	}
+
+	commands := []string{}
+	for _, c := range doc.MainSteps[0].Inputs.RunCommand {
+		commands = append(commands, render(c))
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("parameter %s is required", strings.Join(missing, ", "))
+	}
+	return commands, nil
+}
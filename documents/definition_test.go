@@ -1,11 +1,13 @@
 package documents
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestLoadDefinition(t *testing.T) {
@@ -44,3 +46,144 @@ scriptFile: '%s'`, scriptFile.Name())
 		t.Errorf("got %+v, want %+v", d, want)
 	}
 }
+
+func TestLoadDefinitionRollout(t *testing.T) {
+	examples := map[string]bool{
+		"rollout:\n  stages: [1, 50%, 100%]\n": true,
+		"rollout:\n  stages: []\n":             false,
+		"rollout:\n  maxFailures: 1\n":         false,
+		"rollout:\n  stages: [50%]\n":          false,
+	}
+	for rollout, ok := range examples {
+		defFile, err := ioutil.TempFile("", "paramedic-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(defFile.Name())
+		fmt.Fprintf(defFile, "script: echo\n%s", rollout)
+		defFile.Close()
+
+		_, err = LoadDefinition(defFile.Name())
+		if ok && err != nil {
+			t.Errorf("%q: %s", rollout, err)
+		}
+		if !ok && err == nil {
+			t.Errorf("%q: no error", rollout)
+		}
+	}
+}
+
+func TestDocumentContentWithParameters(t *testing.T) {
+	d := &Definition{
+		Name:   "foo",
+		Script: "bar",
+		Parameters: map[string]*Parameter{
+			"serviceName": {Type: "String", Description: "Service"},
+			"count":       {Type: "Integer"},
+		},
+	}
+
+	content, err := d.DocumentContent("bucket", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := struct {
+		Parameters map[string]*Parameter `json:"parameters"`
+		MainSteps  []struct {
+			Inputs struct {
+				RunCommand []string `json:"runCommand"`
+			} `json:"inputs"`
+		} `json:"mainSteps"`
+	}{}
+	if err := json.Unmarshal([]byte(content), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := doc.Parameters["count"]; p == nil || p.Type != "String" || p.AllowedPattern == "" {
+		t.Errorf("count parameter is %+v", p)
+	}
+	if p := doc.Parameters["serviceName"]; p == nil || p.Description != "Service" {
+		t.Errorf("serviceName parameter is %+v", p)
+	}
+
+	cmds := doc.MainSteps[0].Inputs.RunCommand
+	want := []string{
+		"export PARAMEDIC_PARAM_COUNT='{{count}}'",
+		"export PARAMEDIC_PARAM_SERVICE_NAME='{{serviceName}}'",
+		"exec paramedic-agent",
+	}
+	if got := cmds[len(cmds)-3:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTimeoutDuration(t *testing.T) {
+	examples := []struct {
+		timeout string
+		want    time.Duration
+		ok      bool
+	}{
+		{timeout: "", want: DefaultTimeout, ok: true},
+		{timeout: "10m", want: 10 * time.Minute, ok: true},
+		{timeout: "0s", ok: false},
+		{timeout: "49h", ok: false},
+		{timeout: "10", ok: false},
+	}
+
+	for _, e := range examples {
+		d := &Definition{Timeout: e.timeout}
+		got, err := d.TimeoutDuration()
+		if e.ok && (err != nil || got != e.want) {
+			t.Errorf("TimeoutDuration() with %q = %v, %v, want %v", e.timeout, got, err, e.want)
+		}
+		if !e.ok && err == nil {
+			t.Errorf("TimeoutDuration() with %q returns no error", e.timeout)
+		}
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	port := "80"
+	d := &Definition{
+		Name:    "foo",
+		Script:  "bar",
+		Timeout: "10m",
+		Parameters: map[string]*Parameter{
+			"serviceName": {Type: "String"},
+			"port":        {Type: "Integer", Default: &port},
+		},
+	}
+
+	values := map[string]string{
+		"outputLogGroup":        "group",
+		"outputLogStreamPrefix": "prefix/",
+		"signalS3Bucket":        "signal-bucket",
+		"signalS3Key":           "signal-key",
+	}
+	if _, err := d.RunCommand("bucket", "key", values); err == nil {
+		t.Error("RunCommand without serviceName returns no error")
+	}
+
+	values["serviceName"] = "nginx"
+	cmds, err := d.RunCommand("bucket", "key", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"export PARAMEDIC_OUTPUT_LOG_GROUP=group",
+		"export PARAMEDIC_OUTPUT_LOG_STREAM_PREFIX=prefix/",
+		"export PARAMEDIC_SIGNAL_S3_BUCKET=signal-bucket",
+		"export PARAMEDIC_SIGNAL_S3_KEY=signal-key",
+		"export PARAMEDIC_SCRIPT_S3_BUCKET=bucket",
+		"export PARAMEDIC_SCRIPT_S3_KEY=key",
+		"export PARAMEDIC_TIMEOUT_SECONDS=600",
+		"export PARAMEDIC_DEADLINE=$(($(date +%s) + 600))",
+		"export PARAMEDIC_PARAM_PORT='80'",
+		"export PARAMEDIC_PARAM_SERVICE_NAME='nginx'",
+		"exec paramedic-agent",
+	}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("got %v, want %v", cmds, want)
+	}
+}
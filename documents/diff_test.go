@@ -0,0 +1,41 @@
+package documents
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	if got := UnifiedDiff("a\nb\n", "a\nb\n", "old", "new"); got != "" {
+		t.Errorf("got %q for the same texts", got)
+	}
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := `--- old
++++ new
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	if got := UnifiedDiff(a, b, "old", "new"); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	want = `--- old
++++ new
+@@ -0,0 +1,2 @@
++x
++y
+`
+	if got := UnifiedDiff("", "x\ny\n", "old", "new"); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,245 @@
+package documents
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// internalParameterNames are parameters paramedic passes to every document
+var internalParameterNames = []string{
+	"outputLogGroup",
+	"outputLogStreamPrefix",
+	"signalS3Bucket",
+	"signalS3Key",
+	"executionTimeout",
+	"rolloutStages",
+	"rolloutMaxFailures",
+}
+
+var parameterNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// noQuotePattern is the allowedPattern of String parameters without allowedPattern and allowedValues.
+// Values are quoted with single quotes in the script, so SSM must reject them as well as paramedic.
+const noQuotePattern = `^[^']*$`
+
+// Parameter is a user-defined parameter of a document
+type Parameter struct {
+	Type           string   `yaml:"type" json:"type"`
+	Description    string   `yaml:"description" json:"description,omitempty"`
+	Default        *string  `yaml:"default" json:"default,omitempty"`
+	AllowedPattern string   `yaml:"allowedPattern" json:"allowedPattern,omitempty"`
+	AllowedValues  []string `yaml:"allowedValues" json:"allowedValues,omitempty"`
+}
+
+func isInternalParameter(name string) bool {
+	for _, n := range internalParameterNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parameter) validate(name string) error {
+	if !parameterNamePattern.MatchString(name) {
+		return fmt.Errorf("parameter name '%s' is invalid", name)
+	}
+	if isInternalParameter(name) {
+		return fmt.Errorf("parameter name '%s' is reserved", name)
+	}
+
+	switch p.Type {
+	case "", "String", "Integer", "Boolean":
+	default:
+		return fmt.Errorf("type of parameter '%s' must be one of String, Integer and Boolean", name)
+	}
+
+	if p.AllowedPattern != "" {
+		if _, err := regexp.Compile(p.AllowedPattern); err != nil {
+			return fmt.Errorf("allowedPattern of parameter '%s' is invalid: %s", name, err)
+		}
+		safe, err := quoteSafePattern(p.AllowedPattern)
+		if err != nil {
+			return fmt.Errorf("allowedPattern of parameter '%s' is invalid: %s", name, err)
+		}
+		if !safe {
+			return fmt.Errorf("allowedPattern of parameter '%s' must be anchored with ^ and $ and must not match single quotes", name)
+		}
+	}
+	for _, v := range p.AllowedValues {
+		if strings.Contains(v, "'") {
+			return fmt.Errorf("allowedValues of parameter '%s' must not contain single quotes", name)
+		}
+	}
+
+	if p.Default != nil {
+		if err := p.validateValue(name, *p.Default); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ssmParameter converts a parameter in a definition to one in an SSM document.
+// SSM documents only have String type, so the other types are expressed with
+// allowedPattern or allowedValues.
+func (p *Parameter) ssmParameter() *Parameter {
+	s := &Parameter{
+		Type:           "String",
+		Description:    p.Description,
+		Default:        p.Default,
+		AllowedPattern: p.AllowedPattern,
+		AllowedValues:  p.AllowedValues,
+	}
+
+	switch p.Type {
+	case "Integer":
+		if s.AllowedPattern == "" {
+			s.AllowedPattern = `^-?[0-9]+$`
+		}
+	case "Boolean":
+		if len(s.AllowedValues) == 0 {
+			s.AllowedValues = []string{"true", "false"}
+		}
+	default:
+		if s.AllowedPattern == "" && len(s.AllowedValues) == 0 {
+			s.AllowedPattern = noQuotePattern
+		}
+	}
+
+	return s
+}
+
+// quoteSafePattern returns true if a pattern is anchored at both ends and none of its characters matches
+// a single quote, so that no value SSM accepts with it contains single quotes
+func quoteSafePattern(pattern string) (bool, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return false, err
+	}
+	re = re.Simplify()
+	return anchoredRegexp(re) && !matchesQuote(re), nil
+}
+
+func anchoredRegexp(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpConcat:
+		return len(re.Sub) >= 2 && re.Sub[0].Op == syntax.OpBeginText && re.Sub[len(re.Sub)-1].Op == syntax.OpEndText
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if !anchoredRegexp(sub) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpCapture:
+		return anchoredRegexp(re.Sub[0])
+	}
+	return false
+}
+
+func matchesQuote(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return true
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == '\'' {
+				return true
+			}
+		}
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= '\'' && '\'' <= re.Rune[i+1] {
+				return true
+			}
+		}
+	}
+	for _, sub := range re.Sub {
+		if matchesQuote(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parameter) validateValue(name, value string) error {
+	if strings.Contains(value, "'") {
+		return fmt.Errorf("value of parameter '%s' must not contain single quotes", name)
+	}
+
+	s := p.ssmParameter()
+
+	if len(s.AllowedValues) > 0 {
+		found := false
+		for _, v := range s.AllowedValues {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("value of parameter '%s' must be one of %s", name, strings.Join(s.AllowedValues, ", "))
+		}
+	}
+
+	if s.AllowedPattern != "" {
+		re, err := regexp.Compile(s.AllowedPattern)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("value of parameter '%s' does not match %s", name, s.AllowedPattern)
+		}
+	}
+
+	return nil
+}
+
+// ValidateParameters validates values against parameters of a document
+func ValidateParameters(params map[string]*Parameter, values map[string]string) error {
+	for name, value := range values {
+		p, ok := params[name]
+		if !ok || isInternalParameter(name) {
+			return fmt.Errorf("parameter '%s' is not defined in the document", name)
+		}
+		if err := p.validateValue(name, value); err != nil {
+			return err
+		}
+	}
+
+	missing := []string{}
+	for name, p := range params {
+		if isInternalParameter(name) {
+			continue
+		}
+		if _, ok := values[name]; !ok && p.Default == nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("parameter %s is required", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// ParameterEnvName returns a name of the environment variable a parameter is exported as
+// (e.g. serviceName => PARAMEDIC_PARAM_SERVICE_NAME)
+func ParameterEnvName(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(runes[i-1]) && runes[i-1] != '_' {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return "PARAMEDIC_PARAM_" + b.String()
+}
@@ -0,0 +1,92 @@
+package documents
+
+import "testing"
+
+func TestParameterEnvName(t *testing.T) {
+	examples := map[string]string{
+		"service":     "PARAMEDIC_PARAM_SERVICE",
+		"serviceName": "PARAMEDIC_PARAM_SERVICE_NAME",
+		"service_id":  "PARAMEDIC_PARAM_SERVICE_ID",
+		"dryRunMode":  "PARAMEDIC_PARAM_DRY_RUN_MODE",
+	}
+	for name, want := range examples {
+		if got := ParameterEnvName(name); got != want {
+			t.Errorf("ParameterEnvName(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestValidateParameters(t *testing.T) {
+	service, force, mode := "nginx", "false", "reload"
+	params := map[string]*Parameter{
+		"service": {Type: "String", Default: &service},
+		"count":   {Type: "Integer"},
+		"force":   {Type: "Boolean", Default: &force},
+		"mode":    {Type: "String", AllowedValues: []string{"reload", "restart"}, Default: &mode},
+	}
+	for name, p := range params {
+		if err := p.validate(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	examples := []struct {
+		values map[string]string
+		ok     bool
+	}{
+		{values: map[string]string{"count": "3"}, ok: true},
+		{values: map[string]string{"count": "3", "mode": "restart", "force": "true"}, ok: true},
+		{values: map[string]string{}, ok: false},
+		{values: map[string]string{"count": "three"}, ok: false},
+		{values: map[string]string{"count": "3", "force": "yes"}, ok: false},
+		{values: map[string]string{"count": "3", "mode": "stop"}, ok: false},
+		{values: map[string]string{"count": "3", "unknown": "a"}, ok: false},
+		{values: map[string]string{"count": "3", "signalS3Key": "a"}, ok: false},
+		{values: map[string]string{"count": "3", "service": "it's"}, ok: false},
+	}
+
+	for _, e := range examples {
+		err := ValidateParameters(params, e.values)
+		if e.ok && err != nil {
+			t.Errorf("ValidateParameters(%v) returns an error: %s", e.values, err)
+		}
+		if !e.ok && err == nil {
+			t.Errorf("ValidateParameters(%v) returns no error", e.values)
+		}
+	}
+}
+
+func TestParameterSingleQuotes(t *testing.T) {
+	examples := map[string]bool{
+		`^[a-z]+$`:           true,
+		`^(app|web)-[0-9]+$`: true,
+		`^a$|^b$`:            true,
+		`[a-z]+`:             false,
+		`^[a-z]+`:            false,
+		`^.*$`:               false,
+		`^[^"]+$`:            false,
+		`^[ -~]+$`:           false,
+		`^\W$`:               false,
+		`^it's$`:             false,
+		`^(app|web)|[0-9]+$`: false,
+	}
+	for pattern, ok := range examples {
+		p := &Parameter{Type: "String", AllowedPattern: pattern}
+		err := p.validate("service")
+		if ok && err != nil {
+			t.Errorf("%s: %s", pattern, err)
+		}
+		if !ok && err == nil {
+			t.Errorf("%s: no error", pattern)
+		}
+	}
+
+	p := &Parameter{Type: "String", AllowedValues: []string{"it's"}}
+	if err := p.validate("service"); err == nil {
+		t.Error("allowedValues containing a single quote: no error")
+	}
+
+	if got := (&Parameter{Type: "String"}).ssmParameter().AllowedPattern; got != noQuotePattern {
+		t.Errorf("got allowedPattern %s, want %s", got, noQuotePattern)
+	}
+}
@@ -0,0 +1,301 @@
+package gc
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/ryotarai/paramedic/awsclient"
+	"github.com/ryotarai/paramedic/documents"
+	"github.com/ryotarai/paramedic/store"
+)
+
+// Kinds of objects
+const (
+	KindScript = "script"
+	KindSignal = "signal"
+)
+
+// deleteObjectsLimit is the maximum number of objects DeleteObjects accepts
+const deleteObjectsLimit = 1000
+
+// terminalStatuses are statuses of commands which don't pick up signals anymore
+var terminalStatuses = []string{"Success", "Cancelled", "Failed", "TimedOut"}
+
+// Config is where objects are stored and how long they are retained
+type Config struct {
+	ScriptS3Bucket    string
+	ScriptS3KeyPrefix string
+	SignalS3Bucket    string
+	SignalS3KeyPrefix string
+	// Retention is how long scripts are kept for commands in the history.
+	// Objects modified within it are never deleted.
+	Retention time.Duration
+}
+
+// GC deletes scripts and signal objects which are no longer used
+type GC struct {
+	Documents *documents.Client
+	Store     *store.Store
+	SSM       awsclient.SSM
+	S3        awsclient.S3
+	Config    *Config
+
+	now func() time.Time
+}
+
+// Object is an object to be deleted
+type Object struct {
+	Kind   string `json:"kind" yaml:"kind"`
+	Bucket string `json:"bucket" yaml:"bucket"`
+	Key    string `json:"key" yaml:"key"`
+	Size   int64  `json:"size" yaml:"size"`
+}
+
+func (g *GC) currentTime() time.Time {
+	if g.now != nil {
+		return g.now()
+	}
+	return time.Now()
+}
+
+// Plan returns objects to be deleted
+func (g *GC) Plan() ([]*Object, error) {
+	objects := []*Object{}
+
+	if g.Config.ScriptS3Bucket != "" {
+		scripts, err := g.planScripts()
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, scripts...)
+	}
+
+	if g.Config.SignalS3Bucket != "" {
+		signals, err := g.planSignals()
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, signals...)
+	}
+
+	return objects, nil
+}
+
+// planScripts returns scripts which are referenced by neither any document version nor commands in the retention window
+func (g *GC) planScripts() ([]*Object, error) {
+	keep := map[string]bool{}
+	// Prefixes of scripts of documents whose commands don't record the script
+	keepPrefixes := []string{}
+
+	names, err := g.Documents.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		versions, err := g.Documents.Versions(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			if v.ScriptS3Key != "" {
+				keep[v.ScriptS3Key] = true
+			}
+		}
+	}
+
+	since := g.currentTime().Add(-g.Config.Retention)
+	records, err := g.listCommands(since)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.ScriptS3Key != "" {
+			keep[r.ScriptS3Key] = true
+		} else if r.DocumentName != "" {
+			keepPrefixes = append(keepPrefixes, fmt.Sprintf("%s%s-", g.Config.ScriptS3KeyPrefix, r.DocumentName))
+		}
+	}
+
+	objects := []*Object{}
+	err = g.eachObject(g.Config.ScriptS3Bucket, g.Config.ScriptS3KeyPrefix, func(o *s3.Object) {
+		key := aws.StringValue(o.Key)
+		if keep[key] || hasAnyPrefix(key, keepPrefixes) {
+			return
+		}
+		if aws.TimeValue(o.LastModified).After(since) {
+			// may be uploaded for a document being created
+			return
+		}
+		objects = append(objects, &Object{Kind: KindScript, Bucket: g.Config.ScriptS3Bucket, Key: key, Size: aws.Int64Value(o.Size)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// planSignals returns signal objects of commands in terminal states
+func (g *GC) planSignals() ([]*Object, error) {
+	records, err := g.listCommands(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	byPcommandID := map[string]*store.CommandRecord{}
+	for _, r := range records {
+		byPcommandID[r.PcommandID] = r
+	}
+
+	since := g.currentTime().Add(-g.Config.Retention)
+	objects := []*Object{}
+	var listErr error
+	err = g.eachObject(g.Config.SignalS3Bucket, g.Config.SignalS3KeyPrefix, func(o *s3.Object) {
+		if listErr != nil {
+			return
+		}
+
+		key := aws.StringValue(o.Key)
+		// <prefix><pcommandID>.json or <prefix><pcommandID>/<instanceID>.json
+		pcommandID := strings.TrimPrefix(key, g.Config.SignalS3KeyPrefix)
+		pcommandID = strings.TrimSuffix(strings.SplitN(pcommandID, "/", 2)[0], ".json")
+
+		r, ok := byPcommandID[pcommandID]
+		if !ok {
+			// the command has expired from the history
+			if aws.TimeValue(o.LastModified).After(since) {
+				return
+			}
+		} else {
+			finished, err := g.finished(r)
+			if err != nil {
+				listErr = err
+				return
+			}
+			if !finished {
+				return
+			}
+		}
+		objects = append(objects, &Object{Kind: KindSignal, Bucket: g.Config.SignalS3Bucket, Key: key, Size: aws.Int64Value(o.Size)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if listErr != nil {
+		return nil, listErr
+	}
+	return objects, nil
+}
+
+// finished returns true if a command is in a terminal state.
+// The status in the history may be stale, so SSM is asked unless the history says it finished.
+func (g *GC) finished(r *store.CommandRecord) (bool, error) {
+	if containsString(terminalStatuses, r.Status) {
+		return true, nil
+	}
+
+	resp, err := g.SSM.ListCommands(&ssm.ListCommandsInput{
+		CommandId: aws.String(r.CommandID),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeInvalidCommandId {
+			// SSM has forgotten the command
+			return true, nil
+		}
+		return false, err
+	}
+	if len(resp.Commands) == 0 {
+		return true, nil
+	}
+	return containsString(terminalStatuses, aws.StringValue(resp.Commands[0].Status)), nil
+}
+
+func (g *GC) listCommands(since time.Time) ([]*store.CommandRecord, error) {
+	records := []*store.CommandRecord{}
+	opts := &store.ListCommandsOptions{Since: since}
+	for {
+		rs, nextToken, err := g.Store.ListCommands(opts)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rs...)
+		if nextToken == "" {
+			break
+		}
+		opts.NextToken = nextToken
+	}
+	return records, nil
+}
+
+func (g *GC) eachObject(bucket, prefix string, fn func(*s3.Object)) error {
+	return g.S3.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(resp *s3.ListObjectsV2Output, last bool) bool {
+		for _, o := range resp.Contents {
+			fn(o)
+		}
+		return true
+	})
+}
+
+// Delete deletes objects
+func (g *GC) Delete(objects []*Object) error {
+	byBucket := map[string][]*s3.ObjectIdentifier{}
+	buckets := []string{}
+	for _, o := range objects {
+		if _, ok := byBucket[o.Bucket]; !ok {
+			buckets = append(buckets, o.Bucket)
+		}
+		byBucket[o.Bucket] = append(byBucket[o.Bucket], &s3.ObjectIdentifier{Key: aws.String(o.Key)})
+	}
+
+	for _, bucket := range buckets {
+		ids := byBucket[bucket]
+		for len(ids) > 0 {
+			n := len(ids)
+			if n > deleteObjectsLimit {
+				n = deleteObjectsLimit
+			}
+			log.Printf("[INFO] Deleting %d objects in s3://%s", n, bucket)
+			resp, err := g.S3.DeleteObjects(&s3.DeleteObjectsInput{
+				Bucket: aws.String(bucket),
+				Delete: &s3.Delete{
+					Objects: ids[:n],
+					Quiet:   aws.Bool(true),
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if len(resp.Errors) > 0 {
+				e := resp.Errors[0]
+				return fmt.Errorf("failed to delete %d objects in s3://%s (e.g. %s: %s)", len(resp.Errors), bucket, aws.StringValue(e.Key), aws.StringValue(e.Message))
+			}
+			ids = ids[n:]
+		}
+	}
+	return nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package gc
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/ryotarai/paramedic/awsclient/fake"
+	"github.com/ryotarai/paramedic/documents"
+	"github.com/ryotarai/paramedic/store"
+)
+
+func TestGC(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+
+	a := fake.New()
+	if _, err := a.S3.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("paramedic")}); err != nil {
+		t.Fatal(err)
+	}
+	st := store.New(a.DynamoDB, "")
+	if err := st.CreateTables(&store.TableOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	docs := &documents.Client{SSM: a.SSM, S3: a.S3, ScriptS3Bucket: "paramedic", ScriptS3KeyPrefix: "scripts/"}
+
+	put := func(key string, modified time.Time) {
+		if _, err := a.S3.PutObject(&s3.PutObjectInput{Bucket: aws.String("paramedic"), Key: aws.String(key), Body: strings.NewReader("x")}); err != nil {
+			t.Fatal(err)
+		}
+		a.S3.SetLastModified("paramedic", key, modified)
+	}
+
+	// Scripts of every version of documents are kept
+	d := &documents.Definition{Name: "foo", Script: "echo foo\n"}
+	if _, err := docs.Create(d, true); err != nil {
+		t.Fatal(err)
+	}
+	a.S3.SetLastModified("paramedic", "scripts/foo-"+d.ScriptSha256(), old)
+	d.Script = "echo bar\n"
+	if _, err := docs.Create(d, false); err != nil {
+		t.Fatal(err)
+	}
+	a.S3.SetLastModified("paramedic", "scripts/foo-"+d.ScriptSha256(), old)
+
+	put("scripts/deleted-1", old)
+	put("scripts/deleted-2", now)
+	put("scripts/deleted-3", old)
+	put("scripts/deleted-4", old)
+	put("scripts/legacy-1", old)
+
+	// The instance keeps running commands
+	a.SSM.AddInstance(&fake.Instance{ID: "i-aaa", Name: "app-1"})
+	a.SSM.Agent = func(string, *ssm.Command) *fake.Execution {
+		return &fake.Execution{}
+	}
+	resp, err := a.SSM.SendCommand(&ssm.SendCommandInput{DocumentName: aws.String("paramedic-foo"), InstanceIds: aws.StringSlice([]string{"i-aaa"})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []*store.CommandRecord{
+		{CommandID: "c-1", PcommandID: "p-1", DocumentName: "deleted", ScriptS3Key: "scripts/deleted-3", StartedAt: now.Add(-time.Hour), Status: "Success"},
+		{CommandID: "c-2", PcommandID: "p-2", DocumentName: "deleted", ScriptS3Key: "scripts/deleted-4", StartedAt: old, Status: "Failed"},
+		{CommandID: "c-3", PcommandID: "p-3", DocumentName: "legacy", StartedAt: now.Add(-time.Hour), Status: "Cancelled"},
+		// SSM has forgotten the command
+		{CommandID: "c-4", PcommandID: "p-4", DocumentName: "foo", StartedAt: old, Status: "InProgress"},
+		{CommandID: *resp.Command.CommandId, PcommandID: "p-5", DocumentName: "foo", StartedAt: now, Status: "InProgress"},
+	}
+	for _, r := range records {
+		if err := st.PutCommand(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	put("signals/p-1.json", now)
+	put("signals/p-4/i-aaa.json", now)
+	put("signals/p-5/i-aaa.json", now)
+	put("signals/p-6.json", old)
+	put("signals/p-7.json", now)
+
+	g := &GC{
+		Documents: docs,
+		Store:     st,
+		SSM:       a.SSM,
+		S3:        a.S3,
+		Config: &Config{
+			ScriptS3Bucket:    "paramedic",
+			ScriptS3KeyPrefix: "scripts/",
+			SignalS3Bucket:    "paramedic",
+			SignalS3KeyPrefix: "signals/",
+			Retention:         24 * time.Hour,
+		},
+		now: func() time.Time { return now },
+	}
+
+	objects, err := g.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	for _, o := range objects {
+		keys = append(keys, o.Kind+" "+o.Key)
+	}
+	sort.Strings(keys)
+	want := []string{
+		"script scripts/deleted-1",
+		"script scripts/deleted-4",
+		"signal signals/p-1.json",
+		"signal signals/p-4/i-aaa.json",
+		"signal signals/p-6.json",
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %v, want %v", keys, want)
+	}
+
+	if err := g.Delete(objects); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range want {
+		key := strings.SplitN(k, " ", 2)[1]
+		if _, ok := a.S3.Object("paramedic", key); ok {
+			t.Errorf("%s is not deleted", key)
+		}
+	}
+	if remaining := a.S3.Keys("paramedic", ""); len(remaining) != 7 {
+		t.Errorf("got %v, want 7 objects remaining", remaining)
+	}
+}
+
+func TestDeleteInBatches(t *testing.T) {
+	a := fake.New()
+	if _, err := a.S3.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("paramedic")}); err != nil {
+		t.Fatal(err)
+	}
+
+	objects := []*Object{}
+	for i := 0; i < deleteObjectsLimit+1; i++ {
+		key := fmt.Sprintf("signals/p-%d.json", i)
+		if _, err := a.S3.PutObject(&s3.PutObjectInput{Bucket: aws.String("paramedic"), Key: aws.String(key)}); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, &Object{Kind: KindSignal, Bucket: "paramedic", Key: key})
+	}
+
+	g := &GC{S3: a.S3, Config: &Config{}}
+	if err := g.Delete(objects); err != nil {
+		t.Fatal(err)
+	}
+	if keys := a.S3.Keys("paramedic", ""); len(keys) != 0 {
+		t.Errorf("got %d objects, want none", len(keys))
+	}
+}
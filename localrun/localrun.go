@@ -0,0 +1,227 @@
+// Package localrun runs a script of a document on the local machine as paramedic-agent does on instances
+package localrun
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ryotarai/paramedic/documents"
+	"github.com/ryotarai/paramedic/outputlog"
+)
+
+// InstanceID is what output logs of a local run are printed as
+const InstanceID = "localhost"
+
+// containerDir is where the script is mounted in a container
+const containerDir = "/paramedic"
+
+// Statuses of a local run, which are the same as those of SSM command invocations
+const (
+	StatusSuccess   = "Success"
+	StatusFailed    = "Failed"
+	StatusTimedOut  = "TimedOut"
+	StatusCancelled = "Cancelled"
+)
+
+// Runner runs a script of a document in a local subprocess or a Docker container
+type Runner struct {
+	Definition *documents.Definition
+	// Parameters are values of the parameters defined in the document
+	Parameters map[string]string
+	// Image is a Docker image to run the script in. The script runs in a subprocess if empty
+	Image   string
+	Printer *outputlog.Printer
+
+	dir       string
+	container string
+	cmd       *exec.Cmd
+	outputCh  chan struct{}
+	timer     *time.Timer
+	mutex     sync.Mutex
+	signaled  bool
+	timedOut  bool
+}
+
+// Commands returns the runShellScript commands the runner executes, where paramedic-agent is
+// replaced with the script
+func (r *Runner) Commands(scriptPath string) ([]string, error) {
+	values := map[string]string{
+		"outputLogGroup":        "local",
+		"outputLogStreamPrefix": "local/",
+		"signalS3Bucket":        "local",
+		"signalS3Key":           "local",
+	}
+	for k, v := range r.Parameters {
+		values[k] = v
+	}
+
+	commands, err := r.Definition.RunCommand("local", scriptPath, values)
+	if err != nil {
+		return nil, err
+	}
+
+	last := len(commands) - 1
+	if commands[last] != "exec paramedic-agent" {
+		return nil, fmt.Errorf("the document does not run paramedic-agent")
+	}
+	commands[last] = fmt.Sprintf("exec %s", scriptPath)
+	return commands, nil
+}
+
+// Start starts the script
+func (r *Runner) Start() error {
+	if err := documents.ValidateParameters(r.Definition.Parameters, r.Parameters); err != nil {
+		return err
+	}
+	timeout, err := r.Definition.TimeoutDuration()
+	if err != nil {
+		return err
+	}
+
+	dir, err := ioutil.TempDir("", "paramedic")
+	if err != nil {
+		return err
+	}
+	r.dir = dir
+
+	scriptDir := dir
+	if r.Image != "" {
+		scriptDir = containerDir
+	}
+	commands, err := r.Commands(path.Join(scriptDir, "script"))
+	if err != nil {
+		r.cleanup()
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "script"), []byte(r.Definition.Script), 0755); err != nil {
+		r.cleanup()
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "run.sh"), []byte(strings.Join(commands, "\n")+"\n"), 0644); err != nil {
+		r.cleanup()
+		return err
+	}
+
+	if r.Image == "" {
+		r.cmd = exec.Command("/bin/sh", filepath.Join(dir, "run.sh"))
+	} else {
+		// The container is named to kill it, as SIGKILL to the docker client doesn't stop the container
+		r.container = filepath.Base(dir)
+		// --init forwards signals to the script, which doesn't run as PID 1
+		r.cmd = exec.Command("docker", "run", "--rm", "-i", "--init", "--name", r.container,
+			"-v", fmt.Sprintf("%s:%s:ro", dir, containerDir),
+			r.Image, "/bin/sh", path.Join(containerDir, "run.sh"))
+	}
+	// Signals are sent to the script only by Signal, not by the terminal
+	r.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		r.cleanup()
+		return err
+	}
+	r.cmd.Stdout = pw
+	r.cmd.Stderr = pw
+
+	if err := r.cmd.Start(); err != nil {
+		pr.Close()
+		pw.Close()
+		r.cleanup()
+		return err
+	}
+	pw.Close()
+
+	r.outputCh = make(chan struct{})
+	go r.printOutput(pr)
+
+	r.timer = time.AfterFunc(timeout, func() {
+		r.mutex.Lock()
+		r.timedOut = true
+		r.mutex.Unlock()
+		r.killContainer()
+		syscall.Kill(-r.cmd.Process.Pid, syscall.SIGKILL)
+	})
+
+	return nil
+}
+
+func (r *Runner) printOutput(pr *os.File) {
+	defer close(r.outputCh)
+	defer pr.Close()
+
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		r.Printer.Print([]*outputlog.Event{{
+			Message:   scanner.Text(),
+			Timestamp: time.Now(),
+			LogStream: "local/" + InstanceID,
+		}})
+	}
+}
+
+// Signal sends a signal to the script as paramedic-agent does when the command is cancelled
+func (r *Runner) Signal(sig syscall.Signal) error {
+	r.mutex.Lock()
+	r.signaled = true
+	r.mutex.Unlock()
+	if sig == syscall.SIGKILL {
+		r.killContainer()
+	}
+	return syscall.Kill(-r.cmd.Process.Pid, sig)
+}
+
+// killContainer kills the container the script runs in, if any. It fails if the container has exited.
+func (r *Runner) killContainer() {
+	if r.container == "" {
+		return
+	}
+	if out, err := exec.Command("docker", "kill", r.container).CombinedOutput(); err != nil {
+		log.Printf("[DEBUG] Killing container %s failed: %s: %s", r.container, err, strings.TrimSpace(string(out)))
+	}
+}
+
+// Wait waits for the script to exit and returns its status
+func (r *Runner) Wait() (string, error) {
+	defer r.cleanup()
+
+	<-r.outputCh
+	err := r.cmd.Wait()
+	r.timer.Stop()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return "", err
+	}
+
+	switch {
+	case r.timedOut:
+		return StatusTimedOut, nil
+	case err == nil:
+		return StatusSuccess, nil
+	case r.signaled:
+		return StatusCancelled, nil
+	}
+	return StatusFailed, nil
+}
+
+func (r *Runner) cleanup() {
+	// The container outlives the docker client if the client is killed
+	r.killContainer()
+	if r.dir != "" {
+		os.RemoveAll(r.dir)
+	}
+}
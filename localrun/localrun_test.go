@@ -0,0 +1,122 @@
+package localrun
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ryotarai/paramedic/documents"
+	"github.com/ryotarai/paramedic/outputlog"
+)
+
+func run(t *testing.T, def *documents.Definition, params map[string]string, signalAfter time.Duration) (string, string) {
+	buf := &bytes.Buffer{}
+	printer := outputlog.NewPrinter(buf)
+	r := &Runner{Definition: def, Parameters: params, Printer: printer}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if signalAfter > 0 {
+		time.Sleep(signalAfter)
+		if err := r.Signal(syscall.SIGTERM); err != nil {
+			t.Fatal(err)
+		}
+	}
+	status, err := r.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, buf.String()
+}
+
+func TestRunner(t *testing.T) {
+	def := &documents.Definition{
+		Name:   "echo",
+		Script: "#!/bin/sh\necho \"$PARAMEDIC_PARAM_SERVICE_NAME $PARAMEDIC_TIMEOUT_SECONDS\"\necho error >&2\nexit $PARAMEDIC_PARAM_CODE\n",
+		Parameters: map[string]*documents.Parameter{
+			"serviceName": {Type: "String"},
+			"code":        {Type: "Integer"},
+		},
+	}
+
+	status, out := run(t, def, map[string]string{"serviceName": "nginx", "code": "0"}, 0)
+	if status != StatusSuccess {
+		t.Errorf("got %s, want %s", status, StatusSuccess)
+	}
+	for _, s := range []string{"| localhost | nginx 3600\n", "| localhost | error\n"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output does not contain %q:\n%s", s, out)
+		}
+	}
+
+	if status, _ := run(t, def, map[string]string{"serviceName": "nginx", "code": "1"}, 0); status != StatusFailed {
+		t.Errorf("got %s, want %s", status, StatusFailed)
+	}
+
+	r := &Runner{Definition: def, Parameters: map[string]string{"code": "0"}}
+	if err := r.Start(); err == nil {
+		t.Error("Start without a required parameter returns no error")
+	}
+}
+
+func TestRunnerSignal(t *testing.T) {
+	def := &documents.Definition{
+		Name:   "trap",
+		Script: "trap 'echo terminating; exit 143' TERM\necho started\nwhile true; do sleep 0.01; done\n",
+	}
+
+	status, out := run(t, def, nil, 200*time.Millisecond)
+	if status != StatusCancelled {
+		t.Errorf("got %s, want %s", status, StatusCancelled)
+	}
+	if !strings.Contains(out, "terminating") {
+		t.Errorf("the script does not trap the signal:\n%s", out)
+	}
+}
+
+func TestRunnerImageKill(t *testing.T) {
+	// A fake docker client, which records its arguments and keeps running as a container does
+	bin, err := ioutil.TempDir("", "paramedic-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bin)
+	logPath := filepath.Join(bin, "docker.log")
+	docker := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %s\nif [ \"$1\" = run ]; then exec sleep 10; fi\n", logPath)
+	if err := ioutil.WriteFile(filepath.Join(bin, "docker"), []byte(docker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+
+	def := &documents.Definition{Name: "sleep", Script: "#!/bin/sh\nsleep 10\n"}
+	r := &Runner{Definition: def, Image: "alpine", Printer: outputlog.NewPrinter(&bytes.Buffer{})}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := r.Signal(syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := r.Wait(); err != nil || status != StatusCancelled {
+		t.Errorf("got %s, %v, want %s", status, err, StatusCancelled)
+	}
+
+	b, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if !strings.Contains(lines[0], "--name "+r.container+" ") {
+		t.Errorf("the container is not named %s: %s", r.container, lines[0])
+	}
+	if want := "kill " + r.container; len(lines) < 2 || lines[1] != want {
+		t.Errorf("got docker commands %q, want %q after run", lines[1:], want)
+	}
+}
@@ -0,0 +1,135 @@
+package outputlog
+
+import (
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/ryotarai/paramedic/awsclient"
+)
+
+const (
+	cwlogsMaxRetries   = 5
+	cwlogsRetryBackoff = 200 * time.Millisecond
+)
+
+// CloudWatchLogsPollingReader reads new events from CloudWatch Logs on every Read.
+// It is used to follow logs without Kinesis Streams.
+type CloudWatchLogsPollingReader struct {
+	CloudWatchLogs  awsclient.CloudWatchLogs
+	LogGroup        string
+	LogStreamPrefix string
+	StartTimestamp  time.Time
+
+	nextTokens map[string]string // map[log stream]next forward token
+	sleep      func(time.Duration)
+}
+
+func (r *CloudWatchLogsPollingReader) Read() ([]*Event, error) {
+	if r.nextTokens == nil {
+		r.nextTokens = map[string]string{}
+	}
+
+	// Streams are created as instances start the command
+	streams, err := (&CloudWatchLogsReader{
+		CloudWatchLogs:  r.CloudWatchLogs,
+		LogGroup:        r.LogGroup,
+		LogStreamPrefix: r.LogStreamPrefix,
+	}).getLogStreams()
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*Event{}
+	var readErr error
+	for _, s := range streams {
+		// Events read before an error are returned with it, because the token of the stream has advanced past them
+		ev, err := r.readStream(s)
+		events = append(events, ev...)
+		if err != nil {
+			log.Printf("[DEBUG] Failed to get log events from %s stream: %s", s, err)
+			if readErr == nil {
+				readErr = err
+			}
+		}
+	}
+
+	SortEventsByTimestamp(events)
+
+	return events, readErr
+}
+
+func (r *CloudWatchLogsPollingReader) readStream(logStream string) ([]*Event, error) {
+	events := []*Event{}
+
+	for {
+		input := &cloudwatchlogs.GetLogEventsInput{
+			LogGroupName:  aws.String(r.LogGroup),
+			LogStreamName: aws.String(logStream),
+			StartFromHead: aws.Bool(true),
+		}
+		token, ok := r.nextTokens[logStream]
+		if ok {
+			input.NextToken = aws.String(token)
+		} else {
+			input.StartTime = aws.Int64(r.StartTimestamp.UnixNano() / 1000 / 1000)
+		}
+
+		log.Printf("[DEBUG] Getting log events from %s stream (token: %s)", logStream, token)
+		var resp *cloudwatchlogs.GetLogEventsOutput
+		err := r.retry(func() error {
+			var err error
+			resp, err = r.CloudWatchLogs.GetLogEvents(input)
+			return err
+		})
+		if err != nil {
+			return events, err
+		}
+
+		for _, e := range resp.Events {
+			events = append(events, &Event{
+				LogStream: logStream,
+				Timestamp: time.Unix(0, (*e.Timestamp)*1000*1000),
+				Message:   *e.Message,
+			})
+		}
+
+		next := aws.StringValue(resp.NextForwardToken)
+		if next != "" {
+			r.nextTokens[logStream] = next
+		}
+		// The same token is returned at the end of the stream
+		if len(resp.Events) == 0 || next == "" || next == token {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// retry calls f, retrying throttled requests with exponential backoff.
+// GetLogEvents is called for every stream on every poll, which easily exceeds its rate limit.
+func (r *CloudWatchLogsPollingReader) retry(f func() error) error {
+	sleep := r.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	backoff := cwlogsRetryBackoff
+	for i := 0; ; i++ {
+		err := f()
+		if !isAWSErrorCode(err, "ThrottlingException") &&
+			!isAWSErrorCode(err, cloudwatchlogs.ErrCodeLimitExceededException) &&
+			!isAWSErrorCode(err, cloudwatchlogs.ErrCodeServiceUnavailableException) {
+			return err
+		}
+		if i >= cwlogsMaxRetries {
+			return err
+		}
+
+		log.Printf("[DEBUG] Retrying CloudWatch Logs request in %s: %s", backoff, err)
+		sleep(backoff)
+		backoff *= 2
+	}
+}
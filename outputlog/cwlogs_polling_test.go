@@ -0,0 +1,152 @@
+package outputlog
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/ryotarai/paramedic/awsclient"
+)
+
+// pollingCloudWatchLogs returns events of each stream one by one, using an index as a token
+type pollingCloudWatchLogs struct {
+	awsclient.CloudWatchLogs
+
+	events   map[string][]*cloudwatchlogs.OutputLogEvent
+	throttle int    // the number of following GetLogEvents to be throttled
+	fail     string // log stream GetLogEvents fails on
+}
+
+func (c *pollingCloudWatchLogs) DescribeLogStreamsPages(input *cloudwatchlogs.DescribeLogStreamsInput, fn func(*cloudwatchlogs.DescribeLogStreamsOutput, bool) bool) error {
+	resp := &cloudwatchlogs.DescribeLogStreamsOutput{}
+	for name := range c.events {
+		resp.LogStreams = append(resp.LogStreams, &cloudwatchlogs.LogStream{LogStreamName: aws.String(name)})
+	}
+	fn(resp, true)
+	return nil
+}
+
+func (c *pollingCloudWatchLogs) GetLogEvents(input *cloudwatchlogs.GetLogEventsInput) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	if c.throttle > 0 {
+		c.throttle--
+		return nil, awserr.New("ThrottlingException", "Rate exceeded", nil)
+	}
+	if *input.LogStreamName == c.fail {
+		return nil, awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+
+	events := c.events[*input.LogStreamName]
+	i := 0
+	if input.NextToken != nil {
+		fmt.Sscanf(*input.NextToken, "%d", &i)
+	} else {
+		for i < len(events) && *events[i].Timestamp < *input.StartTime {
+			i++
+		}
+	}
+
+	resp := &cloudwatchlogs.GetLogEventsOutput{}
+	if i < len(events) {
+		resp.Events = events[i : i+1]
+		i++
+	}
+	resp.NextForwardToken = aws.String(fmt.Sprintf("%d", i))
+	return resp, nil
+}
+
+func (c *pollingCloudWatchLogs) GetLogEventsPages(input *cloudwatchlogs.GetLogEventsInput, fn func(*cloudwatchlogs.GetLogEventsOutput, bool) bool) error {
+	return fmt.Errorf("not implemented")
+}
+
+func logEvent(msec int64, message string) *cloudwatchlogs.OutputLogEvent {
+	return &cloudwatchlogs.OutputLogEvent{Timestamp: aws.Int64(msec), Message: aws.String(message)}
+}
+
+func TestCloudWatchLogsPollingReader(t *testing.T) {
+	cwlogs := &pollingCloudWatchLogs{
+		events: map[string][]*cloudwatchlogs.OutputLogEvent{
+			"pcmd/i-a": {logEvent(500, "old"), logEvent(1000, "a1"), logEvent(3000, "a2")},
+			"pcmd/i-b": {logEvent(2000, "b1")},
+		},
+	}
+	r := &CloudWatchLogsPollingReader{
+		CloudWatchLogs:  cwlogs,
+		LogGroup:        "paramedic",
+		LogStreamPrefix: "pcmd/",
+		StartTimestamp:  time.Unix(1, 0),
+	}
+
+	events, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, e := range events {
+		got = append(got, e.Message)
+	}
+	if fmt.Sprint(got) != "[a1 b1 a2]" {
+		t.Errorf("got %v, want [a1 b1 a2]", got)
+	}
+
+	events, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("got %d events, want 0", len(events))
+	}
+
+	cwlogs.events["pcmd/i-b"] = append(cwlogs.events["pcmd/i-b"], logEvent(4000, "b2"))
+	events, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Message != "b2" || events[0].LogStream != "pcmd/i-b" {
+		t.Errorf("got %+v, want only b2", events)
+	}
+}
+
+func TestCloudWatchLogsPollingReaderErrors(t *testing.T) {
+	cwlogs := &pollingCloudWatchLogs{
+		events: map[string][]*cloudwatchlogs.OutputLogEvent{
+			"pcmd/i-a": {logEvent(1000, "a1"), logEvent(2000, "a2")},
+			"pcmd/i-b": {logEvent(1500, "b1")},
+		},
+	}
+	sleeps := []time.Duration{}
+	r := &CloudWatchLogsPollingReader{
+		CloudWatchLogs:  cwlogs,
+		LogGroup:        "paramedic",
+		LogStreamPrefix: "pcmd/",
+		StartTimestamp:  time.Unix(1, 0),
+		sleep:           func(d time.Duration) { sleeps = append(sleeps, d) },
+	}
+
+	// Throttled requests are retried
+	cwlogs.throttle = 2
+	// Events of the other stream are returned with the error
+	cwlogs.fail = "pcmd/i-b"
+	events, err := r.Read()
+	if err == nil {
+		t.Error("got no error")
+	}
+	if got := messages(events); got != "a1,a2" {
+		t.Errorf("got %q, want %q", got, "a1,a2")
+	}
+	if fmt.Sprint(sleeps) != "[200ms 400ms]" {
+		t.Errorf("got sleeps %v, want [200ms 400ms]", sleeps)
+	}
+
+	// The failed stream is read on the next call, and the others are not read again
+	cwlogs.fail = ""
+	events, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messages(events); got != "b1" {
+		t.Errorf("got %q, want %q", got, "b1")
+	}
+}
@@ -1,6 +1,7 @@
 package outputlog
 
 import (
+	"encoding/json"
 	"sort"
 	"strings"
 	"time"
@@ -17,6 +18,38 @@ func (e *Event) InstanceID() string {
 	return parts[len(parts)-1]
 }
 
+// EventOutputType is the type of events in JSON and YAML, which tells them from other values printed to the same stream
+const EventOutputType = "event"
+
+// eventOutput is the schema of an event in JSON and YAML
+type eventOutput struct {
+	Type       string    `json:"type" yaml:"type"`
+	Timestamp  time.Time `json:"timestamp" yaml:"timestamp"`
+	InstanceID string    `json:"instanceId" yaml:"instanceId"`
+	LogStream  string    `json:"logStream" yaml:"logStream"`
+	Message    string    `json:"message" yaml:"message"`
+}
+
+func (e *Event) output() *eventOutput {
+	return &eventOutput{
+		Type:       EventOutputType,
+		Timestamp:  e.Timestamp,
+		InstanceID: e.InstanceID(),
+		LogStream:  e.LogStream,
+		Message:    e.Message,
+	}
+}
+
+// MarshalJSON implements json.Marshaler
+func (e *Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.output())
+}
+
+// MarshalYAML implements yaml.Marshaler
+func (e *Event) MarshalYAML() (interface{}, error) {
+	return e.output(), nil
+}
+
 func SortEventsByTimestamp(events []*Event) {
 	sort.SliceStable(events, func(i, j int) bool {
 		return events[i].Timestamp.Before(events[j].Timestamp)
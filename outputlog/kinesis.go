@@ -7,23 +7,43 @@ import (
 	"io/ioutil"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/kinesis"
 
 	"github.com/ryotarai/paramedic/awsclient"
 )
 
-const kinesisStreamName = "paramedic-logs" // TODO: configurable
+// DefaultKinesisStreamName is the name of the stream CloudWatch Logs are subscribed to by default
+const DefaultKinesisStreamName = "paramedic-logs"
+
+const (
+	kinesisMaxRetries   = 5
+	kinesisRetryBackoff = 200 * time.Millisecond
+)
 
 type KinesisReader struct {
 	Kinesis         awsclient.Kinesis
+	StreamName      string
 	StartTimestamp  time.Time
 	LogGroup        string
 	LogStreamPrefix string
 
-	shardIterator map[string]string // map[shard ID]iterator
+	shards        map[string]*kinesisShard // map[shard ID]shard
+	refreshShards bool
+	sleep         func(time.Duration)
+}
+
+// kinesisShard is the reading state of a shard
+type kinesisShard struct {
+	id                 string
+	iteratorType       string
+	iterator           string
+	lastSequenceNumber string
+	closed             bool
 }
 
 type kinesisRecord struct {
@@ -41,34 +61,96 @@ type kinesisLogEvent struct {
 }
 
 func (r *KinesisReader) Read() ([]*Event, error) {
-	if r.shardIterator == nil {
-		err := r.initShardIterator()
-		if err != nil {
+	if r.shards == nil {
+		if err := r.initShards(); err != nil {
+			return nil, err
+		}
+	} else if r.refreshShards {
+		// Some shards are closed by resharding, so start reading their children
+		if err := r.addChildShards(); err != nil {
 			return nil, err
 		}
 	}
 
-	events := []*Event{}
-	for shardID, iter := range r.shardIterator {
-		log.Printf("[DEBUG] Getting records from Kinesis Streams (shard ID: %s, iterator: %s)", shardID, iter)
-		resp, err := r.Kinesis.GetRecords(&kinesis.GetRecordsInput{
-			ShardIterator: aws.String(iter),
-		})
-		if err != nil {
-			return nil, err
+	type result struct {
+		events []*Event
+		err    error
+	}
+
+	shards := []*kinesisShard{}
+	for _, s := range r.shards {
+		if !s.closed {
+			shards = append(shards, s)
 		}
-		r.shardIterator[shardID] = *resp.NextShardIterator
+	}
 
-		ev, err := r.recordsToEvents(resp.Records)
-		if err != nil {
-			return nil, err
+	results := make([]result, len(shards))
+	var wg sync.WaitGroup
+	for i, s := range shards {
+		wg.Add(1)
+		go func(i int, s *kinesisShard) {
+			defer wg.Done()
+			events, err := r.readShard(s)
+			results[i] = result{events: events, err: err}
+		}(i, s)
+	}
+	wg.Wait()
+
+	// Events of the other shards are returned with an error, because their iterators have advanced past them.
+	// The iterator of a failed shard is kept, so that it is read again on the next call.
+	events := []*Event{}
+	var readErr error
+	for i, res := range results {
+		if res.err != nil {
+			log.Printf("[DEBUG] Failed to get records from shard %s: %s", shards[i].id, res.err)
+			if readErr == nil {
+				readErr = res.err
+			}
+			continue
+		}
+		if shards[i].closed {
+			r.refreshShards = true
 		}
-		events = append(events, ev...)
+		events = append(events, res.events...)
 	}
 
 	SortEventsByTimestamp(events)
 
-	return events, nil
+	return events, readErr
+}
+
+func (r *KinesisReader) readShard(s *kinesisShard) ([]*Event, error) {
+	log.Printf("[DEBUG] Getting records from Kinesis Streams (shard ID: %s, iterator: %s)", s.id, s.iterator)
+
+	var resp *kinesis.GetRecordsOutput
+	err := r.retry(func() error {
+		var err error
+		resp, err = r.Kinesis.GetRecords(&kinesis.GetRecordsInput{
+			ShardIterator: aws.String(s.iterator),
+		})
+		if isAWSErrorCode(err, kinesis.ErrCodeExpiredIteratorException) {
+			log.Printf("[DEBUG] Shard iterator of %s is expired", s.id)
+			if err := r.renewShardIterator(s); err != nil {
+				return err
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Records) > 0 {
+		s.lastSequenceNumber = *resp.Records[len(resp.Records)-1].SequenceNumber
+	}
+	if resp.NextShardIterator == nil {
+		log.Printf("[DEBUG] Shard %s is closed", s.id)
+		s.closed = true
+	} else {
+		s.iterator = *resp.NextShardIterator
+	}
+
+	return r.recordsToEvents(resp.Records)
 }
 
 func (r *KinesisReader) recordsToEvents(records []*kinesis.Record) ([]*Event, error) {
@@ -107,29 +189,155 @@ func (r *KinesisReader) recordsToEvents(records []*kinesis.Record) ([]*Event, er
 	return events, nil
 }
 
-func (r *KinesisReader) initShardIterator() error {
-	log.Printf("[DEBUG] Getting initial shart iterator")
-	r.shardIterator = map[string]string{}
+func (r *KinesisReader) initShards() error {
+	log.Printf("[DEBUG] Getting initial shard iterators")
 
-	resp, err := r.Kinesis.DescribeStream(&kinesis.DescribeStreamInput{
-		StreamName: aws.String(kinesisStreamName),
-	})
+	shards, err := r.listShards()
 	if err != nil {
 		return err
 	}
 
-	for _, s := range resp.StreamDescription.Shards {
-		id := *s.ShardId
-		resp, err := r.Kinesis.GetShardIterator(&kinesis.GetShardIteratorInput{
-			StreamName:        aws.String(kinesisStreamName),
-			ShardId:           aws.String(id),
-			ShardIteratorType: aws.String("AT_TIMESTAMP"),
-			Timestamp:         aws.Time(r.StartTimestamp),
+	r.shards = map[string]*kinesisShard{}
+	for _, s := range shards {
+		if err := r.startShard(s, "AT_TIMESTAMP"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addChildShards starts reading shards whose parents are all closed.
+// Children are read from the beginning because they were created after the command started.
+func (r *KinesisReader) addChildShards() error {
+	shards, err := r.listShards()
+	if err != nil {
+		return err
+	}
+
+	r.refreshShards = false
+	for _, s := range shards {
+		if _, ok := r.shards[*s.ShardId]; ok {
+			continue
+		}
+
+		ready := true
+		for _, p := range shardParentIDs(s) {
+			if parent, ok := r.shards[p]; ok && !parent.closed {
+				ready = false
+			}
+		}
+		if !ready {
+			continue
+		}
+
+		if err := r.startShard(s, "TRIM_HORIZON"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *KinesisReader) startShard(shard *kinesis.Shard, iteratorType string) error {
+	s := &kinesisShard{
+		id:           *shard.ShardId,
+		iteratorType: iteratorType,
+	}
+	if err := r.renewShardIterator(s); err != nil {
+		return err
+	}
+	r.shards[s.id] = s
+	return nil
+}
+
+// renewShardIterator gets an iterator which starts after the last record read from the shard
+func (r *KinesisReader) renewShardIterator(s *kinesisShard) error {
+	input := &kinesis.GetShardIteratorInput{
+		StreamName:        aws.String(r.StreamName),
+		ShardId:           aws.String(s.id),
+		ShardIteratorType: aws.String(s.iteratorType),
+	}
+	if s.lastSequenceNumber != "" {
+		input.ShardIteratorType = aws.String("AFTER_SEQUENCE_NUMBER")
+		input.StartingSequenceNumber = aws.String(s.lastSequenceNumber)
+	} else if s.iteratorType == "AT_TIMESTAMP" {
+		input.Timestamp = aws.Time(r.StartTimestamp)
+	}
+
+	return r.retry(func() error {
+		resp, err := r.Kinesis.GetShardIterator(input)
+		if err != nil {
+			return err
+		}
+		s.iterator = *resp.ShardIterator
+		return nil
+	})
+}
+
+func (r *KinesisReader) listShards() ([]*kinesis.Shard, error) {
+	shards := []*kinesis.Shard{}
+
+	var exclusiveStartShardID *string
+	for {
+		var resp *kinesis.DescribeStreamOutput
+		err := r.retry(func() error {
+			var err error
+			resp, err = r.Kinesis.DescribeStream(&kinesis.DescribeStreamInput{
+				StreamName:            aws.String(r.StreamName),
+				ExclusiveStartShardId: exclusiveStartShardID,
+			})
+			return err
 		})
 		if err != nil {
+			return nil, err
+		}
+
+		shards = append(shards, resp.StreamDescription.Shards...)
+		if !aws.BoolValue(resp.StreamDescription.HasMoreShards) || len(resp.StreamDescription.Shards) == 0 {
+			break
+		}
+		exclusiveStartShardID = shards[len(shards)-1].ShardId
+	}
+
+	return shards, nil
+}
+
+// retry calls f, retrying throttled requests and expired iterators with exponential backoff
+func (r *KinesisReader) retry(f func() error) error {
+	sleep := r.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	backoff := kinesisRetryBackoff
+	for i := 0; ; i++ {
+		err := f()
+		if !isAWSErrorCode(err, kinesis.ErrCodeProvisionedThroughputExceededException) &&
+			!isAWSErrorCode(err, kinesis.ErrCodeLimitExceededException) &&
+			!isAWSErrorCode(err, kinesis.ErrCodeExpiredIteratorException) {
+			return err
+		}
+		if i >= kinesisMaxRetries {
 			return err
 		}
-		r.shardIterator[id] = *resp.ShardIterator
+
+		log.Printf("[DEBUG] Retrying Kinesis request in %s: %s", backoff, err)
+		sleep(backoff)
+		backoff *= 2
 	}
-	return nil
+}
+
+func shardParentIDs(s *kinesis.Shard) []string {
+	ids := []string{}
+	if s.ParentShardId != nil {
+		ids = append(ids, *s.ParentShardId)
+	}
+	if s.AdjacentParentShardId != nil {
+		ids = append(ids, *s.AdjacentParentShardId)
+	}
+	return ids
+}
+
+func isAWSErrorCode(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
 }
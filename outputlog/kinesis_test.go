@@ -0,0 +1,322 @@
+package outputlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/ryotarai/paramedic/awsclient"
+)
+
+// fakeKinesis is an in-memory Kinesis stream. Shard iterators are "<shard ID>/<position>".
+type fakeKinesis struct {
+	awsclient.Kinesis
+
+	mu       sync.Mutex
+	shards   []*fakeShard
+	pageSize int
+	throttle int    // the number of following requests to be throttled
+	expire   int    // the number of following GetRecords to fail with an expired iterator
+	fail     string // shard GetRecords fails on
+}
+
+type fakeShard struct {
+	id       string
+	parentID string
+	records  []*kinesis.Record
+	closed   bool
+}
+
+func (k *fakeKinesis) shard(id string) *fakeShard {
+	for _, s := range k.shards {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (k *fakeKinesis) addShard(id, parentID string) *fakeShard {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	s := &fakeShard{id: id, parentID: parentID}
+	k.shards = append(k.shards, s)
+	return s
+}
+
+func (k *fakeKinesis) put(shardID string, t time.Time, logGroup, logStream, message string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	b, _ := json.Marshal(kinesisRecord{
+		MessageType: "DATA_MESSAGE",
+		LogGroup:    logGroup,
+		LogStream:   logStream,
+		LogEvents:   []kinesisLogEvent{{Timestamp: t.UnixNano() / 1000 / 1000, Message: message}},
+	})
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	w.Write(b)
+	w.Close()
+
+	s := k.shard(shardID)
+	s.records = append(s.records, &kinesis.Record{
+		Data:                        buf.Bytes(),
+		SequenceNumber:              aws.String(fmt.Sprintf("%s-%d", shardID, len(s.records))),
+		ApproximateArrivalTimestamp: aws.Time(t),
+	})
+}
+
+func (k *fakeKinesis) closeShard(id string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.shard(id).closed = true
+}
+
+func (k *fakeKinesis) throttled() error {
+	if k.throttle > 0 {
+		k.throttle--
+		return awserr.New(kinesis.ErrCodeProvisionedThroughputExceededException, "throttled", nil)
+	}
+	return nil
+}
+
+func (k *fakeKinesis) GetRecords(input *kinesis.GetRecordsInput) (*kinesis.GetRecordsOutput, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.throttled(); err != nil {
+		return nil, err
+	}
+	if k.expire > 0 {
+		k.expire--
+		return nil, awserr.New(kinesis.ErrCodeExpiredIteratorException, "expired", nil)
+	}
+
+	parts := strings.SplitN(*input.ShardIterator, "/", 2)
+	if parts[0] == k.fail {
+		return nil, awserr.New(kinesis.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	s := k.shard(parts[0])
+	pos, _ := strconv.Atoi(parts[1])
+
+	resp := &kinesis.GetRecordsOutput{Records: s.records[pos:]}
+	if !s.closed {
+		resp.NextShardIterator = aws.String(fmt.Sprintf("%s/%d", s.id, len(s.records)))
+	}
+	return resp, nil
+}
+
+func (k *fakeKinesis) GetShardIterator(input *kinesis.GetShardIteratorInput) (*kinesis.GetShardIteratorOutput, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.throttled(); err != nil {
+		return nil, err
+	}
+
+	s := k.shard(*input.ShardId)
+	pos := 0
+	switch *input.ShardIteratorType {
+	case "AT_TIMESTAMP":
+		for pos < len(s.records) && s.records[pos].ApproximateArrivalTimestamp.Before(*input.Timestamp) {
+			pos++
+		}
+	case "AFTER_SEQUENCE_NUMBER":
+		for pos < len(s.records) && *s.records[pos].SequenceNumber != *input.StartingSequenceNumber {
+			pos++
+		}
+		pos++
+	case "TRIM_HORIZON":
+	default:
+		return nil, fmt.Errorf("unsupported iterator type %s", *input.ShardIteratorType)
+	}
+
+	return &kinesis.GetShardIteratorOutput{
+		ShardIterator: aws.String(fmt.Sprintf("%s/%d", s.id, pos)),
+	}, nil
+}
+
+func (k *fakeKinesis) DescribeStream(input *kinesis.DescribeStreamInput) (*kinesis.DescribeStreamOutput, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.throttled(); err != nil {
+		return nil, err
+	}
+
+	start := 0
+	if input.ExclusiveStartShardId != nil {
+		for start < len(k.shards) && k.shards[start].id != *input.ExclusiveStartShardId {
+			start++
+		}
+		start++
+	}
+	end := start + k.pageSize
+	if end > len(k.shards) {
+		end = len(k.shards)
+	}
+
+	desc := &kinesis.StreamDescription{HasMoreShards: aws.Bool(end < len(k.shards))}
+	for _, s := range k.shards[start:end] {
+		shard := &kinesis.Shard{ShardId: aws.String(s.id)}
+		if s.parentID != "" {
+			shard.ParentShardId = aws.String(s.parentID)
+		}
+		desc.Shards = append(desc.Shards, shard)
+	}
+	return &kinesis.DescribeStreamOutput{StreamDescription: desc}, nil
+}
+
+func messages(events []*Event) string {
+	m := []string{}
+	for _, e := range events {
+		m = append(m, e.Message)
+	}
+	return strings.Join(m, ",")
+}
+
+func TestKinesisReaderResharding(t *testing.T) {
+	start := time.Unix(1000, 0)
+	k := &fakeKinesis{pageSize: 1}
+	k.addShard("shard-0", "")
+	k.addShard("shard-1", "")
+	k.put("shard-0", start.Add(-time.Second), "paramedic", "pcmd/i-a", "before start")
+	k.put("shard-0", start.Add(1*time.Second), "paramedic", "pcmd/i-a", "a")
+	k.put("shard-1", start.Add(2*time.Second), "paramedic", "other/i-a", "other command")
+	k.put("shard-1", start.Add(3*time.Second), "other", "pcmd/i-a", "other log group")
+
+	r := &KinesisReader{
+		Kinesis:         k,
+		StreamName:      "paramedic-logs",
+		StartTimestamp:  start,
+		LogGroup:        "paramedic",
+		LogStreamPrefix: "pcmd/",
+	}
+
+	read := func() string {
+		events, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return messages(events)
+	}
+
+	if got := read(); got != "a" {
+		t.Errorf("got %q, want %q", got, "a")
+	}
+
+	// Split shard-0 into two
+	k.put("shard-0", start.Add(4*time.Second), "paramedic", "pcmd/i-a", "b")
+	k.closeShard("shard-0")
+	k.addShard("shard-2", "shard-0")
+	k.addShard("shard-3", "shard-0")
+	k.put("shard-2", start.Add(5*time.Second), "paramedic", "pcmd/i-b", "c")
+	k.put("shard-3", start.Add(6*time.Second), "paramedic", "pcmd/i-c", "d")
+
+	if got := read(); got != "b" {
+		t.Errorf("got %q, want %q", got, "b")
+	}
+	if got := read(); got != "c,d" {
+		t.Errorf("got %q, want %q", got, "c,d")
+	}
+	if got := read(); got != "" {
+		t.Errorf("got %q, want no events", got)
+	}
+	if len(r.shards) != 4 {
+		t.Errorf("got %d shards, want 4", len(r.shards))
+	}
+}
+
+func TestKinesisReaderRetry(t *testing.T) {
+	start := time.Unix(1000, 0)
+	k := &fakeKinesis{pageSize: 10}
+	k.addShard("shard-0", "")
+	k.put("shard-0", start, "paramedic", "pcmd/i-a", "a")
+
+	sleeps := []time.Duration{}
+	r := &KinesisReader{
+		Kinesis:         k,
+		StreamName:      "paramedic-logs",
+		StartTimestamp:  start,
+		LogGroup:        "paramedic",
+		LogStreamPrefix: "pcmd/",
+		sleep:           func(d time.Duration) { sleeps = append(sleeps, d) },
+	}
+
+	// DescribeStream is throttled twice
+	k.throttle = 2
+	events, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messages(events); got != "a" {
+		t.Errorf("got %q, want %q", got, "a")
+	}
+	if fmt.Sprint(sleeps) != "[200ms 400ms]" {
+		t.Errorf("got sleeps %v, want [200ms 400ms]", sleeps)
+	}
+
+	// The iterator is renewed after the last record read
+	k.put("shard-0", start.Add(time.Second), "paramedic", "pcmd/i-a", "b")
+	k.expire = 1
+	events, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messages(events); got != "b" {
+		t.Errorf("got %q, want %q", got, "b")
+	}
+
+	// Gives up after retries
+	k.throttle = kinesisMaxRetries + 1
+	if _, err := r.Read(); err == nil {
+		t.Error("got no error, want throttling error")
+	}
+}
+
+func TestKinesisReaderShardError(t *testing.T) {
+	start := time.Unix(1000, 0)
+	k := &fakeKinesis{pageSize: 10}
+	k.addShard("shard-0", "")
+	k.addShard("shard-1", "")
+	k.put("shard-0", start, "paramedic", "pcmd/i-a", "a")
+	k.put("shard-1", start.Add(time.Second), "paramedic", "pcmd/i-b", "b")
+
+	r := &KinesisReader{
+		Kinesis:         k,
+		StreamName:      "paramedic-logs",
+		StartTimestamp:  start,
+		LogGroup:        "paramedic",
+		LogStreamPrefix: "pcmd/",
+	}
+
+	// Events of the other shard are returned with the error
+	k.fail = "shard-1"
+	events, err := r.Read()
+	if err == nil {
+		t.Error("got no error")
+	}
+	if got := messages(events); got != "a" {
+		t.Errorf("got %q, want %q", got, "a")
+	}
+
+	// The failed shard is read again from where it was
+	k.fail = ""
+	events, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messages(events); got != "b" {
+		t.Errorf("got %q, want %q", got, "b")
+	}
+}
@@ -1,12 +1,25 @@
 package outputlog
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+
+	"github.com/fatih/color"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Formats of Printer
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
 )
 
 type Printer struct {
 	Writer io.Writer
+	// Format is one of FormatText (default), FormatJSON (JSON Lines) and FormatYAML (YAML documents)
+	Format string
 
 	colorer *Colorer
 }
@@ -14,12 +27,37 @@ type Printer struct {
 func NewPrinter(writer io.Writer) *Printer {
 	return &Printer{
 		Writer:  writer,
+		Format:  FormatText,
 		colorer: NewColorer(),
 	}
 }
 
 func (p *Printer) Print(events []*Event) {
-	resetColor := "\x1b[0m"
+	switch p.Format {
+	case FormatJSON:
+		enc := json.NewEncoder(p.Writer)
+		for _, e := range events {
+			enc.Encode(e)
+		}
+	case FormatYAML:
+		for _, e := range events {
+			b, err := yaml.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(p.Writer, "---\n%s", b)
+		}
+	default:
+		p.printText(events)
+	}
+}
+
+func (p *Printer) printText(events []*Event) {
+	// The reset resets colors the previous message may leave, only when colors are enabled
+	resetColor := ""
+	if !color.NoColor {
+		resetColor = "\x1b[0m"
+	}
 
 	for _, e := range events {
 		instance := e.InstanceID()
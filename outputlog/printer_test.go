@@ -33,3 +33,32 @@ func TestPrinter(t *testing.T) {
 		}
 	}
 }
+
+func TestPrinterJSON(t *testing.T) {
+	writer := bytes.NewBufferString("")
+
+	events := []*Event{
+		{Message: "foo", Timestamp: time.Unix(0, 0).UTC(), LogStream: "foo/i-aaa"},
+		{Message: "bar", Timestamp: time.Unix(1, 0).UTC(), LogStream: "foo/i-bbb"},
+	}
+
+	p := NewPrinter(writer)
+	p.Format = FormatJSON
+	p.Print(events)
+
+	expects := []string{
+		`{"type":"event","timestamp":"1970-01-01T00:00:00Z","instanceId":"i-aaa","logStream":"foo/i-aaa","message":"foo"}` + "\n",
+		`{"type":"event","timestamp":"1970-01-01T00:00:01Z","instanceId":"i-bbb","logStream":"foo/i-bbb","message":"bar"}` + "\n",
+	}
+
+	for _, e := range expects {
+		l, err := writer.ReadString(byte('\n'))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if l != e {
+			t.Errorf("got %v, want %v", l, e)
+		}
+	}
+}
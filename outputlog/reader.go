@@ -1,21 +1,34 @@
 package outputlog
 
 import (
+	"log"
 	"time"
 )
 
+// followMaxErrors is the number of consecutive failed reads after which Follow gives up
+const followMaxErrors = 5
+
 type Reader interface {
+	// Read returns events written since the last read. When it fails, it returns
+	// the events read before the error with the error, and reads the rest on the next call.
 	Read() ([]*Event, error)
 }
 
 func Follow(r Reader, p *Printer, stopCh chan struct{}) error {
 	exit := false
+	failures := 0
 	for {
 		events, err := r.Read()
+		p.Print(events)
 		if err != nil {
-			return err
+			failures++
+			if failures >= followMaxErrors {
+				return err
+			}
+			log.Printf("[WARN] Failed to read output logs, which will be retried: %s", err)
+		} else {
+			failures = 0
 		}
-		p.Print(events)
 
 		if exit {
 			break
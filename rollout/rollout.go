@@ -0,0 +1,125 @@
+package rollout
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Stage is the cumulative number of instances a command has run on after a wave
+type Stage struct {
+	Count   int
+	Percent int
+}
+
+func (s Stage) String() string {
+	if s.Percent > 0 {
+		return fmt.Sprintf("%d%%", s.Percent)
+	}
+	return strconv.Itoa(s.Count)
+}
+
+func (s Stage) resolve(total int) int {
+	if s.Percent > 0 {
+		// round up so that a small percentage runs at least one instance
+		n := (total*s.Percent + 99) / 100
+		return n
+	}
+	if s.Count > total {
+		return total
+	}
+	return s.Count
+}
+
+func parseCountOrPercent(s string) (count, percent int, err error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		percent, err = strconv.Atoi(strings.TrimSuffix(s, "%"))
+		if err != nil || percent < 0 || percent > 100 {
+			return 0, 0, fmt.Errorf("'%s' is not a valid percentage", s)
+		}
+		return 0, percent, nil
+	}
+
+	count, err = strconv.Atoi(s)
+	if err != nil || count < 0 {
+		return 0, 0, fmt.Errorf("'%s' is neither a number nor a percentage", s)
+	}
+	return count, 0, nil
+}
+
+// ParseStages parses stages like "1,10%,50%,100%"
+func ParseStages(s string) ([]Stage, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	stages := []Stage{}
+	for _, part := range strings.Split(s, ",") {
+		count, percent, err := parseCountOrPercent(part)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 && percent == 0 {
+			return nil, errors.New("stage must be greater than 0")
+		}
+		stages = append(stages, Stage{Count: count, Percent: percent})
+	}
+
+	if last := stages[len(stages)-1]; last.Percent != 100 {
+		return nil, errors.New("the last stage must be 100%")
+	}
+
+	return stages, nil
+}
+
+// SplitWaves splits items into waves according to stages.
+// Stages which add no instance are skipped.
+func SplitWaves(items []string, stages []Stage) [][]string {
+	waves := [][]string{}
+	done := 0
+	for _, s := range stages {
+		n := s.resolve(len(items))
+		if n <= done {
+			continue
+		}
+		waves = append(waves, items[done:n])
+		done = n
+	}
+	return waves
+}
+
+// Threshold is the number of failed instances a wave can tolerate
+type Threshold struct {
+	Count   int
+	Percent int
+}
+
+func (t Threshold) String() string {
+	if t.Percent > 0 {
+		return fmt.Sprintf("%d%%", t.Percent)
+	}
+	return strconv.Itoa(t.Count)
+}
+
+// ParseThreshold parses a threshold like "0", "2" or "10%"
+func ParseThreshold(s string) (Threshold, error) {
+	if strings.TrimSpace(s) == "" {
+		return Threshold{}, nil
+	}
+	count, percent, err := parseCountOrPercent(s)
+	if err != nil {
+		return Threshold{}, err
+	}
+	return Threshold{Count: count, Percent: percent}, nil
+}
+
+// Exceeded returns true if failed instances of a wave exceed the threshold
+func (t Threshold) Exceeded(failed, total int) bool {
+	allowed := t.Count
+	if t.Percent > 0 {
+		allowed = total * t.Percent / 100
+	}
+	return failed > allowed
+}
@@ -0,0 +1,64 @@
+package rollout
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStages(t *testing.T) {
+	stages, err := ParseStages("1, 10%,50%,100%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Stage{{Count: 1}, {Percent: 10}, {Percent: 50}, {Percent: 100}}
+	if !reflect.DeepEqual(stages, want) {
+		t.Errorf("got %v, want %v", stages, want)
+	}
+
+	for _, s := range []string{"1,50%", "0,100%", "a,100%", "101%"} {
+		if _, err := ParseStages(s); err == nil {
+			t.Errorf("ParseStages(%s) returns no error", s)
+		}
+	}
+}
+
+func TestSplitWaves(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}
+	stages := []Stage{{Count: 1}, {Percent: 10}, {Percent: 50}, {Percent: 100}}
+
+	got := SplitWaves(items, stages)
+	want := [][]string{
+		{"a"},
+		{"b"},
+		{"c", "d", "e", "f"},
+		{"g", "h", "i", "j", "k"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestThresholdExceeded(t *testing.T) {
+	examples := []struct {
+		threshold     string
+		failed, total int
+		want          bool
+	}{
+		{threshold: "0", failed: 0, total: 10, want: false},
+		{threshold: "0", failed: 1, total: 10, want: true},
+		{threshold: "2", failed: 2, total: 10, want: false},
+		{threshold: "10%", failed: 1, total: 10, want: false},
+		{threshold: "10%", failed: 2, total: 10, want: true},
+		{threshold: "10%", failed: 1, total: 5, want: true},
+	}
+
+	for _, e := range examples {
+		th, err := ParseThreshold(e.threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := th.Exceeded(e.failed, e.total); got != e.want {
+			t.Errorf("Threshold(%s).Exceeded(%d, %d) = %v, want %v", e.threshold, e.failed, e.total, got, e.want)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package setup
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ryotarai/paramedic/awsclient"
+	"github.com/ryotarai/paramedic/store"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionNone   = "none"
+)
+
+const (
+	subscriptionFilterName = "paramedic"
+	signalsLifecycleRuleID = "paramedic-signals"
+	scriptsLifecycleRuleID = "paramedic-scripts"
+)
+
+// Config is the set of AWS resources paramedic uses
+type Config struct {
+	// The table is created in on-demand billing mode if capacities are zero
+	TableReadCapacity  int64
+	TableWriteCapacity int64
+
+	OutputLogGroup   string
+	LogRetentionDays int64
+	// KinesisStream is empty if output logs are not delivered to Kinesis Streams
+	KinesisStream        string
+	KinesisShardCount    int64
+	SubscriptionRoleName string
+	ScriptS3Bucket       string
+	SignalS3Bucket       string
+	SignalS3KeyPrefix    string
+	SignalExpirationDays int64
+}
+
+// Setup creates AWS resources paramedic uses if they don't exist
+type Setup struct {
+	Store          *store.Store
+	CloudWatchLogs awsclient.CloudWatchLogs
+	Kinesis        awsclient.Kinesis
+	IAM            awsclient.IAM
+	S3             awsclient.S3
+	Region         string
+	Config         *Config
+
+	sleep func(time.Duration)
+}
+
+// Step is a step to set up a resource
+type Step struct {
+	Resource string `json:"resource" yaml:"resource"`
+	Name     string `json:"name" yaml:"name"`
+	Action   string `json:"action" yaml:"action"`
+
+	apply func() error
+}
+
+// Plan checks which resources exist and returns steps to set up all of them
+func (s *Setup) Plan() ([]*Step, error) {
+	steps := []*Step{}
+	add := func(resource, name string, exists bool, apply func() error) {
+		action := ActionNone
+		if !exists {
+			action = ActionCreate
+		}
+		steps = append(steps, &Step{Resource: resource, Name: name, Action: action, apply: apply})
+	}
+
+	exists, err := s.Store.TableExists()
+	if err != nil {
+		return nil, err
+	}
+	add("DynamoDB table", s.Store.TableName(), exists, func() error {
+		return s.Store.CreateTables(&store.TableOptions{
+			ReadCapacity:  s.Config.TableReadCapacity,
+			WriteCapacity: s.Config.TableWriteCapacity,
+		})
+	})
+
+	exists, err = s.logGroupExists()
+	if err != nil {
+		return nil, err
+	}
+	add("CloudWatch Logs log group", s.Config.OutputLogGroup, exists, s.createLogGroup)
+
+	if s.Config.KinesisStream != "" {
+		exists, err = s.streamExists()
+		if err != nil {
+			return nil, err
+		}
+		add("Kinesis stream", s.Config.KinesisStream, exists, s.createStream)
+
+		exists, err = s.roleExists()
+		if err != nil {
+			return nil, err
+		}
+		add("IAM role", s.Config.SubscriptionRoleName, exists, s.createRole)
+
+		exists, err = s.subscriptionFilterExists()
+		if err != nil {
+			return nil, err
+		}
+		add("CloudWatch Logs subscription filter", fmt.Sprintf("%s (%s)", subscriptionFilterName, s.Config.OutputLogGroup), exists, s.putSubscriptionFilter)
+	}
+
+	for _, b := range s.buckets() {
+		b := b
+
+		exists, err = s.bucketExists(b.name)
+		if err != nil {
+			return nil, err
+		}
+		add("S3 bucket", b.name, exists, func() error { return s.createBucket(b.name) })
+
+		found := false
+		if exists {
+			found, err = s.lifecycleRulesExist(b.name, b.rules)
+			if err != nil {
+				return nil, err
+			}
+		}
+		add("S3 lifecycle rules", b.name, found, func() error { return s.putLifecycleRules(b.name, b.rules) })
+		if exists && !found {
+			steps[len(steps)-1].Action = ActionUpdate
+		}
+	}
+
+	return steps, nil
+}
+
+// Apply runs steps whose action is not none
+func (s *Setup) Apply(steps []*Step) error {
+	for _, st := range steps {
+		if st.Action == ActionNone {
+			continue
+		}
+		log.Printf("[INFO] Creating %s %s", st.Resource, st.Name)
+		if err := st.apply(); err != nil {
+			return fmt.Errorf("failed to create %s %s: %s", st.Resource, st.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Setup) logGroupExists() (bool, error) {
+	found := false
+	err := s.CloudWatchLogs.DescribeLogGroupsPages(&cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: aws.String(s.Config.OutputLogGroup),
+	}, func(resp *cloudwatchlogs.DescribeLogGroupsOutput, last bool) bool {
+		for _, g := range resp.LogGroups {
+			if *g.LogGroupName == s.Config.OutputLogGroup {
+				found = true
+				return false
+			}
+		}
+		return true
+	})
+	return found, err
+}
+
+func (s *Setup) createLogGroup() error {
+	_, err := s.CloudWatchLogs.CreateLogGroup(&cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName: aws.String(s.Config.OutputLogGroup),
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.Config.LogRetentionDays > 0 {
+		_, err = s.CloudWatchLogs.PutRetentionPolicy(&cloudwatchlogs.PutRetentionPolicyInput{
+			LogGroupName:    aws.String(s.Config.OutputLogGroup),
+			RetentionInDays: aws.Int64(s.Config.LogRetentionDays),
+		})
+	}
+	return err
+}
+
+func (s *Setup) describeStream() (*kinesis.StreamDescription, error) {
+	resp, err := s.Kinesis.DescribeStream(&kinesis.DescribeStreamInput{
+		StreamName: aws.String(s.Config.KinesisStream),
+		Limit:      aws.Int64(1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.StreamDescription, nil
+}
+
+func (s *Setup) streamExists() (bool, error) {
+	_, err := s.describeStream()
+	if isAWSErrorCode(err, kinesis.ErrCodeResourceNotFoundException) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Setup) createStream() error {
+	_, err := s.Kinesis.CreateStream(&kinesis.CreateStreamInput{
+		StreamName: aws.String(s.Config.KinesisStream),
+		ShardCount: aws.Int64(s.Config.KinesisShardCount),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Kinesis.WaitUntilStreamExists(&kinesis.DescribeStreamInput{
+		StreamName: aws.String(s.Config.KinesisStream),
+	})
+}
+
+func (s *Setup) roleExists() (bool, error) {
+	_, err := s.IAM.GetRole(&iam.GetRoleInput{
+		RoleName: aws.String(s.Config.SubscriptionRoleName),
+	})
+	if isAWSErrorCode(err, iam.ErrCodeNoSuchEntityException) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// createRole creates a role CloudWatch Logs assumes to put records to the stream
+func (s *Setup) createRole() error {
+	stream, err := s.describeStream()
+	if err != nil {
+		return err
+	}
+
+	assumeRolePolicy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":    "Allow",
+				"Principal": map[string]string{"Service": fmt.Sprintf("logs.%s.amazonaws.com", s.Region)},
+				"Action":    "sts:AssumeRole",
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.IAM.CreateRole(&iam.CreateRoleInput{
+		RoleName:                 aws.String(s.Config.SubscriptionRoleName),
+		AssumeRolePolicyDocument: aws.String(string(assumeRolePolicy)),
+		Description:              aws.String("Allows CloudWatch Logs to deliver paramedic output logs to Kinesis Streams"),
+	})
+	if err != nil {
+		return err
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   "kinesis:PutRecord",
+				"Resource": *stream.StreamARN,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.IAM.PutRolePolicy(&iam.PutRolePolicyInput{
+		RoleName:       aws.String(s.Config.SubscriptionRoleName),
+		PolicyName:     aws.String("kinesis"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	return err
+}
+
+func (s *Setup) subscriptionFilterExists() (bool, error) {
+	resp, err := s.CloudWatchLogs.DescribeSubscriptionFilters(&cloudwatchlogs.DescribeSubscriptionFiltersInput{
+		LogGroupName:     aws.String(s.Config.OutputLogGroup),
+		FilterNamePrefix: aws.String(subscriptionFilterName),
+	})
+	if isAWSErrorCode(err, cloudwatchlogs.ErrCodeResourceNotFoundException) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range resp.SubscriptionFilters {
+		if *f.FilterName == subscriptionFilterName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Setup) putSubscriptionFilter() error {
+	stream, err := s.describeStream()
+	if err != nil {
+		return err
+	}
+	role, err := s.IAM.GetRole(&iam.GetRoleInput{
+		RoleName: aws.String(s.Config.SubscriptionRoleName),
+	})
+	if err != nil {
+		return err
+	}
+
+	sleep := s.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	// A new role can't be assumed by CloudWatch Logs until it is propagated
+	for i := 0; ; i++ {
+		_, err = s.CloudWatchLogs.PutSubscriptionFilter(&cloudwatchlogs.PutSubscriptionFilterInput{
+			LogGroupName:   aws.String(s.Config.OutputLogGroup),
+			FilterName:     aws.String(subscriptionFilterName),
+			FilterPattern:  aws.String(""),
+			DestinationArn: stream.StreamARN,
+			RoleArn:        role.Role.Arn,
+		})
+		if !isAWSErrorCode(err, cloudwatchlogs.ErrCodeInvalidParameterException) || i >= 10 {
+			return err
+		}
+		log.Printf("[DEBUG] Waiting for the role to be propagated: %s", err)
+		sleep(5 * time.Second)
+	}
+}
+
+func (s *Setup) bucketExists(bucket string) (bool, error) {
+	_, err := s.S3.HeadBucket(&s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if isAWSErrorCode(err, "NotFound") || isAWSErrorCode(err, s3.ErrCodeNoSuchBucket) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Setup) createBucket(bucket string) error {
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(bucket),
+	}
+	// us-east-1 must not be specified as a location constraint
+	if s.Region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(s.Region),
+		}
+	}
+
+	_, err := s.S3.CreateBucket(input)
+	return err
+}
+
+type bucket struct {
+	name  string
+	rules []*s3.LifecycleRule
+}
+
+// buckets returns buckets paramedic uses with lifecycle rules they need
+func (s *Setup) buckets() []*bucket {
+	buckets := []*bucket{}
+	addRule := func(name string, rule *s3.LifecycleRule) {
+		for _, b := range buckets {
+			if b.name == name {
+				b.rules = append(b.rules, rule)
+				return
+			}
+		}
+		buckets = append(buckets, &bucket{name: name, rules: []*s3.LifecycleRule{rule}})
+	}
+
+	if s.Config.ScriptS3Bucket != "" {
+		addRule(s.Config.ScriptS3Bucket, &s3.LifecycleRule{
+			ID:     aws.String(scriptsLifecycleRuleID),
+			Status: aws.String("Enabled"),
+			Filter: &s3.LifecycleRuleFilter{Prefix: aws.String("")},
+			AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{
+				DaysAfterInitiation: aws.Int64(7),
+			},
+		})
+	}
+
+	if s.Config.SignalS3Bucket != "" {
+		addRule(s.Config.SignalS3Bucket, &s3.LifecycleRule{
+			ID:     aws.String(signalsLifecycleRuleID),
+			Status: aws.String("Enabled"),
+			Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(s.Config.SignalS3KeyPrefix)},
+			Expiration: &s3.LifecycleExpiration{
+				Days: aws.Int64(s.Config.SignalExpirationDays),
+			},
+		})
+	}
+
+	return buckets
+}
+
+func (s *Setup) getLifecycleRules(bucket string) ([]*s3.LifecycleRule, error) {
+	resp, err := s.S3.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if isAWSErrorCode(err, "NoSuchLifecycleConfiguration") {
+		return []*s3.LifecycleRule{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Rules, nil
+}
+
+func (s *Setup) lifecycleRulesExist(bucket string, rules []*s3.LifecycleRule) (bool, error) {
+	current, err := s.getLifecycleRules(bucket)
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range rules {
+		found := false
+		for _, c := range current {
+			if aws.StringValue(c.ID) == *r.ID {
+				found = true
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// putLifecycleRules adds rules to the lifecycle configuration of a bucket, keeping the other rules
+func (s *Setup) putLifecycleRules(bucket string, rules []*s3.LifecycleRule) error {
+	current, err := s.getLifecycleRules(bucket)
+	if err != nil {
+		return err
+	}
+
+	ids := map[string]bool{}
+	for _, r := range rules {
+		ids[*r.ID] = true
+	}
+	for _, c := range current {
+		if !ids[aws.StringValue(c.ID)] {
+			rules = append(rules, c)
+		}
+	}
+
+	_, err = s.S3.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: rules},
+	})
+	return err
+}
+
+func isAWSErrorCode(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
@@ -0,0 +1,283 @@
+package setup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ryotarai/paramedic/awsclient"
+	"github.com/ryotarai/paramedic/store"
+)
+
+// account is an in-memory stand-in for the AWS services setup uses
+type account struct {
+	awsclient.DynamoDB
+	awsclient.CloudWatchLogs
+	awsclient.Kinesis
+	awsclient.IAM
+	awsclient.S3
+
+	tables              []string
+	logGroups           map[string]int64 // map[name]retention days
+	streams             map[string]int64 // map[name]shard count
+	roles               map[string]string
+	subscriptionFilters map[string]*cloudwatchlogs.PutSubscriptionFilterInput
+	buckets             map[string][]*s3.LifecycleRule
+	notPropagated       int
+}
+
+func newAccount() *account {
+	return &account{
+		logGroups:           map[string]int64{},
+		streams:             map[string]int64{},
+		roles:               map[string]string{},
+		subscriptionFilters: map[string]*cloudwatchlogs.PutSubscriptionFilterInput{},
+		buckets:             map[string][]*s3.LifecycleRule{},
+	}
+}
+
+func (a *account) ListTablesPages(input *dynamodb.ListTablesInput, fn func(*dynamodb.ListTablesOutput, bool) bool) error {
+	fn(&dynamodb.ListTablesOutput{TableNames: aws.StringSlice(a.tables)}, true)
+	return nil
+}
+
+func (a *account) CreateTable(input *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	a.tables = append(a.tables, *input.TableName)
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (a *account) WaitUntilTableExists(input *dynamodb.DescribeTableInput) error {
+	return nil
+}
+
+func (a *account) UpdateTimeToLive(input *dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+func (a *account) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (a *account) DescribeLogGroupsPages(input *cloudwatchlogs.DescribeLogGroupsInput, fn func(*cloudwatchlogs.DescribeLogGroupsOutput, bool) bool) error {
+	resp := &cloudwatchlogs.DescribeLogGroupsOutput{}
+	for name := range a.logGroups {
+		resp.LogGroups = append(resp.LogGroups, &cloudwatchlogs.LogGroup{LogGroupName: aws.String(name)})
+	}
+	fn(resp, true)
+	return nil
+}
+
+func (a *account) CreateLogGroup(input *cloudwatchlogs.CreateLogGroupInput) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	a.logGroups[*input.LogGroupName] = 0
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (a *account) PutRetentionPolicy(input *cloudwatchlogs.PutRetentionPolicyInput) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	a.logGroups[*input.LogGroupName] = *input.RetentionInDays
+	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
+}
+
+func (a *account) DescribeSubscriptionFilters(input *cloudwatchlogs.DescribeSubscriptionFiltersInput) (*cloudwatchlogs.DescribeSubscriptionFiltersOutput, error) {
+	if _, ok := a.logGroups[*input.LogGroupName]; !ok {
+		return nil, awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	resp := &cloudwatchlogs.DescribeSubscriptionFiltersOutput{}
+	if f, ok := a.subscriptionFilters[*input.LogGroupName]; ok {
+		resp.SubscriptionFilters = []*cloudwatchlogs.SubscriptionFilter{{FilterName: f.FilterName}}
+	}
+	return resp, nil
+}
+
+func (a *account) PutSubscriptionFilter(input *cloudwatchlogs.PutSubscriptionFilterInput) (*cloudwatchlogs.PutSubscriptionFilterOutput, error) {
+	if a.notPropagated > 0 {
+		a.notPropagated--
+		return nil, awserr.New(cloudwatchlogs.ErrCodeInvalidParameterException, "could not deliver test message", nil)
+	}
+	a.subscriptionFilters[*input.LogGroupName] = input
+	return &cloudwatchlogs.PutSubscriptionFilterOutput{}, nil
+}
+
+func (a *account) DescribeStream(input *kinesis.DescribeStreamInput) (*kinesis.DescribeStreamOutput, error) {
+	if _, ok := a.streams[*input.StreamName]; !ok {
+		return nil, awserr.New(kinesis.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	return &kinesis.DescribeStreamOutput{
+		StreamDescription: &kinesis.StreamDescription{
+			StreamName: input.StreamName,
+			StreamARN:  aws.String("arn:aws:kinesis:ap-northeast-1:123456789012:stream/" + *input.StreamName),
+		},
+	}, nil
+}
+
+func (a *account) CreateStream(input *kinesis.CreateStreamInput) (*kinesis.CreateStreamOutput, error) {
+	a.streams[*input.StreamName] = *input.ShardCount
+	return &kinesis.CreateStreamOutput{}, nil
+}
+
+func (a *account) WaitUntilStreamExists(input *kinesis.DescribeStreamInput) error {
+	return nil
+}
+
+func (a *account) GetRole(input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	if _, ok := a.roles[*input.RoleName]; !ok {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, "not found", nil)
+	}
+	return &iam.GetRoleOutput{
+		Role: &iam.Role{Arn: aws.String("arn:aws:iam::123456789012:role/" + *input.RoleName)},
+	}, nil
+}
+
+func (a *account) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	a.roles[*input.RoleName] = ""
+	return &iam.CreateRoleOutput{}, nil
+}
+
+func (a *account) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	a.roles[*input.RoleName] = *input.PolicyDocument
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func (a *account) HeadBucket(input *s3.HeadBucketInput) (*s3.HeadBucketOutput, error) {
+	if _, ok := a.buckets[*input.Bucket]; !ok {
+		return nil, awserr.New("NotFound", "not found", nil)
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (a *account) CreateBucket(input *s3.CreateBucketInput) (*s3.CreateBucketOutput, error) {
+	a.buckets[*input.Bucket] = nil
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func (a *account) GetBucketLifecycleConfiguration(input *s3.GetBucketLifecycleConfigurationInput) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	rules := a.buckets[*input.Bucket]
+	if len(rules) == 0 {
+		return nil, awserr.New("NoSuchLifecycleConfiguration", "not found", nil)
+	}
+	return &s3.GetBucketLifecycleConfigurationOutput{Rules: rules}, nil
+}
+
+func (a *account) PutBucketLifecycleConfiguration(input *s3.PutBucketLifecycleConfigurationInput) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	a.buckets[*input.Bucket] = input.LifecycleConfiguration.Rules
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+func newSetup(a *account) *Setup {
+	return &Setup{
+		Store:          store.New(a, ""),
+		CloudWatchLogs: a,
+		Kinesis:        a,
+		IAM:            a,
+		S3:             a,
+		Region:         "ap-northeast-1",
+		Config: &Config{
+			OutputLogGroup:       "paramedic",
+			LogRetentionDays:     30,
+			KinesisStream:        "paramedic-logs",
+			KinesisShardCount:    1,
+			SubscriptionRoleName: "paramedic-logs",
+			ScriptS3Bucket:       "paramedic-bucket",
+			SignalS3Bucket:       "paramedic-bucket",
+			SignalS3KeyPrefix:    "signals/",
+			SignalExpirationDays: 7,
+		},
+		sleep: func(time.Duration) {},
+	}
+}
+
+func actions(steps []*Step) []string {
+	a := []string{}
+	for _, s := range steps {
+		a = append(a, s.Resource+":"+s.Action)
+	}
+	return a
+}
+
+func TestSetup(t *testing.T) {
+	a := newAccount()
+	a.notPropagated = 2
+	s := newSetup(a)
+
+	steps, err := s.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"DynamoDB table:create",
+		"CloudWatch Logs log group:create",
+		"Kinesis stream:create",
+		"IAM role:create",
+		"CloudWatch Logs subscription filter:create",
+		"S3 bucket:create",
+		"S3 lifecycle rules:create",
+	}
+	if got := actions(steps); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := s.Apply(steps); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.logGroups["paramedic"] != 30 {
+		t.Errorf("got retention %d, want 30", a.logGroups["paramedic"])
+	}
+	f := a.subscriptionFilters["paramedic"]
+	if f == nil || *f.DestinationArn != "arn:aws:kinesis:ap-northeast-1:123456789012:stream/paramedic-logs" {
+		t.Errorf("subscription filter is not put to the stream: %v", f)
+	}
+	if len(a.buckets["paramedic-bucket"]) != 2 {
+		t.Errorf("got %d lifecycle rules, want 2", len(a.buckets["paramedic-bucket"]))
+	}
+
+	// Nothing is created twice
+	steps, err = s.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range steps {
+		if st.Action != ActionNone {
+			t.Errorf("%s %s is going to be %sd again", st.Resource, st.Name, st.Action)
+		}
+	}
+}
+
+func TestSetupLifecycleRules(t *testing.T) {
+	a := newAccount()
+	a.buckets["paramedic-bucket"] = []*s3.LifecycleRule{{ID: aws.String("other")}}
+	s := newSetup(a)
+	s.Config.KinesisStream = ""
+
+	steps, err := s.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"DynamoDB table:create",
+		"CloudWatch Logs log group:create",
+		"S3 bucket:none",
+		"S3 lifecycle rules:update",
+	}
+	if got := actions(steps); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := s.Apply(steps); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, r := range a.buckets["paramedic-bucket"] {
+		ids = append(ids, *r.ID)
+	}
+	if want := []string{scriptsLifecycleRuleID, signalsLifecycleRuleID, "other"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got rules %v, want %v", ids, want)
+	}
+}
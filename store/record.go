@@ -1,6 +1,47 @@
 package store
 
+import "time"
+
+// CommandRecord is a command in the history.
+// Attribute names in DynamoDB are explicit so that json tags don't change them.
 type CommandRecord struct {
-	CommandID  string
-	PcommandID string
+	CommandID  string `dynamodbav:"CommandID" json:"commandId" yaml:"commandId"`
+	PcommandID string `dynamodbav:"PcommandID" json:"pcommandId" yaml:"pcommandId"`
+
+	DocumentName    string              `dynamodbav:"DocumentName,omitempty" json:"documentName,omitempty" yaml:"documentName,omitempty"`
+	DocumentVersion string              `dynamodbav:"DocumentVersion,omitempty" json:"documentVersion,omitempty" yaml:"documentVersion,omitempty"`
+	ScriptS3Key     string              `dynamodbav:"ScriptS3Key,omitempty" json:"scriptS3Key,omitempty" yaml:"scriptS3Key,omitempty"`
+	Targets         map[string][]string `dynamodbav:"Targets,omitempty" json:"targets,omitempty" yaml:"targets,omitempty"`
+	// TargetTags is a list of "key=value" for filtering by tag
+	TargetTags   []string       `dynamodbav:"TargetTags,omitempty" json:"targetTags,omitempty" yaml:"targetTags,omitempty"`
+	RequestedBy  string         `dynamodbav:"RequestedBy,omitempty" json:"requestedBy,omitempty" yaml:"requestedBy,omitempty"`
+	StartedAt    time.Time      `dynamodbav:"StartedAt,unixtime" json:"startedAt" yaml:"startedAt"`
+	Status       string         `dynamodbav:"Status,omitempty" json:"status,omitempty" yaml:"status,omitempty"`
+	StatusCounts map[string]int `dynamodbav:"StatusCounts,omitempty" json:"statusCounts,omitempty" yaml:"statusCounts,omitempty"`
+
+	// Options the command was sent with, to run it again
+	Parameters        map[string]string `dynamodbav:"Parameters,omitempty" json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	ExecutionTimeout  int64             `dynamodbav:"ExecutionTimeout,omitempty" json:"executionTimeout,omitempty" yaml:"executionTimeout,omitempty"`
+	MaxConcurrency    string            `dynamodbav:"MaxConcurrency,omitempty" json:"maxConcurrency,omitempty" yaml:"maxConcurrency,omitempty"`
+	MaxErrors         string            `dynamodbav:"MaxErrors,omitempty" json:"maxErrors,omitempty" yaml:"maxErrors,omitempty"`
+	OutputLogGroup    string            `dynamodbav:"OutputLogGroup,omitempty" json:"outputLogGroup,omitempty" yaml:"outputLogGroup,omitempty"`
+	SignalS3Bucket    string            `dynamodbav:"SignalS3Bucket,omitempty" json:"signalS3Bucket,omitempty" yaml:"signalS3Bucket,omitempty"`
+	SignalS3KeyPrefix string            `dynamodbav:"SignalS3KeyPrefix,omitempty" json:"signalS3KeyPrefix,omitempty" yaml:"signalS3KeyPrefix,omitempty"`
+
+	// RerunOf is the ID of the command this command re-runs
+	RerunOf string `dynamodbav:"RerunOf,omitempty" json:"rerunOf,omitempty" yaml:"rerunOf,omitempty"`
+
+	// TargetGroup is the name of the saved target group the command is run on
+	TargetGroup string `dynamodbav:"TargetGroup,omitempty" json:"targetGroup,omitempty" yaml:"targetGroup,omitempty"`
+
+	// BatchID is the command ID of the first batch if the command is sent as batches of instance IDs
+	BatchID string `dynamodbav:"BatchID,omitempty" json:"batchId,omitempty" yaml:"batchId,omitempty"`
+	// BatchCommandIDs is stored only in the record of the first batch, which has the status of all the batches
+	BatchCommandIDs []string `dynamodbav:"BatchCommandIDs,omitempty" json:"batchCommandIds,omitempty" yaml:"batchCommandIds,omitempty"`
+
+	// RolloutID is the command ID of the first wave of a staged rollout
+	RolloutID string `dynamodbav:"RolloutID,omitempty" json:"rolloutId,omitempty" yaml:"rolloutId,omitempty"`
+	// RolloutStages and RolloutCommandIDs are stored only in the record of the first wave
+	RolloutStages     []string `dynamodbav:"RolloutStages,omitempty" json:"rolloutStages,omitempty" yaml:"rolloutStages,omitempty"`
+	RolloutCommandIDs []string `dynamodbav:"RolloutCommandIDs,omitempty" json:"rolloutCommandIds,omitempty" yaml:"rolloutCommandIds,omitempty"`
 }
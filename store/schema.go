@@ -0,0 +1,291 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// SchemaVersion is the version of the table schema this version of paramedic expects
+const SchemaVersion = 4
+
+const (
+	// schemaItemID is CommandID of the item which has the schema version of the table
+	schemaItemID = "paramedic:schema"
+
+	ttlAttributeName = "ExpiresAt"
+)
+
+// Migration updates the table to a schema version
+type Migration struct {
+	Version     int
+	Description string
+
+	apply func(s *Store) error
+}
+
+var migrations = []*Migration{
+	{
+		Version:     1,
+		Description: "Set RecordType of commands recorded before the history was indexed",
+		apply:       migrateRecordType,
+	},
+	{
+		Version:     2,
+		Description: fmt.Sprintf("Enable TTL on %s attribute to expire old commands", ttlAttributeName),
+		apply:       enableTTL,
+	},
+	{
+		Version:     3,
+		Description: "Switch the table provisioned with 1 RCU and 1 WCU to on-demand billing mode",
+		apply:       migrateBillingMode,
+	},
+	{
+		Version:     4,
+		Description: "Create the indexes sorted by StartedAt to query the history",
+		apply:       createIndexes,
+	},
+}
+
+// GetSchemaVersion returns the schema version of the table. It is 0 if the table predates schema versions.
+func (s *Store) GetSchemaVersion() (int, error) {
+	resp, err := s.dynamodb.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"CommandID": {S: aws.String(schemaItemID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := resp.Item["SchemaVersion"]
+	if !ok || v.N == nil {
+		return 0, nil
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(*v.N, "%d", &version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *Store) putSchemaVersion(version int) error {
+	_, err := s.dynamodb.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"CommandID":     {S: aws.String(schemaItemID)},
+			"SchemaVersion": {N: aws.String(fmt.Sprintf("%d", version))},
+		},
+	})
+	return err
+}
+
+// PendingMigrations returns migrations which are not applied to the table yet
+func (s *Store) PendingMigrations() ([]*Migration, error) {
+	version, err := s.GetSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("schema version of table %s is %d, which is newer than %d this paramedic supports", s.tableName, version, SchemaVersion)
+	}
+
+	pending := []*Migration{}
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies migrations in order, recording the schema version after each of them
+func (s *Store) Migrate(ms []*Migration) error {
+	for _, m := range ms {
+		log.Printf("[INFO] Migrating %s table to version %d: %s", s.tableName, m.Version, m.Description)
+		if err := m.apply(s); err != nil {
+			return fmt.Errorf("migration to version %d failed: %s", m.Version, err)
+		}
+		if err := s.putSchemaVersion(m.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateRecordType(s *Store) error {
+	ids := []string{}
+	err := s.dynamodb.ScanPages(&dynamodb.ScanInput{
+		TableName:            aws.String(s.tableName),
+		ProjectionExpression: aws.String("CommandID"),
+		FilterExpression:     aws.String("attribute_not_exists(RecordType) AND CommandID <> :schema"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":schema": {S: aws.String(schemaItemID)},
+		},
+	}, func(resp *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range resp.Items {
+			ids = append(ids, *item["CommandID"].S)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] Setting RecordType of %d commands", len(ids))
+	for _, id := range ids {
+		_, err := s.dynamodb.UpdateItem(&dynamodb.UpdateItemInput{
+			TableName: aws.String(s.tableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"CommandID": {S: aws.String(id)},
+			},
+			UpdateExpression: aws.String("SET RecordType = :type"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":type": {S: aws.String(commandRecordType)},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func enableTTL(s *Store) error {
+	_, err := s.dynamodb.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(s.tableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(ttlAttributeName),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && strings.Contains(aerr.Message(), "already enabled") {
+		return nil
+	}
+	return err
+}
+
+// migrateBillingMode switches a table created with the throughput paramedic used to provision
+// to on-demand billing mode. Tables provisioned with other throughput are left as they are.
+func migrateBillingMode(s *Store) error {
+	table, err := s.describeTable()
+	if err != nil {
+		return err
+	}
+
+	throughput := table.ProvisionedThroughput
+	if throughput == nil || aws.Int64Value(throughput.ReadCapacityUnits) != 1 || aws.Int64Value(throughput.WriteCapacityUnits) != 1 {
+		log.Printf("[DEBUG] Keeping the billing mode of %s table", s.tableName)
+		return nil
+	}
+
+	_, err = s.dynamodb.UpdateTableToPayPerRequest(&dynamodb.UpdateTableInput{
+		TableName: aws.String(s.tableName),
+	})
+	if err != nil {
+		return err
+	}
+	return s.waitUntilTableActive()
+}
+
+// createIndexes creates the indexes missing in the table one by one, as DynamoDB creates only one index at a time
+func createIndexes(s *Store) error {
+	table, err := s.describeTable()
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, i := range table.GlobalSecondaryIndexes {
+		existing[aws.StringValue(i.IndexName)] = true
+	}
+
+	// Indexes of an on-demand table have no throughput
+	var throughput *dynamodb.ProvisionedThroughput
+	if t := table.ProvisionedThroughput; t != nil && aws.Int64Value(t.ReadCapacityUnits) > 0 {
+		throughput = &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  t.ReadCapacityUnits,
+			WriteCapacityUnits: t.WriteCapacityUnits,
+		}
+	}
+
+	for _, i := range indexKeys {
+		if existing[i.name] {
+			log.Printf("[DEBUG] %s index already exists", i.name)
+			continue
+		}
+
+		log.Printf("[INFO] Creating %s index, which may take a while for a large table", i.name)
+		_, err := s.dynamodb.UpdateTable(&dynamodb.UpdateTableInput{
+			TableName: aws.String(s.tableName),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{
+					AttributeName: aws.String(i.hashKey),
+					AttributeType: aws.String("S"),
+				},
+				{
+					AttributeName: aws.String("StartedAt"),
+					AttributeType: aws.String("N"),
+				},
+			},
+			GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+				{
+					Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+						IndexName:             aws.String(i.name),
+						KeySchema:             i.keySchema(),
+						Projection:            &dynamodb.Projection{ProjectionType: aws.String("ALL")},
+						ProvisionedThroughput: throughput,
+					},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.waitUntilTableActive(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) describeTable() (*dynamodb.TableDescription, error) {
+	resp, err := s.dynamodb.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(s.tableName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Table, nil
+}
+
+// waitUntilTableActive waits until the table and all of its indexes become ACTIVE
+func (s *Store) waitUntilTableActive() error {
+	for {
+		table, err := s.describeTable()
+		if err != nil {
+			return err
+		}
+
+		active := aws.StringValue(table.TableStatus) == dynamodb.TableStatusActive
+		for _, i := range table.GlobalSecondaryIndexes {
+			if aws.StringValue(i.IndexStatus) != dynamodb.IndexStatusActive {
+				active = false
+			}
+		}
+		if active {
+			return nil
+		}
+
+		log.Printf("[DEBUG] Waiting for %s table to become ACTIVE", s.tableName)
+		time.Sleep(s.pollInterval)
+	}
+}
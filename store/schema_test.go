@@ -0,0 +1,226 @@
+package store
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/ryotarai/paramedic/awsclient"
+)
+
+// itemsDynamoDB is an in-memory table keyed by CommandID
+type itemsDynamoDB struct {
+	awsclient.DynamoDB
+
+	items      map[string]map[string]*dynamodb.AttributeValue
+	ttlEnabled bool
+
+	throughput    *dynamodb.ProvisionedThroughput
+	indexes       []*dynamodb.CreateGlobalSecondaryIndexAction
+	payPerRequest bool
+	// updating is the number of times the table is described as UPDATING after an update
+	updating int
+}
+
+func (d *itemsDynamoDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: d.items[*input.Key["CommandID"].S]}, nil
+}
+
+func (d *itemsDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	d.items[*input.Item["CommandID"].S] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// ScanPages supports only the filter of migrateRecordType
+func (d *itemsDynamoDB) ScanPages(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool) error {
+	resp := &dynamodb.ScanOutput{}
+	for id, item := range d.items {
+		if _, ok := item["RecordType"]; !ok && id != schemaItemID {
+			resp.Items = append(resp.Items, map[string]*dynamodb.AttributeValue{"CommandID": {S: aws.String(id)}})
+		}
+	}
+	fn(resp, true)
+	return nil
+}
+
+// UpdateItem supports only the expression of migrateRecordType
+func (d *itemsDynamoDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	d.items[*input.Key["CommandID"].S]["RecordType"] = input.ExpressionAttributeValues[":type"]
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (d *itemsDynamoDB) UpdateTimeToLive(input *dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	d.ttlEnabled = *input.TimeToLiveSpecification.Enabled
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+func (d *itemsDynamoDB) DescribeTable(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	table := &dynamodb.TableDescription{
+		TableStatus: aws.String(dynamodb.TableStatusActive),
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughputDescription{
+			ReadCapacityUnits:  aws.Int64(0),
+			WriteCapacityUnits: aws.Int64(0),
+		},
+	}
+	if d.updating > 0 {
+		d.updating--
+		table.TableStatus = aws.String(dynamodb.TableStatusUpdating)
+	}
+	if d.throughput != nil {
+		table.ProvisionedThroughput.ReadCapacityUnits = d.throughput.ReadCapacityUnits
+		table.ProvisionedThroughput.WriteCapacityUnits = d.throughput.WriteCapacityUnits
+	}
+	for _, i := range d.indexes {
+		table.GlobalSecondaryIndexes = append(table.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{
+			IndexName:   i.IndexName,
+			IndexStatus: aws.String(dynamodb.IndexStatusActive),
+		})
+	}
+	return &dynamodb.DescribeTableOutput{Table: table}, nil
+}
+
+// UpdateTable supports only creating an index
+func (d *itemsDynamoDB) UpdateTable(input *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	d.indexes = append(d.indexes, input.GlobalSecondaryIndexUpdates[0].Create)
+	d.updating = 2
+	return &dynamodb.UpdateTableOutput{}, nil
+}
+
+func (d *itemsDynamoDB) UpdateTableToPayPerRequest(input *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	d.throughput = nil
+	d.payPerRequest = true
+	d.updating = 2
+	return &dynamodb.UpdateTableOutput{}, nil
+}
+
+// indexNames returns names of the indexes in the order of creation
+func (d *itemsDynamoDB) indexNames() []string {
+	names := []string{}
+	for _, i := range d.indexes {
+		names = append(names, *i.IndexName)
+	}
+	return names
+}
+
+func TestMigrate(t *testing.T) {
+	d := &itemsDynamoDB{
+		items: map[string]map[string]*dynamodb.AttributeValue{
+			"old": {"CommandID": {S: aws.String("old")}},
+			"new": {"CommandID": {S: aws.String("new")}, "RecordType": {S: aws.String(commandRecordType)}},
+		},
+		// The table created by old versions of paramedic
+		throughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(1),
+			WriteCapacityUnits: aws.Int64(1),
+		},
+	}
+	s := New(d, "")
+	s.pollInterval = 0
+
+	pending, err := s.PendingMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := []int{}
+	for _, m := range pending {
+		versions = append(versions, m.Version)
+	}
+	if want := []int{1, 2, 3, 4}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("got pending versions %v, want %v", versions, want)
+	}
+
+	if err := s.Migrate(pending); err != nil {
+		t.Fatal(err)
+	}
+
+	types := []string{}
+	for id, item := range d.items {
+		if id != schemaItemID {
+			types = append(types, *item["RecordType"].S)
+		}
+	}
+	sort.Strings(types)
+	if want := []string{"command", "command"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("got record types %v, want %v", types, want)
+	}
+	if !d.ttlEnabled {
+		t.Error("TTL is not enabled")
+	}
+	if !d.payPerRequest {
+		t.Error("billing mode is not switched to PAY_PER_REQUEST")
+	}
+	want := []string{recordTypeIndex, documentNameIndex, requestedByIndex, statusIndex}
+	if got := d.indexNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got indexes %v, want %v", got, want)
+	}
+	for _, i := range d.indexes {
+		if i.ProvisionedThroughput != nil {
+			t.Errorf("got throughput %v of %s index, want none", i.ProvisionedThroughput, *i.IndexName)
+		}
+	}
+
+	version, err := s.GetSchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("got version %d, want %d", version, SchemaVersion)
+	}
+
+	pending, err = s.PendingMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("got %d pending migrations, want 0", len(pending))
+	}
+}
+
+func TestPendingMigrationsNewerSchema(t *testing.T) {
+	d := &itemsDynamoDB{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	s := New(d, "")
+	s.putSchemaVersion(SchemaVersion + 1)
+
+	if _, err := s.PendingMigrations(); err == nil {
+		t.Error("got no error for a newer schema")
+	}
+}
+
+func TestMigrateProvisionedTable(t *testing.T) {
+	d := &itemsDynamoDB{
+		items: map[string]map[string]*dynamodb.AttributeValue{},
+		throughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(3),
+		},
+		indexes: []*dynamodb.CreateGlobalSecondaryIndexAction{
+			{IndexName: aws.String(recordTypeIndex)},
+		},
+	}
+	s := New(d, "")
+	s.pollInterval = 0
+	s.putSchemaVersion(2)
+
+	pending, err := s.PendingMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Migrate(pending); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.payPerRequest {
+		t.Error("billing mode of the table provisioned by the user is switched")
+	}
+	want := []string{recordTypeIndex, documentNameIndex, requestedByIndex, statusIndex}
+	if got := d.indexNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got indexes %v, want %v", got, want)
+	}
+	for _, i := range d.indexes[1:] {
+		if got := i.ProvisionedThroughput; got == nil || *got.ReadCapacityUnits != 5 || *got.WriteCapacityUnits != 3 {
+			t.Errorf("got throughput %v of %s index, want 5 RCU and 3 WCU", got, *i.IndexName)
+		}
+	}
+}
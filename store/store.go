@@ -1,7 +1,12 @@
 package store
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -9,15 +14,72 @@ import (
 	"github.com/ryotarai/paramedic/awsclient"
 )
 
-const commandsTableName = "ParamedicCommands"
+// DefaultTableName is the name of the table commands are stored in by default
+const DefaultTableName = "ParamedicCommands"
+
+const (
+	// commandRecordType is the partition key of recordTypeIndex, so that all commands can be queried in time order
+	commandRecordType = "command"
+
+	recordTypeIndex   = "RecordType-StartedAt-index"
+	documentNameIndex = "DocumentName-StartedAt-index"
+	requestedByIndex  = "RequestedBy-StartedAt-index"
+	statusIndex       = "Status-StartedAt-index"
+)
+
+// indexKeys is the partition keys of the indexes, which are sorted by StartedAt
+var indexKeys = []indexKey{
+	{recordTypeIndex, "RecordType"},
+	{documentNameIndex, "DocumentName"},
+	{requestedByIndex, "RequestedBy"},
+	{statusIndex, "Status"},
+}
+
+type indexKey struct {
+	name    string
+	hashKey string
+}
+
+func (i indexKey) keySchema() []*dynamodb.KeySchemaElement {
+	return []*dynamodb.KeySchemaElement{
+		{
+			AttributeName: aws.String(i.hashKey),
+			KeyType:       aws.String("HASH"),
+		},
+		{
+			AttributeName: aws.String("StartedAt"),
+			KeyType:       aws.String("RANGE"),
+		},
+	}
+}
 
 type Store struct {
-	dynamodb awsclient.DynamoDB
+	dynamodb  awsclient.DynamoDB
+	tableName string
+
+	// pollInterval is the interval to check whether the table has been updated
+	pollInterval time.Duration
+
+	// HistoryTTL is how long commands are kept in the history (0 means forever)
+	HistoryTTL time.Duration
 }
 
-func New(dynamodb awsclient.DynamoDB) *Store {
+// TableOptions is options for CreateTables
+type TableOptions struct {
+	// The table is created in on-demand billing mode if they are zero
+	ReadCapacity  int64
+	WriteCapacity int64
+}
+
+// New returns a store. DefaultTableName is used if tableName is empty.
+func New(dynamodb awsclient.DynamoDB, tableName string) *Store {
+	if tableName == "" {
+		tableName = DefaultTableName
+	}
 	return &Store{
-		dynamodb: dynamodb,
+		dynamodb:     dynamodb,
+		tableName:    tableName,
+		pollInterval: 10 * time.Second,
 	}
 }
 
@@ -26,9 +88,14 @@ func (s *Store) PutCommand(r *CommandRecord) error {
 	if err != nil {
 		return err
 	}
+	av["RecordType"] = &dynamodb.AttributeValue{S: aws.String(commandRecordType)}
+	if s.HistoryTTL > 0 {
+		expiresAt := r.StartedAt.Add(s.HistoryTTL).Unix()
+		av[ttlAttributeName] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", expiresAt))}
+	}
 
 	_, err = s.dynamodb.PutItem(&dynamodb.PutItemInput{
-		TableName: aws.String(commandsTableName),
+		TableName: aws.String(s.tableName),
 		Item:      av,
 	})
 	if err != nil {
@@ -40,7 +107,7 @@ func (s *Store) PutCommand(r *CommandRecord) error {
 
 func (s *Store) GetCommand(commandID string) (*CommandRecord, error) {
 	resp, err := s.dynamodb.GetItem(&dynamodb.GetItemInput{
-		TableName: aws.String(commandsTableName),
+		TableName: aws.String(s.tableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			"CommandID": {S: aws.String(commandID)},
 		},
@@ -58,17 +125,193 @@ func (s *Store) GetCommand(commandID string) (*CommandRecord, error) {
 	return &r, nil
 }
 
-func (s *Store) CreateTablesIfNotExists() error {
+// UpdateCommandStatus updates the status of a command and the number of instances in each status
+func (s *Store) UpdateCommandStatus(commandID, status string, counts map[string]int) error {
+	c, err := dynamodbattribute.Marshal(counts)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.dynamodb.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"CommandID": {S: aws.String(commandID)},
+		},
+		ConditionExpression: aws.String("attribute_exists(CommandID)"),
+		UpdateExpression:    aws.String("SET #status = :status, StatusCounts = :counts"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("Status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {S: aws.String(status)},
+			":counts": c,
+		},
+	})
+	return err
+}
+
+// AddRolloutCommand links a command to a staged rollout
+func (s *Store) AddRolloutCommand(rolloutID, commandID string) error {
+	_, err := s.dynamodb.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"CommandID": {S: aws.String(rolloutID)},
+		},
+		ConditionExpression: aws.String("attribute_exists(CommandID)"),
+		UpdateExpression:    aws.String("SET RolloutCommandIDs = list_append(if_not_exists(RolloutCommandIDs, :empty), :ids)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":empty": {L: []*dynamodb.AttributeValue{}},
+			":ids":   {L: []*dynamodb.AttributeValue{{S: aws.String(commandID)}}},
+		},
+	})
+	return err
+}
+
+// ListCommandsOptions is options for ListCommands
+type ListCommandsOptions struct {
+	DocumentName string
+	// RequestedBy matches the whole ARN of an IAM identity or a part of it
+	RequestedBy string
+	Status      string
+	// Tag is in "key=value" format
+	Tag   string
+	Since time.Time
+	Limit int64
+	// NextToken is returned by the previous ListCommands call
+	NextToken string
+}
+
+// ListCommands returns command records in reverse chronological order and a token for the next page.
+// The token is empty if there are no more records.
+func (s *Store) ListCommands(opts *ListCommandsOptions) ([]*CommandRecord, string, error) {
+	names := map[string]*string{}
+	values := map[string]*dynamodb.AttributeValue{}
+	filters := []string{}
+
+	// Choose the most selective index and filter the others
+	var index, hashKey, hashValue string
+	switch {
+	case opts.DocumentName != "":
+		index, hashKey, hashValue = documentNameIndex, "DocumentName", opts.DocumentName
+	case strings.HasPrefix(opts.RequestedBy, "arn:"):
+		index, hashKey, hashValue = requestedByIndex, "RequestedBy", opts.RequestedBy
+	case opts.Status != "":
+		index, hashKey, hashValue = statusIndex, "Status", opts.Status
+	default:
+		index, hashKey, hashValue = recordTypeIndex, "RecordType", commandRecordType
+	}
+
+	names["#hash"] = aws.String(hashKey)
+	values[":hash"] = &dynamodb.AttributeValue{S: aws.String(hashValue)}
+	keyCondition := "#hash = :hash"
+	if !opts.Since.IsZero() {
+		values[":since"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", opts.Since.Unix()))}
+		keyCondition += " AND StartedAt >= :since"
+	}
+
+	if opts.RequestedBy != "" && hashKey != "RequestedBy" {
+		values[":requestedBy"] = &dynamodb.AttributeValue{S: aws.String(opts.RequestedBy)}
+		filters = append(filters, "contains(RequestedBy, :requestedBy)")
+	}
+	if opts.Status != "" && hashKey != "Status" {
+		names["#status"] = aws.String("Status")
+		values[":status"] = &dynamodb.AttributeValue{S: aws.String(opts.Status)}
+		filters = append(filters, "#status = :status")
+	}
+	if opts.Tag != "" {
+		values[":tag"] = &dynamodb.AttributeValue{S: aws.String(opts.Tag)}
+		filters = append(filters, "contains(TargetTags, :tag)")
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		IndexName:                 aws.String(index),
+		KeyConditionExpression:    aws.String(keyCondition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(false),
+	}
+	if len(filters) > 0 {
+		input.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+	if opts.Limit > 0 {
+		input.Limit = aws.Int64(opts.Limit)
+	}
+	if opts.NextToken != "" {
+		key, err := decodeNextToken(opts.NextToken)
+		if err != nil {
+			return nil, "", err
+		}
+		input.ExclusiveStartKey = key
+	}
+
+	resp, err := s.dynamodb.Query(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	records := []*CommandRecord{}
+	err = dynamodbattribute.UnmarshalListOfMaps(resp.Items, &records)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextToken := ""
+	if len(resp.LastEvaluatedKey) > 0 {
+		nextToken, err = encodeNextToken(resp.LastEvaluatedKey)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return records, nextToken, nil
+}
+
+func encodeNextToken(key map[string]*dynamodb.AttributeValue) (string, error) {
+	b, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+func decodeNextToken(token string) (map[string]*dynamodb.AttributeValue, error) {
+	b, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("next token is invalid: %s", err)
+	}
+	key := map[string]*dynamodb.AttributeValue{}
+	if err := json.Unmarshal(b, &key); err != nil {
+		return nil, fmt.Errorf("next token is invalid: %s", err)
+	}
+	return key, nil
+}
+
+// TableName returns the name of the DynamoDB table commands are stored in
+func (s *Store) TableName() string {
+	return s.tableName
+}
+
+// TableExists returns true if the table exists
+func (s *Store) TableExists() (bool, error) {
 	found := false
 	err := s.dynamodb.ListTablesPages(&dynamodb.ListTablesInput{}, func(resp *dynamodb.ListTablesOutput, last bool) bool {
 		for _, n := range resp.TableNames {
-			if *n == commandsTableName {
+			if *n == s.tableName {
 				found = true
 				return false
 			}
 		}
 		return true
 	})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+func (s *Store) CreateTablesIfNotExists() error {
+	found, err := s.TableExists()
 	if err != nil {
 		return err
 	}
@@ -77,29 +320,82 @@ func (s *Store) CreateTablesIfNotExists() error {
 		return nil
 	}
 
-	return s.createTables()
+	return s.CreateTables(&TableOptions{})
 }
 
-func (s *Store) createTables() error {
-	log.Printf("[INFO] Creating %s table", commandsTableName)
+// CreateTables creates the table and its indexes, and initializes it with the latest schema
+func (s *Store) CreateTables(opts *TableOptions) error {
+	log.Printf("[INFO] Creating %s table", s.tableName)
+
+	var throughput *dynamodb.ProvisionedThroughput
+	if opts.ReadCapacity > 0 || opts.WriteCapacity > 0 {
+		throughput = &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(opts.ReadCapacity),
+			WriteCapacityUnits: aws.Int64(opts.WriteCapacity),
+		}
+	}
+
+	indexes := []*dynamodb.GlobalSecondaryIndex{}
+	for _, i := range indexKeys {
+		indexes = append(indexes, &dynamodb.GlobalSecondaryIndex{
+			IndexName:             aws.String(i.name),
+			KeySchema:             i.keySchema(),
+			Projection:            &dynamodb.Projection{ProjectionType: aws.String("ALL")},
+			ProvisionedThroughput: throughput,
+		})
+	}
+
 	_, err := s.dynamodb.CreateTable(&dynamodb.CreateTableInput{
-		TableName: aws.String(commandsTableName),
+		TableName: aws.String(s.tableName),
 		AttributeDefinitions: []*dynamodb.AttributeDefinition{
 			{
 				AttributeName: aws.String("CommandID"),
 				AttributeType: aws.String("S"), // string
 			},
+			{
+				AttributeName: aws.String("RecordType"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("DocumentName"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("RequestedBy"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("Status"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("StartedAt"),
+				AttributeType: aws.String("N"), // number
+			},
 		},
+		GlobalSecondaryIndexes: indexes,
 		KeySchema: []*dynamodb.KeySchemaElement{
 			{
 				AttributeName: aws.String("CommandID"),
 				KeyType:       aws.String("HASH"),
 			},
 		},
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(1),
-			WriteCapacityUnits: aws.Int64(1),
-		},
+		ProvisionedThroughput: throughput,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+
+	err = s.dynamodb.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+		TableName: aws.String(s.tableName),
+	})
+	if err != nil {
+		return err
+	}
+
+	// A new table doesn't need migrations
+	if err := enableTTL(s); err != nil {
+		return err
+	}
+	return s.putSchemaVersion(SchemaVersion)
 }
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/ryotarai/paramedic/awsclient/fake"
+)
+
+func TestNextToken(t *testing.T) {
+	key := map[string]*dynamodb.AttributeValue{
+		"CommandID":  {S: aws.String("foo")},
+		"RecordType": {S: aws.String("command")},
+		"StartedAt":  {N: aws.String("1506486379")},
+	}
+
+	token, err := encodeNextToken(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := decodeNextToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, key) {
+		t.Errorf("got %v, want %v", got, key)
+	}
+
+	if _, err := decodeNextToken("invalid"); err == nil {
+		t.Error("decodeNextToken(invalid) returns no error")
+	}
+}
+
+func TestListCommands(t *testing.T) {
+	s := New(fake.NewDynamoDB(), "")
+	if err := s.CreateTablesIfNotExists(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1506486379, 0)
+	for i, r := range []*CommandRecord{
+		{CommandID: "c1", DocumentName: "reload-nginx", TargetTags: []string{"Role=app"}, RequestedBy: "arn:aws:iam::123456789012:user/alice", Status: "Success"},
+		{CommandID: "c2", DocumentName: "restart-app", TargetTags: []string{"Role=app"}, RequestedBy: "arn:aws:iam::123456789012:user/bob", Status: "Failed"},
+		{CommandID: "c3", DocumentName: "reload-nginx", TargetTags: []string{"Role=db"}, RequestedBy: "arn:aws:iam::123456789012:user/alice", Status: "InProgress"},
+	} {
+		r.StartedAt = now.Add(time.Duration(i) * time.Minute)
+		if err := s.PutCommand(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.UpdateCommandStatus("c3", "Success", map[string]int{"Success": 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		opts *ListCommandsOptions
+		want []string
+	}{
+		{&ListCommandsOptions{}, []string{"c3", "c2", "c1"}},
+		{&ListCommandsOptions{DocumentName: "reload-nginx"}, []string{"c3", "c1"}},
+		{&ListCommandsOptions{RequestedBy: "alice", Status: "Success"}, []string{"c3", "c1"}},
+		{&ListCommandsOptions{Tag: "Role=app"}, []string{"c2", "c1"}},
+		{&ListCommandsOptions{Since: now.Add(time.Minute)}, []string{"c3", "c2"}},
+	}
+	for _, c := range cases {
+		ids := []string{}
+		opts := *c.opts
+		opts.Limit = 1
+		for {
+			records, next, err := s.ListCommands(&opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range records {
+				ids = append(ids, r.CommandID)
+			}
+			if next == "" {
+				break
+			}
+			opts.NextToken = next
+		}
+		if !reflect.DeepEqual(ids, c.want) {
+			t.Errorf("%+v: got %v, want %v", c.opts, ids, c.want)
+		}
+	}
+
+	if err := s.UpdateCommandStatus("unknown", "Success", nil); err == nil {
+		t.Error("UpdateCommandStatus of an unknown command returns no error")
+	}
+}
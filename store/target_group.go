@@ -0,0 +1,108 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const (
+	// targetGroupRecordType is the partition key of recordTypeIndex for target groups
+	targetGroupRecordType = "target-group"
+
+	// targetGroupIDPrefix is the prefix of CommandID of target groups, which share the table with commands
+	targetGroupIDPrefix = "paramedic:target-group:"
+)
+
+// TargetGroupRecord is a saved set of targets of commands
+type TargetGroupRecord struct {
+	Name string `dynamodbav:"Name" json:"name" yaml:"name"`
+
+	InstanceIDs []string `dynamodbav:"InstanceIDs,omitempty" json:"instanceIds,omitempty" yaml:"instanceIds,omitempty"`
+	// Tags and ExcludeTags are terms of tag expressions
+	Tags               []string `dynamodbav:"Tags,omitempty" json:"tags,omitempty" yaml:"tags,omitempty"`
+	ExcludeInstanceIDs []string `dynamodbav:"ExcludeInstanceIDs,omitempty" json:"excludeInstanceIds,omitempty" yaml:"excludeInstanceIds,omitempty"`
+	ExcludeTags        []string `dynamodbav:"ExcludeTags,omitempty" json:"excludeTags,omitempty" yaml:"excludeTags,omitempty"`
+	// InstanceName is a glob or a regular expression between slashes names of instances match
+	InstanceName string `dynamodbav:"InstanceName,omitempty" json:"instanceName,omitempty" yaml:"instanceName,omitempty"`
+
+	UpdatedBy string `dynamodbav:"UpdatedBy,omitempty" json:"updatedBy,omitempty" yaml:"updatedBy,omitempty"`
+	// UpdatedAt is stored as StartedAt, the sort key of recordTypeIndex
+	UpdatedAt time.Time `dynamodbav:"StartedAt,unixtime" json:"updatedAt" yaml:"updatedAt"`
+}
+
+// PutTargetGroup creates or replaces a target group
+func (s *Store) PutTargetGroup(r *TargetGroupRecord) error {
+	av, err := dynamodbattribute.MarshalMap(r)
+	if err != nil {
+		return err
+	}
+	av["CommandID"] = &dynamodb.AttributeValue{S: aws.String(targetGroupIDPrefix + r.Name)}
+	av["RecordType"] = &dynamodb.AttributeValue{S: aws.String(targetGroupRecordType)}
+
+	_, err = s.dynamodb.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      av,
+	})
+	return err
+}
+
+// GetTargetGroup returns a target group by name
+func (s *Store) GetTargetGroup(name string) (*TargetGroupRecord, error) {
+	resp, err := s.dynamodb.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"CommandID": {S: aws.String(targetGroupIDPrefix + name)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Item) == 0 {
+		return nil, fmt.Errorf("target group '%s' is not found", name)
+	}
+
+	r := TargetGroupRecord{}
+	err = dynamodbattribute.UnmarshalMap(resp.Item, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// ListTargetGroups returns all target groups in reverse chronological order of updates
+func (s *Store) ListTargetGroups() ([]*TargetGroupRecord, error) {
+	records := []*TargetGroupRecord{}
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		IndexName:              aws.String(recordTypeIndex),
+		KeyConditionExpression: aws.String("RecordType = :type"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":type": {S: aws.String(targetGroupRecordType)},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	for {
+		resp, err := s.dynamodb.Query(input)
+		if err != nil {
+			return nil, err
+		}
+
+		rs := []*TargetGroupRecord{}
+		if err := dynamodbattribute.UnmarshalListOfMaps(resp.Items, &rs); err != nil {
+			return nil, err
+		}
+		records = append(records, rs...)
+
+		if len(resp.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+
+	return records, nil
+}
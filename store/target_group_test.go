@@ -0,0 +1,64 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ryotarai/paramedic/awsclient/fake"
+)
+
+func TestTargetGroups(t *testing.T) {
+	s := New(fake.NewDynamoDB(), "")
+	if err := s.CreateTablesIfNotExists(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1506486379, 0)
+	if err := s.PutCommand(&CommandRecord{CommandID: "c1", DocumentName: "reload-nginx", StartedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	for i, g := range []*TargetGroupRecord{
+		{Name: "app", Tags: []string{"Role=app"}, ExcludeTags: []string{"Canary"}},
+		{Name: "web", Tags: []string{"Role=web"}},
+		{Name: "app", Tags: []string{"Role=app|worker"}, InstanceName: "app-*"},
+	} {
+		g.UpdatedAt = now.Add(time.Duration(i) * time.Minute)
+		if err := s.PutTargetGroup(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g, err := s.GetTargetGroup("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &TargetGroupRecord{Name: "app", Tags: []string{"Role=app|worker"}, InstanceName: "app-*", UpdatedAt: now.Add(2 * time.Minute)}
+	if !reflect.DeepEqual(g, want) {
+		t.Errorf("got %+v, want %+v", g, want)
+	}
+	if _, err := s.GetTargetGroup("unknown"); err == nil {
+		t.Error("GetTargetGroup of an unknown group returns no error")
+	}
+
+	groups, err := s.ListTargetGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, g := range groups {
+		names = append(names, g.Name)
+	}
+	if want := []string{"app", "web"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+
+	// Target groups are not in the command history
+	records, _, err := s.ListCommands(&ListCommandsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].CommandID != "c1" {
+		t.Errorf("got %+v, want only c1", records)
+	}
+}